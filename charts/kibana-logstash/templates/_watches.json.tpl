@@ -50,7 +50,7 @@
                             "host": "outlook.office.com",
                             "port": 443,
                             "method": "post",
-                            "path": "{{ .Values.watcher.webhooks.teams }}",
+                            "path": "${secret:teams_webhook}",
                             "params": {},
                             "headers": {},
                             "body": "{\"text\":\"HTTP 404 Errors\",\"fields\":[{\"title\":\"Count\",\"value\":\"{{`{{ctx.payload.hits.total}}`}}\",\"short\":true}]}]}"
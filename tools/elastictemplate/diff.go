@@ -0,0 +1,344 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// fetchTemplate retrieves an installed template without the wrapping added by the API of its kind
+func fetchTemplate(host string, port int, authFile string, kind string, name string) (*Template, error) {
+	statusCode, content, err := doRequest(http.MethodGet, buildTemplateURL(host, port, kind, name), authFile, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve the template '%s'. Error: %v", name, err)
+	}
+	if statusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to retrieve the template '%s'. Status Code: %d. Error: %s", name, statusCode, string(content))
+	}
+
+	templates, err := parseInstalledTemplates(kind, content)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the template '%s'. Error: %v", name, err)
+	}
+	for _, template := range templates {
+		if template.Name == name {
+			return &template, nil
+		}
+	}
+	return nil, nil
+}
+
+// The JSON and text diff helpers below, up to hunkRange, are kept identical to the ones of tools/elasticwatcher/diff.go
+// since both tools are built as separate binaries: apply every fix to both copies.
+
+func formatJSONValue(value interface{}) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(content)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonPathKey appends a key to a JSON path, the keys which are not plain identifiers, e.g. the
+// flattened settings, are quoted
+func jsonPathKey(path string, key string) string {
+	for _, r := range key {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return path + "[" + strconv.Quote(key) + "]"
+		}
+	}
+	return path + "." + key
+}
+
+// jsonPathDiff lists the differences between the installed and local values, one per JSON path
+func jsonPathDiff(installed interface{}, local interface{}, path string) []string {
+	if reflect.DeepEqual(installed, local) {
+		return nil
+	}
+
+	installedMap, installedIsMap := installed.(map[string]interface{})
+	localMap, localIsMap := local.(map[string]interface{})
+	if installedIsMap && localIsMap {
+		keys := make(map[string]interface{})
+		for key := range installedMap {
+			keys[key] = nil
+		}
+		for key := range localMap {
+			keys[key] = nil
+		}
+
+		var diffs []string
+		for _, key := range sortedKeys(keys) {
+			keyPath := jsonPathKey(path, key)
+			installedValue, inInstalled := installedMap[key]
+			localValue, inLocal := localMap[key]
+			switch {
+			case !inLocal:
+				diffs = append(diffs, fmt.Sprintf("- %s: %s", keyPath, formatJSONValue(installedValue)))
+			case !inInstalled:
+				diffs = append(diffs, fmt.Sprintf("+ %s: %s", keyPath, formatJSONValue(localValue)))
+			default:
+				diffs = append(diffs, jsonPathDiff(installedValue, localValue, keyPath)...)
+			}
+		}
+		return diffs
+	}
+
+	installedList, installedIsList := installed.([]interface{})
+	localList, localIsList := local.([]interface{})
+	if installedIsList && localIsList && len(installedList) == len(localList) {
+		var diffs []string
+		for i := range installedList {
+			diffs = append(diffs, jsonPathDiff(installedList[i], localList[i], fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return diffs
+	}
+
+	return []string{fmt.Sprintf("~ %s: %s -> %s", path, formatJSONValue(installed), formatJSONValue(local))}
+}
+
+// unifiedDiff builds a line based diff between two texts with the given number of context lines
+func unifiedDiff(fromName string, from string, toName string, to string, context int) []string {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	// lcs[i][j] holds the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// fromLines and toLines hold the number of lines of each text before every diff line
+	var lines []string
+	var fromLines, toLines []int
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		fromLines = append(fromLines, i)
+		toLines = append(toLines, j)
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	keep := make([]bool, len(lines))
+	changed := false
+	for n, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+		changed = true
+		for k := n - context; k <= n+context; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	diff := []string{"--- " + fromName, "+++ " + toName}
+	for start := 0; start < len(lines); start++ {
+		if !keep[start] {
+			continue
+		}
+		end := start
+		fromCount, toCount := 0, 0
+		for ; end < len(lines) && keep[end]; end++ {
+			if lines[end][0] != '+' {
+				fromCount++
+			}
+			if lines[end][0] != '-' {
+				toCount++
+			}
+		}
+		diff = append(diff, fmt.Sprintf("@@ -%s +%s @@",
+			hunkRange(fromLines[start], fromCount), hunkRange(toLines[start], toCount)))
+		diff = append(diff, lines[start:end]...)
+		start = end
+	}
+	return diff
+}
+
+// hunkRange formats the range of a hunk header, an empty range starts at the line before the hunk
+func hunkRange(before int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// diffTemplate lists the differences between the normalized installed and local templates
+func diffTemplate(installed Template, local Template, format string) ([]string, error) {
+	installedBody, err := normalizeTemplate(installed.Kind, installed.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to normalize the installed template '%s'. Error: %v", installed.Name, err)
+	}
+	localBody, err := normalizeTemplate(local.Kind, local.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to normalize the template '%s'. Error: %v", local.Name, err)
+	}
+
+	if format == "path" {
+		return jsonPathDiff(installedBody, localBody, "$"), nil
+	}
+	installedContent, _ := json.MarshalIndent(installedBody, "", "  ") // #nosec
+	localContent, _ := json.MarshalIndent(localBody, "", "  ")         // #nosec
+	return unifiedDiff("installed/"+installed.Name, string(installedContent),
+		"local/"+local.Name, string(localContent), 3), nil
+}
+
+type diffCmd struct {
+	host          string
+	port          int
+	templatesFile string
+	authFile      string
+	templates     string
+	format        string
+}
+
+func (*diffCmd) Name() string { return "diff" }
+func (*diffCmd) Synopsis() string {
+	return "Show the differences between the templates file and the templates installed in Elasticsearch"
+}
+
+func (*diffCmd) Usage() string {
+	return `diff [-host] <host name> [-port] <port> [-templates-file] <path to templates file> [-templates] <comma separated list of templates> [-format] <unified|path> [-auth-file] <path to basic auth file>
+        Show the differences between the templates file and the templates installed in Elasticsearch.
+        Both sides are normalized before the comparison, the settings are flattened with string values and
+        the empty sections and the defaults are dropped. The command exits with a non-zero code when any template differs.
+	`
+}
+
+func (d *diffCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&d.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&d.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&d.templatesFile, "templates-file", "", "Path to templates file")
+	f.StringVar(&d.templates, "templates", "", "Comma separated list of template names to compare (default all templates in the file)")
+	f.StringVar(&d.format, "format", "unified", "Output format of the differences: unified or path")
+	f.StringVar(&d.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (d *diffCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if d.format != "unified" && d.format != "path" {
+		fmt.Printf("Unknown diff format '%s'\n", d.format)
+		return subcommands.ExitUsageError
+	}
+	if _, err := os.Stat(d.templatesFile); os.IsNotExist(err) {
+		fmt.Printf("Templates file '%s' not found\n", d.templatesFile)
+		return subcommands.ExitFailure
+	}
+	cfg, err := loadTemplates(d.templatesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	templates, err := orderTemplates(cfg.Templates)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	declared := make(map[string]bool)
+	for _, template := range templates {
+		declared[template.Name] = true
+	}
+	selected := make(map[string]bool)
+	unknown := false
+	if d.templates != "" {
+		for _, name := range parseTemplateNames(d.templates) {
+			if !declared[name] {
+				fmt.Printf("Template '%s' not found in the templates file\n", name)
+				unknown = true
+			}
+			selected[name] = true
+		}
+	}
+	if unknown {
+		return subcommands.ExitFailure
+	}
+
+	drift := false
+	for _, template := range templates {
+		if len(selected) > 0 && !selected[template.Name] {
+			continue
+		}
+
+		installed, err := fetchTemplate(d.host, d.port, d.authFile, template.Kind, template.Name)
+		if err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+		if installed == nil {
+			drift = true
+			fmt.Printf("Template '%s' (%s): not installed\n", template.Name, template.Kind)
+			continue
+		}
+
+		diffs, err := diffTemplate(*installed, template, d.format)
+		if err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+		if len(diffs) == 0 {
+			fmt.Printf("Template '%s' (%s): no differences\n", template.Name, template.Kind)
+			continue
+		}
+		drift = true
+		fmt.Printf("Template '%s' (%s):\n", template.Name, template.Kind)
+		for _, line := range diffs {
+			fmt.Println(line)
+		}
+	}
+
+	if drift {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
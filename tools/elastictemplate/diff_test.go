@@ -0,0 +1,162 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elastictemplate diff", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	var templatesFile *os.File
+
+	const Templates = `{"templates": [
+		{"name": "logstash", "body": {"template": "logstash-*", "settings": {"number_of_shards": 1}, "aliases": {}}},
+		{"name": "settings", "kind": "component", "body": {"template": {"settings": {"number_of_replicas": 0}}}}
+	]}`
+	const InstalledLogstash = `{"logstash": {"order": 0, "index_patterns": ["logstash-*"], "settings": {"index": {"number_of_shards": "1"}}, "mappings": {}, "aliases": {}}}`
+	const InstalledSettings = `{"component_templates": [{"name": "settings", "component_template": {"template": {"settings": {"index": {"number_of_replicas": "0"}}}}}]}`
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		u, err := url.Parse(server.URL())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		host, port, err := net.SplitHostPort(u.Host)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		elasticHost = host
+		elasticPort, err = strconv.Atoi(port)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		templatesFile, err = ioutil.TempFile("", "templates")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = templatesFile.Write([]byte(Templates))
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.Remove(templatesFile.Name())
+		server.Close()
+	})
+
+	It("should list the differences by JSON path", func() {
+		installed := Template{Name: "logstash", Kind: legacyTemplateKind, Body: map[string]interface{}{
+			"order":          float64(1),
+			"index_patterns": []interface{}{"logstash-*"},
+			"settings":       map[string]interface{}{"index": map[string]interface{}{"number_of_shards": "2"}},
+		}}
+		local := Template{Name: "logstash", Kind: legacyTemplateKind, Body: map[string]interface{}{
+			"template": "logstash-*",
+			"settings": map[string]interface{}{"number_of_shards": float64(1), "refresh_interval": "5s"},
+		}}
+
+		diffs, err := diffTemplate(installed, local, "path")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(diffs).Should(Equal([]string{
+			`- $.order: 1`,
+			`~ $.settings["index.number_of_shards"]: "2" -> "1"`,
+			`+ $.settings["index.refresh_interval"]: "5s"`,
+		}))
+	})
+
+	It("should produce a unified diff", func() {
+		installed := Template{Name: "settings", Kind: componentTemplateKind, Body: map[string]interface{}{
+			"template": map[string]interface{}{"settings": map[string]interface{}{"index": map[string]interface{}{"number_of_replicas": "1"}}},
+		}}
+		local := Template{Name: "settings", Kind: componentTemplateKind, Body: map[string]interface{}{
+			"template": map[string]interface{}{"settings": map[string]interface{}{"number_of_replicas": float64(0)}},
+		}}
+
+		diffs, err := diffTemplate(installed, local, "unified")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(diffs).Should(ContainElement(`-      "index.number_of_replicas": "1"`))
+		Expect(diffs).Should(ContainElement(`+      "index.number_of_replicas": "0"`))
+	})
+
+	It("should write the line ranges in the hunk headers", func() {
+		Expect(unifiedDiff("a", "x\ny\nz", "b", "x\nw\nz", 0)).Should(Equal(
+			[]string{"--- a", "+++ b", "@@ -2,1 +2,1 @@", "-y", "+w"}))
+		Expect(unifiedDiff("a", "x\ny\nz\nq\nr", "b", "w\nx\ny\nz\nr", 1)).Should(Equal(
+			[]string{"--- a", "+++ b", "@@ -1,1 +1,2 @@", "+w", " x", "@@ -3,3 +4,2 @@", " z", "-q", " r"}))
+		Expect(unifiedDiff("a", "x", "b", "w\nx", 0)).Should(Equal(
+			[]string{"--- a", "+++ b", "@@ -0,0 +1,1 @@", "+w"}))
+	})
+
+	It("should succeed when the normalized templates match", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/_component_template/settings"),
+				ghttp.RespondWith(http.StatusOK, InstalledSettings),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/_template/logstash"),
+				ghttp.RespondWith(http.StatusOK, InstalledLogstash),
+			),
+		)
+
+		cmd := &diffCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name(), format: "unified"}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+	})
+
+	It("should fail when a template is not installed", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/_template/logstash"),
+				ghttp.RespondWith(http.StatusNotFound, `{}`),
+			),
+		)
+
+		cmd := &diffCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name(), templates: "logstash", format: "path"}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		Expect(server.ReceivedRequests()).Should(HaveLen(1))
+	})
+
+	It("should fail when a template drifted", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/_template/logstash"),
+				ghttp.RespondWith(http.StatusOK, `{"logstash": {"order": 0, "index_patterns": ["logstash-*"], "settings": {"index": {"number_of_shards": "3"}}}}`),
+			),
+		)
+
+		cmd := &diffCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name(), templates: "logstash", format: "path"}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+	})
+
+	It("should fail when a selected template is not in the templates file", func() {
+		cmd := &diffCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name(), templates: "logstash,unknown", format: "path"}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		Expect(server.ReceivedRequests()).Should(BeEmpty())
+	})
+
+	It("should reject an unknown format", func() {
+		cmd := &diffCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name(), format: "json"}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+	})
+})
@@ -15,6 +15,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -36,11 +37,99 @@ type TemplatesConfig struct {
 // Template define a template
 type Template struct {
 	Name string
+	// Kind of the template: legacy (default), index or component
+	Kind string
 	Body interface{}
 }
 
-func buildTemplateURL(host string, port int, templateID string) string {
-	return fmt.Sprintf("http://%s:%d/_template/%s", host, port, templateID)
+const (
+	legacyTemplateKind    = "legacy"
+	indexTemplateKind     = "index"
+	componentTemplateKind = "component"
+)
+
+// templateAPIs the Elasticsearch API of each template kind
+var templateAPIs = map[string]string{
+	legacyTemplateKind:    "_template",
+	indexTemplateKind:     "_index_template",
+	componentTemplateKind: "_component_template",
+}
+
+// parseTemplateKind checks the kind of a template, the legacy kind is the default
+func parseTemplateKind(kind string) (string, error) {
+	kind = strings.TrimSpace(kind)
+	if kind == "" {
+		return legacyTemplateKind, nil
+	}
+	if _, ok := templateAPIs[kind]; !ok {
+		return "", fmt.Errorf("Unknown template kind '%s', expected legacy, index or component", kind)
+	}
+	return kind, nil
+}
+
+func buildTemplateURL(host string, port int, kind string, templateID string) string {
+	if templateID == "" {
+		return fmt.Sprintf("http://%s:%d/%s", host, port, templateAPIs[kind])
+	}
+	return fmt.Sprintf("http://%s:%d/%s/%s", host, port, templateAPIs[kind], templateID)
+}
+
+// orderTemplates returns the templates with the component templates first, so that they are installed
+// before the index templates which reference them in composed_of
+func orderTemplates(templates []Template) ([]Template, error) {
+	var components, others []Template
+	for _, template := range templates {
+		kind, err := parseTemplateKind(template.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid template '%s': %v", template.Name, err)
+		}
+		template.Kind = kind
+		if kind == componentTemplateKind {
+			components = append(components, template)
+		} else {
+			others = append(others, template)
+		}
+	}
+	return append(components, others...), nil
+}
+
+// parseInstalledTemplates returns the templates, sorted by name, from the response of the API of the given kind
+func parseInstalledTemplates(kind string, content []byte) ([]Template, error) {
+	var templates []Template
+	switch kind {
+	case legacyTemplateKind:
+		var bodies map[string]interface{}
+		if err := json.Unmarshal(content, &bodies); err != nil {
+			return nil, err
+		}
+		for name, body := range bodies {
+			templates = append(templates, Template{Name: name, Kind: kind, Body: body})
+		}
+	default:
+		var response map[string][]map[string]interface{}
+		if err := json.Unmarshal(content, &response); err != nil {
+			return nil, err
+		}
+		for _, template := range response[kind+"_templates"] {
+			name, _ := template["name"].(string)
+			templates = append(templates, Template{Name: name, Kind: kind, Body: template[kind+"_template"]})
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// parseTemplateList returns the sorted names of the templates listed by the API of the given kind
+func parseTemplateList(kind string, content []byte) ([]string, error) {
+	templates, err := parseInstalledTemplates(kind, content)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(templates))
+	for _, template := range templates {
+		names = append(names, template.Name)
+	}
+	return names, nil
 }
 
 func buildHTTPClient() *http.Client {
@@ -49,6 +138,45 @@ func buildHTTPClient() *http.Client {
 	}
 }
 
+// doRequest executes a request against the Elasticsearch API and returns the status code
+// together with the response content. The body is encoded as JSON when provided.
+func doRequest(method string, url string, authFile string, body interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("Failed to encode the request body: %v", err)
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to build the HTTP request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	err = setBasicAuth(req, authFile)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to set the Basic Auth Header: %v", err)
+	}
+
+	client := buildHTTPClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("Failed to read the response: %v", err)
+	}
+	return resp.StatusCode, content, nil
+}
+
 func loadBasicAuth(authFile string) (*BasicAuth, error) {
 	file, err := ioutil.ReadFile(authFile) // #nosec
 	if err != nil {
@@ -101,6 +229,7 @@ type retrieveCmd struct {
 	port      int
 	authFile  string
 	templates string
+	kind      string
 }
 
 func (*retrieveCmd) Name() string { return "retrieve" }
@@ -109,7 +238,7 @@ func (*retrieveCmd) Synopsis() string {
 }
 
 func (*retrieveCmd) Usage() string {
-	return `retrieve [-host] <host name> [-port] <port> [-templates] <comma separated list of templates> [-auth-file] <path to basic auth file>
+	return `retrieve [-host] <host name> [-port] <port> [-templates] <comma separated list of templates> [-kind] <template kind> [-auth-file] <path to basic auth file>
         Retrieve the content of Elasticsearch Index Templates
 
 	`
@@ -119,15 +248,21 @@ func (r *retrieveCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&r.host, "host", "localhost", "Host name of the Elasticsearch API")
 	f.IntVar(&r.port, "port", 9200, "Port of the Elastisearch API")
 	f.StringVar(&r.templates, "templates", "", "Comma separated list with template names")
+	f.StringVar(&r.kind, "kind", legacyTemplateKind, "Kind of the templates: legacy, index or component")
 	f.StringVar(&r.authFile, "auth-file", "", "Path to basic auth file")
 }
 
 func (r *retrieveCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	kind, err := parseTemplateKind(r.kind)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitUsageError
+	}
 	templateNames := parseTemplateNames(r.templates)
 	client := buildHTTPClient()
 
 	for _, template := range templateNames {
-		templateURL := buildTemplateURL(r.host, r.port, template)
+		templateURL := buildTemplateURL(r.host, r.port, kind, template)
 
 		req, err := http.NewRequest(http.MethodGet, templateURL, nil)
 		if err != nil {
@@ -175,6 +310,7 @@ type deleteCmd struct {
 	port      int
 	authFile  string
 	templates string
+	kind      string
 }
 
 func (*deleteCmd) Name() string { return "delete" }
@@ -183,17 +319,22 @@ func (*deleteCmd) Synopsis() string {
 }
 
 func (*deleteCmd) Usage() string {
-	return `delete [-host] <host name> [-port] <port> [-templates] <comma separated list of templates> [-auth-file] <path to basic auth file>
+	return `delete [-host] <host name> [-port] <port> [-templates] <comma separated list of templates> [-kind] <template kind> [-auth-file] <path to basic auth file>
         Delete the templates from Elasticsearch
 	`
 }
 
 func (d *deleteCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	kind, err := parseTemplateKind(d.kind)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitUsageError
+	}
 	templateNames := parseTemplateNames(d.templates)
 	client := buildHTTPClient()
 
 	for _, template := range templateNames {
-		templateURL := buildTemplateURL(d.host, d.port, template)
+		templateURL := buildTemplateURL(d.host, d.port, kind, template)
 
 		req, err := http.NewRequest(http.MethodDelete, templateURL, nil)
 		if err != nil {
@@ -239,6 +380,7 @@ func (d *deleteCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&d.host, "host", "localhost", "Host name of the Elasticsearch API")
 	f.IntVar(&d.port, "port", 9200, "Port of the Elastisearch API")
 	f.StringVar(&d.templates, "templates", "", "Comma separated list of template names")
+	f.StringVar(&d.kind, "kind", legacyTemplateKind, "Kind of the templates: legacy, index or component")
 	f.StringVar(&d.authFile, "auth-file", "", "Path to basic auth file")
 }
 
@@ -246,6 +388,7 @@ type listCmd struct {
 	host     string
 	port     int
 	authFile string
+	kind     string
 }
 
 func (*listCmd) Name() string { return "list" }
@@ -253,7 +396,7 @@ func (*listCmd) Synopsis() string {
 	return "List all Elasticsearch Index Templates"
 }
 func (*listCmd) Usage() string {
-	return `list [-host] <host name> [-port] <port> [-auth-file] <path to basic auth file>
+	return `list [-host] <host name> [-port] <port> [-kind] <template kind> [-auth-file] <path to basic auth file>
         List the Elasticsearch Index Templates
 	`
 }
@@ -261,12 +404,18 @@ func (*listCmd) Usage() string {
 func (l *listCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&l.host, "host", "localhost", "Host name of the Elasticsearch API")
 	f.IntVar(&l.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&l.kind, "kind", legacyTemplateKind, "Kind of the templates: legacy, index or component")
 	f.StringVar(&l.authFile, "auth-file", "", "Path to basic auth file")
 }
 
 func (l *listCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	kind, err := parseTemplateKind(l.kind)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitUsageError
+	}
 	client := buildHTTPClient()
-	templateURL := buildTemplateURL(l.host, l.port, "*")
+	templateURL := buildTemplateURL(l.host, l.port, kind, "*")
 
 	req, err := http.NewRequest(http.MethodGet, templateURL, nil)
 	if err != nil {
@@ -293,16 +442,15 @@ func (l *listCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 		return subcommands.ExitFailure
 	}
 
-	var templates map[string]interface{}
-	err = json.Unmarshal(content, &templates)
+	templates, err := parseTemplateList(kind, content)
 	if err != nil {
 		fmt.Printf("Failed to unmarshal the templates. Error: %v\n", err)
 		return subcommands.ExitFailure
 	}
 
 	fmt.Println("Templates:")
-	for key := range templates {
-		fmt.Printf("%s\n", key)
+	for _, name := range templates {
+		fmt.Printf("%s\n", name)
 	}
 	return subcommands.ExitSuccess
 }
@@ -343,7 +491,13 @@ func (c *createCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 		return subcommands.ExitFailure
 	}
 
-	for _, template := range cfg.Templates {
+	templates, err := orderTemplates(cfg.Templates)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	for _, template := range templates {
 		reader, writer := io.Pipe()
 		wg := sync.WaitGroup{}
 		wg.Add(2)
@@ -358,7 +512,7 @@ func (c *createCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 		go func() {
 			defer wg.Done()
 			defer reader.Close()
-			templateURL := buildTemplateURL(c.host, c.port, template.Name)
+			templateURL := buildTemplateURL(c.host, c.port, template.Kind, template.Name)
 			req, err := http.NewRequest(http.MethodPut, templateURL, reader)
 			if err != nil {
 				errc <- fmt.Errorf("Failed to build the request to create the template: %v", err)
@@ -395,7 +549,7 @@ func (c *createCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 			fmt.Println(err)
 			return subcommands.ExitFailure
 		}
-		fmt.Printf("Successfully created/updated the template '%s'.\n", template.Name)
+		fmt.Printf("Successfully created/updated the %s template '%s'.\n", template.Kind, template.Name)
 	}
 	return subcommands.ExitSuccess
 }
@@ -407,7 +561,9 @@ func main() {
 	subcommands.Register(&createCmd{}, "")
 	subcommands.Register(&listCmd{}, "")
 	subcommands.Register(&deleteCmd{}, "")
+	subcommands.Register(&diffCmd{}, "")
 	subcommands.Register(&retrieveCmd{}, "")
+	subcommands.Register(&syncCmd{}, "")
 
 	flag.Parse()
 	ctx := context.Background()
@@ -306,4 +306,102 @@ var _ = Describe("The elastictemplate client", func() {
 		})
 	})
 
+	Context("composable templates", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			elasticHost = host
+			elasticPort, err = strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should install the component templates before the index templates", func() {
+			templatesFile, err := ioutil.TempFile("", "templates")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.Remove(templatesFile.Name())
+			_, err = templatesFile.Write([]byte(`{"templates": [
+				{"name": "logstash", "kind": "index", "body": {"index_patterns": ["logstash-*"], "composed_of": ["settings"]}},
+				{"name": "legacy", "body": {"template": "old-*"}},
+				{"name": "settings", "kind": "component", "body": {"template": {"settings": {"number_of_shards": 1}}}}
+			]}`))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			server.AppendHandlers(
+				ghttp.VerifyRequest("PUT", "/_component_template/settings"),
+				ghttp.VerifyRequest("PUT", "/_index_template/logstash"),
+				ghttp.VerifyRequest("PUT", "/_template/legacy"),
+			)
+
+			cmd := &createCmd{
+				host:          elasticHost,
+				port:          elasticPort,
+				templatesFile: templatesFile.Name()}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+
+		It("should reject an unknown template kind", func() {
+			_, err := orderTemplates([]Template{{Name: "logstash", Kind: "composable"}})
+			Expect(err).Should(HaveOccurred())
+
+			cmd := &listCmd{host: elasticHost, port: elasticPort, kind: "composable"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+
+		It("should list the index templates", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/_index_template/*"),
+					ghttp.RespondWith(http.StatusOK, `{"index_templates": [{"name": "logstash", "index_template": {}}]}`),
+				),
+			)
+
+			cmd := &listCmd{host: elasticHost, port: elasticPort, kind: "index"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should parse the template lists of each kind", func() {
+			Expect(parseTemplateList("legacy", []byte(`{"b": {}, "a": {}}`))).Should(Equal([]string{"a", "b"}))
+			Expect(parseTemplateList("component", []byte(`{"component_templates": [{"name": "settings", "component_template": {}}]}`))).
+				Should(Equal([]string{"settings"}))
+		})
+
+		It("should retrieve and delete the component templates", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/_component_template/settings"),
+					ghttp.RespondWith(http.StatusOK, `{"component_templates": []}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", "/_component_template/settings"),
+					ghttp.RespondWith(http.StatusOK, `{"acknowledged": true}`),
+				),
+			)
+
+			retrieve := &retrieveCmd{host: elasticHost, port: elasticPort, templates: "settings", kind: "component"}
+			Expect(retrieve.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+
+			del := &deleteCmd{host: elasticHost, port: elasticPort, templates: "settings", kind: "component"}
+			Expect(del.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(2))
+		})
+	})
+
 })
@@ -0,0 +1,107 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// normalizeTemplate converts the body of a template into the form returned by Elasticsearch, so that a
+// declared template can be compared with an installed one: the settings are flattened under the index
+// prefix with string values, and the empty sections and the defaults added by the server are dropped
+func normalizeTemplate(kind string, body interface{}) (map[string]interface{}, error) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var template map[string]interface{}
+	if err := json.Unmarshal(content, &template); err != nil {
+		return nil, fmt.Errorf("the template body is not a JSON object")
+	}
+	if template == nil {
+		template = map[string]interface{}{}
+	}
+
+	switch kind {
+	case legacyTemplateKind:
+		// the pattern of the 5.x templates is returned as index_patterns
+		if pattern, ok := template["template"].(string); ok {
+			template["index_patterns"] = []interface{}{pattern}
+			delete(template, "template")
+		}
+		if order, ok := template["order"].(float64); ok && order == 0 {
+			delete(template, "order")
+		}
+		normalizeTemplateSection(template)
+	default:
+		if section, ok := template["template"].(map[string]interface{}); ok {
+			normalizeTemplateSection(section)
+			if len(section) == 0 {
+				delete(template, "template")
+			}
+		}
+		if composedOf, ok := template["composed_of"].([]interface{}); ok && len(composedOf) == 0 {
+			delete(template, "composed_of")
+		}
+	}
+	return template, nil
+}
+
+// normalizeTemplateSection normalizes the settings, the mappings and the aliases of a template
+func normalizeTemplateSection(section map[string]interface{}) {
+	if settings, ok := section["settings"].(map[string]interface{}); ok {
+		flattened := map[string]interface{}{}
+		flattenSettings("", settings, flattened)
+		section["settings"] = flattened
+	}
+	for _, key := range []string{"settings", "mappings", "aliases"} {
+		if value, ok := section[key].(map[string]interface{}); ok && len(value) == 0 {
+			delete(section, key)
+		}
+	}
+}
+
+// flattenSettings flattens the nested settings into index prefixed keys with string values
+func flattenSettings(prefix string, settings map[string]interface{}, flattened map[string]interface{}) {
+	for key, value := range settings {
+		key = prefix + key
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenSettings(key+".", nested, flattened)
+			continue
+		}
+		if !strings.HasPrefix(key, "index.") {
+			key = "index." + key
+		}
+		if values, ok := value.([]interface{}); ok {
+			strs := make([]interface{}, 0, len(values))
+			for _, v := range values {
+				strs = append(strs, settingString(v))
+			}
+			flattened[key] = strs
+			continue
+		}
+		flattened[key] = settingString(value)
+	}
+}
+
+// settingString returns the string form of a scalar setting, as Elasticsearch returns it
+func settingString(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return nil
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
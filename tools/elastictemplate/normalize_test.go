@@ -0,0 +1,73 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The template normalization", func() {
+	normalize := func(kind string, body string) map[string]interface{} {
+		var value interface{}
+		Expect(json.Unmarshal([]byte(body), &value)).Should(Succeed())
+		template, err := normalizeTemplate(kind, value)
+		Expect(err).ShouldNot(HaveOccurred())
+		return template
+	}
+
+	It("should normalize a declared legacy template as the installed one", func() {
+		declared := normalize(legacyTemplateKind, `{
+			"template": "logstash-*",
+			"settings": {"number_of_shards": 1, "index": {"refresh_interval": "5s"}, "mapper": {"dynamic": false}},
+			"aliases": {}
+		}`)
+		installed := normalize(legacyTemplateKind, `{
+			"order": 0,
+			"index_patterns": ["logstash-*"],
+			"settings": {"index": {"number_of_shards": "1", "refresh_interval": "5s", "mapper": {"dynamic": "false"}}},
+			"mappings": {},
+			"aliases": {}
+		}`)
+
+		Expect(declared).Should(Equal(installed))
+		Expect(declared).Should(Equal(map[string]interface{}{
+			"index_patterns": []interface{}{"logstash-*"},
+			"settings": map[string]interface{}{
+				"index.number_of_shards": "1",
+				"index.refresh_interval": "5s",
+				"index.mapper.dynamic":   "false",
+			},
+		}))
+	})
+
+	It("should normalize the template section of the composable templates", func() {
+		declared := normalize(indexTemplateKind, `{
+			"index_patterns": ["logstash-*"],
+			"template": {"settings": {"number_of_replicas": 0}}
+		}`)
+		installed := normalize(indexTemplateKind, `{
+			"index_patterns": ["logstash-*"],
+			"composed_of": [],
+			"template": {"settings": {"index": {"number_of_replicas": "0"}}, "aliases": {}}
+		}`)
+
+		Expect(declared).Should(Equal(installed))
+		Expect(normalize(componentTemplateKind, `{"template": {"aliases": {}}}`)).Should(BeEmpty())
+	})
+
+	It("should keep the differences", func() {
+		Expect(normalize(legacyTemplateKind, `{"order": 1, "settings": {"number_of_shards": 2}}`)).
+			ShouldNot(Equal(normalize(legacyTemplateKind, `{"settings": {"number_of_shards": 1}}`)))
+	})
+
+	It("should reject a body which is not an object", func() {
+		_, err := normalizeTemplate(legacyTemplateKind, []interface{}{"logstash-*"})
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,311 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// syncPlan holds the changes required to reconcile the installed templates with a templates file
+type syncPlan struct {
+	Create    []Template
+	Update    []Template
+	Unchanged []Template
+	// Extra installed templates which are not declared in the templates file
+	Extra []Template
+	// Delete the extra templates which match the allowed name patterns
+	Delete []Template
+}
+
+// templateKinds the kinds of templates, in the order in which the undeclared templates are deleted:
+// the index templates before the component templates they may reference
+var templateKinds = []string{indexTemplateKind, legacyTemplateKind, componentTemplateKind}
+
+func templateKey(template Template) string {
+	return template.Kind + "/" + template.Name
+}
+
+func templateLabels(templates []Template) []string {
+	labels := make([]string, 0, len(templates))
+	for _, template := range templates {
+		labels = append(labels, fmt.Sprintf("%s (%s)", template.Name, template.Kind))
+	}
+	return labels
+}
+
+// matchesAnyPattern checks if a template name matches one of the glob patterns
+func matchesAnyPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchInstalledTemplates retrieves the templates of every kind installed in the cluster, the kinds
+// which are not supported by the cluster are skipped
+func fetchInstalledTemplates(host string, port int, authFile string) ([]Template, error) {
+	var installed []Template
+	for _, kind := range templateKinds {
+		statusCode, content, err := doRequest(http.MethodGet, buildTemplateURL(host, port, kind, ""), authFile, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve the %s templates. Error: %v", kind, err)
+		}
+		if kind != legacyTemplateKind && unsupportedTemplateAPI(statusCode, content) {
+			continue
+		}
+		if statusCode != http.StatusOK {
+			return nil, fmt.Errorf("Failed to retrieve the %s templates. Status Code: %d. Error: %s", kind, statusCode, string(content))
+		}
+		templates, err := parseInstalledTemplates(kind, content)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal the %s templates. Error: %v", kind, err)
+		}
+		installed = append(installed, templates...)
+	}
+	return installed, nil
+}
+
+// unsupportedTemplateAPI tells whether an error response of the index or component templates API comes from
+// a cluster older than 7.8, which has no handler for the endpoint or takes it for an index name
+func unsupportedTemplateAPI(statusCode int, content []byte) bool {
+	if statusCode != http.StatusBadRequest && statusCode != http.StatusNotFound {
+		return false
+	}
+	var resp struct {
+		Error interface{} `json:"error"`
+	}
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return false
+	}
+	switch e := resp.Error.(type) {
+	case string:
+		return strings.HasPrefix(e, "no handler found for uri")
+	case map[string]interface{}:
+		if e["type"] != "invalid_index_name_exception" && e["type"] != "index_not_found_exception" {
+			return false
+		}
+		index, _ := e["index"].(string)
+		return strings.HasPrefix(index, "_")
+	}
+	return false
+}
+
+// computeSyncPlan compares the declared templates with the installed ones
+func computeSyncPlan(declared []Template, installed []Template, prunePatterns []string) (*syncPlan, error) {
+	installedByKey := make(map[string]Template, len(installed))
+	for _, template := range installed {
+		installedByKey[templateKey(template)] = template
+	}
+
+	plan := &syncPlan{}
+	declaredKeys := make(map[string]bool, len(declared))
+	for _, template := range declared {
+		declaredKeys[templateKey(template)] = true
+		current, ok := installedByKey[templateKey(template)]
+		if !ok {
+			plan.Create = append(plan.Create, template)
+			continue
+		}
+		body, err := normalizeTemplate(template.Kind, template.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to normalize the template '%s': %v", template.Name, err)
+		}
+		currentBody, err := normalizeTemplate(current.Kind, current.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to normalize the installed template '%s': %v", current.Name, err)
+		}
+		if reflect.DeepEqual(body, currentBody) {
+			plan.Unchanged = append(plan.Unchanged, template)
+		} else {
+			plan.Update = append(plan.Update, template)
+		}
+	}
+
+	for _, template := range installed {
+		if declaredKeys[templateKey(template)] {
+			continue
+		}
+		plan.Extra = append(plan.Extra, template)
+		if matchesAnyPattern(template.Name, prunePatterns) {
+			plan.Delete = append(plan.Delete, template)
+		}
+	}
+	return plan, nil
+}
+
+func printSyncPlan(plan *syncPlan, prune bool) {
+	format := func(names []string) string {
+		if len(names) == 0 {
+			return "-"
+		}
+		return strings.Join(names, ", ")
+	}
+
+	deleted := make(map[string]bool, len(plan.Delete))
+	for _, template := range plan.Delete {
+		deleted[templateKey(template)] = true
+	}
+	extras := make([]string, 0, len(plan.Extra))
+	for _, template := range plan.Extra {
+		note := "kept, does not match the prune patterns"
+		if deleted[templateKey(template)] {
+			note = "deleted"
+			if !prune {
+				note = "skipped, use -prune to delete"
+			}
+		}
+		extras = append(extras, fmt.Sprintf("%s (%s, %s)", template.Name, template.Kind, note))
+	}
+
+	fmt.Println("Sync plan:")
+	fmt.Printf("  create:    %s\n", format(templateLabels(plan.Create)))
+	fmt.Printf("  update:    %s\n", format(templateLabels(plan.Update)))
+	fmt.Printf("  unchanged: %s\n", format(templateLabels(plan.Unchanged)))
+	fmt.Printf("  extra:     %s\n", format(extras))
+}
+
+func putTemplate(host string, port int, authFile string, template Template) error {
+	statusCode, content, err := doRequest(http.MethodPut, buildTemplateURL(host, port, template.Kind, template.Name), authFile, template.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to create/update the template '%s'. Error: %v", template.Name, err)
+	}
+	if statusCode >= http.StatusBadRequest {
+		return fmt.Errorf("Failed to create/update the template '%s'. Status Code: %d. Error: %s", template.Name, statusCode, string(content))
+	}
+	return nil
+}
+
+func deleteTemplate(host string, port int, authFile string, template Template) error {
+	statusCode, content, err := doRequest(http.MethodDelete, buildTemplateURL(host, port, template.Kind, template.Name), authFile, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to delete the template '%s'. Error: %v", template.Name, err)
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("Failed to delete the template '%s'. Status Code: %d. Error: %s", template.Name, statusCode, string(content))
+	}
+	return nil
+}
+
+type syncCmd struct {
+	host          string
+	port          int
+	templatesFile string
+	authFile      string
+	prune         bool
+	prunePatterns string
+	dryRun        bool
+}
+
+func (*syncCmd) Name() string { return "sync" }
+func (*syncCmd) Synopsis() string {
+	return "Reconcile the templates installed in Elasticsearch with a templates file"
+}
+
+func (*syncCmd) Usage() string {
+	return `sync [-host] <host name> [-port] <port> [-templates-file] <path to templates file> [-auth-file] <path to basic auth file> [-prune] [-prune-patterns] <comma separated list of name patterns> [-dry-run]
+        Create and update the templates declared in the templates file and, with -prune, delete the installed templates
+        which are not declared and whose name matches one of the prune patterns
+	`
+}
+
+func (s *syncCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&s.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&s.templatesFile, "templates-file", "", "Path to templates file")
+	f.StringVar(&s.authFile, "auth-file", "", "Path to basic auth file")
+	f.BoolVar(&s.prune, "prune", false, "Delete the installed templates which are not declared and match the prune patterns")
+	f.StringVar(&s.prunePatterns, "prune-patterns", "", "Comma separated list of name patterns of the templates which can be pruned, e.g. logstash-*")
+	f.BoolVar(&s.dryRun, "dry-run", false, "Only print the sync plan without applying it")
+}
+
+func (s *syncCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	var patterns []string
+	if s.prunePatterns != "" {
+		patterns = parseTemplateNames(s.prunePatterns)
+	}
+	if s.prune && len(patterns) == 0 {
+		fmt.Println("The prune patterns are required to prune the templates")
+		return subcommands.ExitUsageError
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fmt.Printf("Invalid prune pattern '%s'. Error: %v\n", pattern, err)
+			return subcommands.ExitUsageError
+		}
+	}
+
+	if _, err := os.Stat(s.templatesFile); os.IsNotExist(err) {
+		fmt.Printf("Templates file '%s' not found\n", s.templatesFile)
+		return subcommands.ExitFailure
+	}
+	cfg, err := loadTemplates(s.templatesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	templates, err := orderTemplates(cfg.Templates)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	installed, err := fetchInstalledTemplates(s.host, s.port, s.authFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	plan, err := computeSyncPlan(templates, installed, patterns)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	printSyncPlan(plan, s.prune)
+
+	if s.dryRun {
+		return subcommands.ExitSuccess
+	}
+
+	for _, template := range plan.Create {
+		if err := putTemplate(s.host, s.port, s.authFile, template); err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("Successfully created the %s template '%s'.\n", template.Kind, template.Name)
+	}
+
+	for _, template := range plan.Update {
+		if err := putTemplate(s.host, s.port, s.authFile, template); err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("Successfully updated the %s template '%s'.\n", template.Kind, template.Name)
+	}
+
+	if s.prune {
+		for _, template := range plan.Delete {
+			if err := deleteTemplate(s.host, s.port, s.authFile, template); err != nil {
+				fmt.Println(err)
+				return subcommands.ExitFailure
+			}
+			fmt.Printf("Successfully deleted the %s template '%s'.\n", template.Kind, template.Name)
+		}
+	}
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,176 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elastictemplate sync", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	var templatesFile *os.File
+
+	const Templates = `{"templates": [
+		{"name": "logstash", "body": {"template": "logstash-*", "settings": {"number_of_shards": 1}}},
+		{"name": "filebeat", "body": {"index_patterns": ["filebeat-*"], "order": 1}},
+		{"name": "metricbeat", "kind": "index", "body": {"index_patterns": ["metricbeat-*"], "composed_of": ["settings"]}},
+		{"name": "settings", "kind": "component", "body": {"template": {"settings": {"number_of_replicas": 0}}}}
+	]}`
+	const LegacyTemplates = `{
+		"logstash": {"order": 0, "index_patterns": ["logstash-*"], "settings": {"index": {"number_of_shards": "1"}}, "mappings": {}, "aliases": {}},
+		"filebeat": {"order": 0, "index_patterns": ["filebeat-*"], "settings": {}, "mappings": {}, "aliases": {}},
+		"logstash-old": {"order": 0, "index_patterns": ["logstash-old-*"], "settings": {}, "mappings": {}, "aliases": {}},
+		".monitoring-es": {"order": 0, "index_patterns": [".monitoring-es-*"], "settings": {}, "mappings": {}, "aliases": {}}
+	}`
+	const ComponentTemplates = `{"component_templates": [
+		{"name": "settings", "component_template": {"template": {"settings": {"index": {"number_of_replicas": "0"}}}}}
+	]}`
+
+	appendFetchHandlers := func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/_index_template"),
+				ghttp.RespondWith(http.StatusOK, `{"index_templates": []}`),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/_template"),
+				ghttp.RespondWith(http.StatusOK, LegacyTemplates),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/_component_template"),
+				ghttp.RespondWith(http.StatusOK, ComponentTemplates),
+			),
+		)
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		u, err := url.Parse(server.URL())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		host, port, err := net.SplitHostPort(u.Host)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		elasticHost = host
+		elasticPort, err = strconv.Atoi(port)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		templatesFile, err = ioutil.TempFile("", "templates")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = templatesFile.Write([]byte(Templates))
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.Remove(templatesFile.Name())
+		server.Close()
+	})
+
+	It("should compute the plan between the templates file and the cluster", func() {
+		appendFetchHandlers()
+
+		cfg, err := loadTemplates(templatesFile.Name())
+		Expect(err).ShouldNot(HaveOccurred())
+		templates, err := orderTemplates(cfg.Templates)
+		Expect(err).ShouldNot(HaveOccurred())
+		installed, err := fetchInstalledTemplates(elasticHost, elasticPort, "")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		plan, err := computeSyncPlan(templates, installed, []string{"logstash-*"})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(templateLabels(plan.Create)).Should(Equal([]string{"metricbeat (index)"}))
+		Expect(templateLabels(plan.Update)).Should(Equal([]string{"filebeat (legacy)"}))
+		Expect(templateLabels(plan.Unchanged)).Should(Equal([]string{"settings (component)", "logstash (legacy)"}))
+		Expect(templateLabels(plan.Extra)).Should(Equal([]string{".monitoring-es (legacy)", "logstash-old (legacy)"}))
+		Expect(templateLabels(plan.Delete)).Should(Equal([]string{"logstash-old (legacy)"}))
+	})
+
+	It("should skip the template kinds which are not supported by the cluster", func() {
+		server.AppendHandlers(
+			ghttp.RespondWith(http.StatusBadRequest, `{"error": {"type": "invalid_index_name_exception",
+				"reason": "Invalid index name [_index_template], must not start with '_'.", "index": "_index_template"}, "status": 400}`),
+			ghttp.RespondWith(http.StatusOK, LegacyTemplates),
+			ghttp.RespondWith(http.StatusBadRequest, `{"error": "no handler found for uri [/_component_template] and method [GET]", "status": 400}`),
+		)
+
+		installed, err := fetchInstalledTemplates(elasticHost, elasticPort, "")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(installed).Should(HaveLen(4))
+	})
+
+	It("should fail on the other errors of the index and component templates API", func() {
+		server.AppendHandlers(
+			ghttp.RespondWith(http.StatusBadRequest, `{"error": {"type": "illegal_argument_exception",
+				"reason": "request [/_index_template] contains unrecognized parameter"}, "status": 400}`),
+		)
+
+		_, err := fetchInstalledTemplates(elasticHost, elasticPort, "")
+
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should apply the plan without pruning", func() {
+		appendFetchHandlers()
+		server.AppendHandlers(
+			ghttp.VerifyRequest("PUT", "/_index_template/metricbeat"),
+			ghttp.VerifyRequest("PUT", "/_template/filebeat"),
+		)
+
+		cmd := &syncCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name()}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		Expect(server.ReceivedRequests()).Should(HaveLen(5))
+	})
+
+	It("should prune only the extra templates matching the patterns", func() {
+		appendFetchHandlers()
+		server.AppendHandlers(
+			ghttp.VerifyRequest("PUT", "/_index_template/metricbeat"),
+			ghttp.VerifyRequest("PUT", "/_template/filebeat"),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("DELETE", "/_template/logstash-old"),
+				ghttp.RespondWith(http.StatusOK, `{"acknowledged": true}`),
+			),
+		)
+
+		cmd := &syncCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name(), prune: true, prunePatterns: "logstash-*"}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		Expect(server.ReceivedRequests()).Should(HaveLen(6))
+	})
+
+	It("should only print the plan in dry run", func() {
+		appendFetchHandlers()
+
+		cmd := &syncCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name(), prune: true, prunePatterns: "logstash-*", dryRun: true}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		Expect(server.ReceivedRequests()).Should(HaveLen(3))
+	})
+
+	It("should require the prune patterns to prune", func() {
+		cmd := &syncCmd{host: elasticHost, port: elasticPort, templatesFile: templatesFile.Name(), prune: true}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+		Expect(server.ReceivedRequests()).Should(BeEmpty())
+	})
+})
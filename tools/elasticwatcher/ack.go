@@ -0,0 +1,111 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// actionAckState the acknowledgement state of a watch action
+type actionAckState struct {
+	Ack struct {
+		Timestamp string `json:"timestamp"`
+		State     string `json:"state"`
+	} `json:"ack"`
+}
+
+// ackWatch acknowledges the given actions of a watch, or all its actions when none is given
+func ackWatch(host string, port int, authFile string, watch string, actions []string) (map[string]actionAckState, error) {
+	fragment := watch + "/_ack"
+	if len(actions) > 0 {
+		fragment += "/" + strings.Join(actions, ",")
+	}
+
+	statusCode, content, err := doRequest(http.MethodPut, buildWatcherURL(host, port, fragment), authFile, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to ack the watch '%s'. Error: %v", watch, err)
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to ack the watch '%s'. Status Code: %d. Error: %s", watch, statusCode, string(content))
+	}
+
+	var resp struct {
+		Status struct {
+			Actions map[string]actionAckState `json:"actions"`
+		} `json:"status"`
+	}
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the ack response of the watch '%s'. Error: %v", watch, err)
+	}
+	return resp.Status.Actions, nil
+}
+
+type ackCmd struct {
+	host     string
+	port     int
+	authFile string
+	watches  string
+	actions  string
+}
+
+func (*ackCmd) Name() string { return "ack" }
+func (*ackCmd) Synopsis() string {
+	return "Acknowledge the actions of a list of watches from Elasicsearch Watcher"
+}
+
+func (*ackCmd) Usage() string {
+	return `ack [-host] <host name> [-port] <port> [-watches] <comma separated list of watches> [-actions] <comma separated list of action ids> [-auth-file] <path to basic auth file>
+        Acknowledge the actions of a list of watches, all the actions are acknowledged when no action id is provided
+	`
+}
+
+func (a *ackCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&a.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&a.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&a.watches, "watches", "", "Comma separated list with watches names")
+	f.StringVar(&a.actions, "actions", "", "Comma separated list with action ids (default all actions)")
+	f.StringVar(&a.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (a *ackCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	watchNames := parseWatchNames(a.watches)
+	var actions []string
+	if a.actions != "" {
+		actions = parseWatchNames(a.actions)
+	}
+
+	for _, watch := range watchNames {
+		states, err := ackWatch(a.host, a.port, a.authFile, watch, actions)
+		if err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+
+		fmt.Printf("ack watch '%s':\n", watch)
+		for _, action := range sortedActionIDs(states) {
+			state := states[action]
+			fmt.Printf("  %s: %s (%s)\n", action, state.Ack.State, state.Ack.Timestamp)
+		}
+	}
+
+	return subcommands.ExitSuccess
+}
+
+func sortedActionIDs(states map[string]actionAckState) []string {
+	ids := make(map[string]interface{}, len(states))
+	for id := range states {
+		ids[id] = nil
+	}
+	return sortedKeys(ids)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher ack", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	const watcherEndpoint = "/_xpack/watcher/watch"
+	const WatchName = "watch_test"
+	const AckResponse = `{"status": {"state": {"active": true}, "actions": {
+		"teams_webhook": {"ack": {"timestamp": "2018-03-20T10:00:00.000Z", "state": "acked"}}
+	}}}`
+	const Username = "test"
+	const Password = "test"
+	var Auth = fmt.Sprintf("{\"Username\": \"%s\", \"Password\": \"%s\"}", Username, Password)
+
+	createAuthFile := func() string {
+		authFile, err := ioutil.TempFile("", "auth")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = authFile.Write([]byte(Auth))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return authFile.Name()
+	}
+
+	Context("ack command", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			elasticHost = host
+			elasticPort, err = strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should ack all the actions", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/"+WatchName+"/_ack"),
+					ghttp.RespondWith(http.StatusOK, AckResponse),
+				),
+			)
+
+			cmd := &ackCmd{
+				host:    elasticHost,
+				port:    elasticPort,
+				watches: WatchName}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should ack the given actions with basic auth", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/"+WatchName+"/_ack/teams_webhook,email"),
+					ghttp.RespondWith(http.StatusOK, AckResponse),
+					ghttp.VerifyBasicAuth(Username, Password),
+				),
+			)
+
+			authFile := createAuthFile()
+			defer os.Remove(authFile)
+
+			cmd := &ackCmd{
+				host:     elasticHost,
+				port:     elasticPort,
+				watches:  WatchName,
+				actions:  "teams_webhook, email",
+				authFile: authFile}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should fail when the watch does not exist", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/"+WatchName+"/_ack"),
+					ghttp.RespondWith(http.StatusNotFound, `{"error": "not found"}`),
+				),
+			)
+
+			cmd := &ackCmd{
+				host:    elasticHost,
+				port:    elasticPort,
+				watches: WatchName}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitFailure))
+		})
+	})
+})
@@ -0,0 +1,403 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/subcommands"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// blueprintParams the parameters of a watch generated from a blueprint
+type blueprintParams struct {
+	Name           string      `yaml:"name"`
+	Blueprint      string      `yaml:"blueprint"`
+	Indices        interface{} `yaml:"indices"`
+	Field          string      `yaml:"field"`
+	Value          interface{} `yaml:"value"`
+	Threshold      *float64    `yaml:"threshold"`
+	Aggregation    string      `yaml:"aggregation"`
+	Operator       string      `yaml:"operator"`
+	Window         string      `yaml:"window"`
+	Interval       string      `yaml:"interval"`
+	TimestampField string      `yaml:"timestamp_field"`
+	Webhook        string      `yaml:"webhook"`
+	Title          string      `yaml:"title"`
+	Runbook        string      `yaml:"runbook"`
+}
+
+// blueprintsConfig the parameters of the watches generated from blueprints
+type blueprintsConfig struct {
+	Watches []blueprintParams `yaml:"watches"`
+}
+
+// watchBlueprint a parameterized shape of watch. The build function returns the filters and the aggregations
+// of the search, the condition and the notification text of the watch.
+type watchBlueprint struct {
+	Name        string
+	Description string
+	Parameters  string
+	build       func(p blueprintParams, window string) ([]interface{}, map[string]interface{}, interface{}, string, error)
+}
+
+// blueprintAggregations the metric aggregations of the threshold blueprint
+var blueprintAggregations = []string{"avg", "max", "min", "sum"}
+
+// watchBlueprints the built-in blueprints
+var watchBlueprints = []watchBlueprint{
+	{
+		Name:        "error_count",
+		Description: "Alert when the number of events with a field value, e.g. response 404, exceeds a threshold",
+		Parameters:  "indices, field, value, threshold (default 0), window",
+		build: func(p blueprintParams, window string) ([]interface{}, map[string]interface{}, interface{}, string, error) {
+			if p.Field == "" || p.Value == nil {
+				return nil, nil, nil, "", fmt.Errorf("the field and the value are required")
+			}
+			threshold := 0.0
+			if p.Threshold != nil {
+				threshold = *p.Threshold
+			}
+			filters := []interface{}{map[string]interface{}{"match": map[string]interface{}{p.Field: p.Value}}}
+			condition := compareCondition("ctx.payload.hits.total", "gt", threshold)
+			text := fmt.Sprintf("{{ctx.payload.hits.total}} events with %s %v in the last %s", p.Field, p.Value, window)
+			return filters, nil, condition, text, nil
+		},
+	},
+	{
+		Name:        "heartbeat",
+		Description: "Alert when no data, or not more events than a threshold, is received",
+		Parameters:  "indices, field (optional, must exist in the events), threshold (default 0), window",
+		build: func(p blueprintParams, window string) ([]interface{}, map[string]interface{}, interface{}, string, error) {
+			threshold := 0.0
+			if p.Threshold != nil {
+				threshold = *p.Threshold
+			}
+			var filters []interface{}
+			if p.Field != "" {
+				filters = append(filters, map[string]interface{}{"exists": map[string]interface{}{"field": p.Field}})
+			}
+			condition := compareCondition("ctx.payload.hits.total", "lte", threshold)
+			text := fmt.Sprintf("{{ctx.payload.hits.total}} events received from %s in the last %s",
+				strings.Join(stringValues(p.Indices), ", "), window)
+			return filters, nil, condition, text, nil
+		},
+	},
+	{
+		Name:        "threshold",
+		Description: "Alert when an aggregation of a numeric field crosses a threshold",
+		Parameters:  "indices, field, threshold, aggregation (avg, max, min or sum, default avg), operator (default gt), window",
+		build: func(p blueprintParams, window string) ([]interface{}, map[string]interface{}, interface{}, string, error) {
+			if p.Field == "" || p.Threshold == nil {
+				return nil, nil, nil, "", fmt.Errorf("the field and the threshold are required")
+			}
+			aggregation := p.Aggregation
+			if aggregation == "" {
+				aggregation = "avg"
+			}
+			if !containsString(blueprintAggregations, aggregation) {
+				return nil, nil, nil, "", fmt.Errorf("unknown aggregation '%s', expected one of %s",
+					aggregation, strings.Join(blueprintAggregations, ", "))
+			}
+			operator := p.Operator
+			if operator == "" {
+				operator = "gt"
+			}
+			if !containsString(compareOperators, operator) {
+				return nil, nil, nil, "", fmt.Errorf("unknown operator '%s', expected one of %s",
+					operator, strings.Join(compareOperators, ", "))
+			}
+			aggs := map[string]interface{}{"metric": map[string]interface{}{aggregation: map[string]interface{}{"field": p.Field}}}
+			condition := compareCondition("ctx.payload.aggregations.metric.value", operator, *p.Threshold)
+			text := fmt.Sprintf("%s of %s is {{ctx.payload.aggregations.metric.value}} in the last %s (%s %v)",
+				aggregation, p.Field, window, operator, *p.Threshold)
+			return nil, aggs, condition, text, nil
+		},
+	},
+}
+
+func compareCondition(path string, operator string, value float64) map[string]interface{} {
+	return map[string]interface{}{"compare": map[string]interface{}{path: map[string]interface{}{operator: value}}}
+}
+
+func findBlueprint(name string) *watchBlueprint {
+	for i := range watchBlueprints {
+		if watchBlueprints[i].Name == name {
+			return &watchBlueprints[i]
+		}
+	}
+	return nil
+}
+
+// blueprintWebhook builds the webhook action settings. The webhook is either a URL or the name of a secret
+// holding the path of a Microsoft Teams webhook, as the watches of the chart.
+func blueprintWebhook(webhook string, body string) (map[string]interface{}, error) {
+	if strings.Contains(webhook, "://") {
+		return webhookFromURL(webhook, body)
+	}
+	return map[string]interface{}{
+		"scheme":  "https",
+		"host":    "outlook.office.com",
+		"port":    443,
+		"method":  "post",
+		"path":    fmt.Sprintf("${secret:%s}", webhook),
+		"params":  map[string]interface{}{},
+		"headers": map[string]interface{}{},
+		"body":    body,
+	}, nil
+}
+
+// generateWatch generates a watch from a blueprint
+func generateWatch(p blueprintParams) (*Watch, error) {
+	if p.Name == "" {
+		return nil, fmt.Errorf("the name of the watch is required")
+	}
+	blueprint := findBlueprint(p.Blueprint)
+	if blueprint == nil {
+		var names []string
+		for _, b := range watchBlueprints {
+			names = append(names, b.Name)
+		}
+		return nil, fmt.Errorf("unknown blueprint '%s', expected one of %s", p.Blueprint, strings.Join(names, ", "))
+	}
+	indices := stringValues(p.Indices)
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("the indices are required")
+	}
+
+	window := p.Window
+	if window == "" {
+		window = "5m"
+	}
+	if _, err := parseInterval(window); err != nil {
+		return nil, fmt.Errorf("window: %v", err)
+	}
+	interval := p.Interval
+	if interval == "" {
+		interval = window
+	}
+	if _, err := parseInterval(interval); err != nil {
+		return nil, fmt.Errorf("interval: %v", err)
+	}
+	timestampField := p.TimestampField
+	if timestampField == "" {
+		timestampField = "@timestamp"
+	}
+	title := p.Title
+	if title == "" {
+		title = p.Name
+	}
+	webhook := p.Webhook
+	if webhook == "" {
+		webhook = "teams_webhook"
+	}
+
+	filters, aggs, condition, text, err := blueprint.build(p, window)
+	if err != nil {
+		return nil, err
+	}
+	filters = append([]interface{}{map[string]interface{}{"range": map[string]interface{}{
+		timestampField: map[string]interface{}{
+			"gte": fmt.Sprintf("{{ctx.trigger.scheduled_time}}||-%s", window),
+			"lte": "{{ctx.trigger.scheduled_time}}",
+		},
+	}}}, filters...)
+	search := map[string]interface{}{"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filters}}}
+	if aggs != nil {
+		search["size"] = 0
+		search["aggs"] = aggs
+	}
+
+	action, err := blueprintWebhook(webhook, encodeJSON(map[string]interface{}{"title": title, "text": text}))
+	if err != nil {
+		return nil, fmt.Errorf("webhook: %v", err)
+	}
+	metadata := map[string]interface{}{"blueprint": blueprint.Name}
+	if p.Runbook != "" {
+		metadata["runbook"] = p.Runbook
+	}
+
+	return &Watch{
+		Name: p.Name,
+		Body: map[string]interface{}{
+			"metadata": metadata,
+			"trigger":  map[string]interface{}{"schedule": map[string]interface{}{"interval": interval}},
+			"input": map[string]interface{}{"search": map[string]interface{}{
+				"request": map[string]interface{}{"indices": indices, "body": search},
+			}},
+			"condition": condition,
+			"actions":   map[string]interface{}{"teams_webhook": map[string]interface{}{"webhook": action}},
+		},
+	}, nil
+}
+
+// loadBlueprintParams loads the parameters of the watches from a YAML file
+func loadBlueprintParams(file string) ([]blueprintParams, error) {
+	content, err := ioutil.ReadFile(file) // #nosec
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the parameters file: %v", err)
+	}
+	var cfg blueprintsConfig
+	err = yaml.Unmarshal(content, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the parameters file: %v", err)
+	}
+	for i := range cfg.Watches {
+		cfg.Watches[i].Indices = normalizeYAML(cfg.Watches[i].Indices)
+		cfg.Watches[i].Value = normalizeYAML(cfg.Watches[i].Value)
+	}
+	return cfg.Watches, nil
+}
+
+type generateCmd struct {
+	blueprint      string
+	name           string
+	indices        string
+	field          string
+	value          string
+	threshold      string
+	aggregation    string
+	operator       string
+	window         string
+	interval       string
+	timestampField string
+	webhook        string
+	title          string
+	runbook        string
+	paramsFile     string
+	outputFile     string
+	list           bool
+}
+
+func (*generateCmd) Name() string { return "generate" }
+func (*generateCmd) Synopsis() string {
+	return "Generate watches from the built-in blueprints"
+}
+
+func (*generateCmd) Usage() string {
+	return `generate [-blueprint] <blueprint> [-name] <watch name> [-indices] <index patterns> [-field] <field> [-value] <value>
+        [-threshold] <threshold> [-aggregation] <aggregation> [-operator] <operator> [-window] <window> [-interval] <interval>
+        [-webhook] <secret name or URL> [-title] <title> [-runbook] <runbook> [-params-file] <path to YAML file> [-output-file] <path to watches file> [-list]
+        Generate a watch from the flags, or the watches listed in a YAML parameters file, from the built-in blueprints
+	`
+}
+
+func (g *generateCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&g.blueprint, "blueprint", "", "Name of the blueprint, see -list")
+	f.StringVar(&g.name, "name", "", "Name of the watch")
+	f.StringVar(&g.indices, "indices", "", "Comma separated list of index patterns")
+	f.StringVar(&g.field, "field", "", "Field of the events")
+	f.StringVar(&g.value, "value", "", "Value of the field which is counted by the error_count blueprint")
+	f.StringVar(&g.threshold, "threshold", "", "Threshold of the condition")
+	f.StringVar(&g.aggregation, "aggregation", "", "Aggregation of the threshold blueprint: avg, max, min or sum")
+	f.StringVar(&g.operator, "operator", "", "Comparison operator of the threshold blueprint: eq, not_eq, lt, lte, gt or gte")
+	f.StringVar(&g.window, "window", "", "Time window of the search, e.g. 5m (default 5m)")
+	f.StringVar(&g.interval, "interval", "", "Interval of the watch (default the window)")
+	f.StringVar(&g.timestampField, "timestamp-field", "", "Timestamp field of the events (default @timestamp)")
+	f.StringVar(&g.webhook, "webhook", "", "URL of the webhook, or name of the secret with the path of the Teams webhook (default teams_webhook)")
+	f.StringVar(&g.title, "title", "", "Title of the notification (default the watch name)")
+	f.StringVar(&g.runbook, "runbook", "", "Runbook stored in the metadata of the watch")
+	f.StringVar(&g.paramsFile, "params-file", "", "Path to a YAML file with the parameters of several watches")
+	f.StringVar(&g.outputFile, "output-file", "", "Path to the watches file (default standard output)")
+	f.BoolVar(&g.list, "list", false, "List the blueprints and their parameters")
+}
+
+// flagParams builds the parameters of a single watch from the flags
+func (g *generateCmd) flagParams() (blueprintParams, error) {
+	p := blueprintParams{
+		Name:           g.name,
+		Blueprint:      g.blueprint,
+		Field:          g.field,
+		Aggregation:    g.aggregation,
+		Operator:       g.operator,
+		Window:         g.window,
+		Interval:       g.interval,
+		TimestampField: g.timestampField,
+		Webhook:        g.webhook,
+		Title:          g.title,
+		Runbook:        g.runbook,
+	}
+	if g.indices != "" {
+		p.Indices = g.indices
+	}
+	if g.value != "" {
+		p.Value = g.value
+		if n, err := strconv.ParseFloat(g.value, 64); err == nil {
+			p.Value = n
+		}
+	}
+	if g.threshold != "" {
+		threshold, err := strconv.ParseFloat(g.threshold, 64)
+		if err != nil {
+			return p, fmt.Errorf("Invalid threshold '%s'", g.threshold)
+		}
+		p.Threshold = &threshold
+	}
+	return p, nil
+}
+
+func (g *generateCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if g.list {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BLUEPRINT\tDESCRIPTION\tPARAMETERS")
+		for _, b := range watchBlueprints {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", b.Name, b.Description, b.Parameters)
+		}
+		w.Flush()
+		return subcommands.ExitSuccess
+	}
+
+	var params []blueprintParams
+	if g.paramsFile != "" {
+		var err error
+		params, err = loadBlueprintParams(g.paramsFile)
+		if err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+	} else {
+		p, err := g.flagParams()
+		if err != nil {
+			fmt.Println(err)
+			return subcommands.ExitUsageError
+		}
+		params = append(params, p)
+	}
+
+	cfg := WatcherConfig{Watches: []Watch{}}
+	for _, p := range params {
+		watch, err := generateWatch(p)
+		if err != nil {
+			fmt.Printf("Failed to generate the watch '%s'. Error: %v\n", p.Name, err)
+			return subcommands.ExitFailure
+		}
+		cfg.Watches = append(cfg.Watches, *watch)
+	}
+
+	content, err := marshalIndent(cfg)
+	if err != nil {
+		fmt.Printf("Failed to encode the watches. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	if g.outputFile == "" {
+		fmt.Println(string(content))
+		return subcommands.ExitSuccess
+	}
+	err = ioutil.WriteFile(g.outputFile, append(content, '\n'), 0600)
+	if err != nil {
+		fmt.Printf("Failed to write the watches file '%s'. Error: %v\n", g.outputFile, err)
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("Generated %d watches into '%s'.\n", len(cfg.Watches), g.outputFile)
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,188 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher blueprints", func() {
+	threshold := func(value float64) *float64 { return &value }
+
+	generate := func(p blueprintParams) map[string]interface{} {
+		watch, err := generateWatch(p)
+		Expect(err).ShouldNot(HaveOccurred())
+		body, err := normalizeWatchBody(watch.Body)
+		Expect(err).ShouldNot(HaveOccurred())
+		return body
+	}
+
+	It("should generate an error count watch like the chart watches", func() {
+		body := generate(blueprintParams{
+			Name:      "watch_http_404_error",
+			Blueprint: "error_count",
+			Indices:   "dev-logstash-*",
+			Field:     "response",
+			Value:     404,
+			Window:    "15m",
+			Runbook:   "Check the ingress logs",
+		})
+
+		Expect(encodeJSON(body)).Should(MatchJSON(`{
+			"metadata": {"blueprint": "error_count", "runbook": "Check the ingress logs"},
+			"trigger": {"schedule": {"interval": "15m"}},
+			"input": {"search": {"request": {
+				"indices": ["dev-logstash-*"],
+				"body": {"query": {"bool": {"filter": [
+					{"range": {"@timestamp": {"gte": "{{ctx.trigger.scheduled_time}}||-15m", "lte": "{{ctx.trigger.scheduled_time}}"}}},
+					{"match": {"response": 404}}
+				]}}}
+			}}},
+			"condition": {"compare": {"ctx.payload.hits.total": {"gt": 0}}},
+			"actions": {"teams_webhook": {"webhook": {
+				"scheme": "https",
+				"host": "outlook.office.com",
+				"port": 443,
+				"method": "post",
+				"path": "${secret:teams_webhook}",
+				"params": {},
+				"headers": {},
+				"body": "{\"text\":\"{{ctx.payload.hits.total}} events with response 404 in the last 15m\",\"title\":\"watch_http_404_error\"}"
+			}}}
+		}`))
+	})
+
+	It("should generate a heartbeat watch", func() {
+		body := generate(blueprintParams{
+			Name:      "watch_no_logs",
+			Blueprint: "heartbeat",
+			Indices:   []interface{}{"dev-logstash-*", "dev-filebeat-*"},
+			Field:     "kubernetes.pod",
+			Interval:  "1m",
+			Webhook:   "https://hooks.example.com:8443/alerts",
+		})
+
+		Expect(body["condition"]).Should(Equal(map[string]interface{}{
+			"compare": map[string]interface{}{"ctx.payload.hits.total": map[string]interface{}{"lte": 0.0}},
+		}))
+		Expect(encodeJSON(body["input"])).Should(ContainSubstring(`{"exists":{"field":"kubernetes.pod"}}`))
+		Expect(encodeJSON(body["trigger"])).Should(MatchJSON(`{"schedule": {"interval": "1m"}}`))
+		webhook := body["actions"].(map[string]interface{})["teams_webhook"].(map[string]interface{})["webhook"].(map[string]interface{})
+		Expect(webhook["host"]).Should(Equal("hooks.example.com"))
+		Expect(webhook["port"]).Should(Equal(8443.0))
+		Expect(webhook["path"]).Should(Equal("/alerts"))
+	})
+
+	It("should generate a threshold watch", func() {
+		body := generate(blueprintParams{
+			Name:        "watch_slow_requests",
+			Blueprint:   "threshold",
+			Indices:     "dev-logstash-*",
+			Field:       "request_time",
+			Aggregation: "max",
+			Operator:    "gte",
+			Threshold:   threshold(2.5),
+		})
+
+		Expect(body["condition"]).Should(Equal(map[string]interface{}{
+			"compare": map[string]interface{}{"ctx.payload.aggregations.metric.value": map[string]interface{}{"gte": 2.5}},
+		}))
+		Expect(encodeJSON(body["input"])).Should(ContainSubstring(`"aggs":{"metric":{"max":{"field":"request_time"}}}`))
+		Expect(encodeJSON(body["input"])).Should(ContainSubstring(`"size":0`))
+	})
+
+	It("should reject the invalid parameters", func() {
+		for _, p := range []blueprintParams{
+			{Blueprint: "heartbeat", Indices: "logs-*"},
+			{Name: "watch", Blueprint: "unknown", Indices: "logs-*"},
+			{Name: "watch", Blueprint: "heartbeat"},
+			{Name: "watch", Blueprint: "heartbeat", Indices: "logs-*", Window: "5x"},
+			{Name: "watch", Blueprint: "error_count", Indices: "logs-*", Field: "response"},
+			{Name: "watch", Blueprint: "threshold", Indices: "logs-*", Field: "cpu"},
+			{Name: "watch", Blueprint: "threshold", Indices: "logs-*", Field: "cpu", Threshold: threshold(1), Aggregation: "median"},
+			{Name: "watch", Blueprint: "threshold", Indices: "logs-*", Field: "cpu", Threshold: threshold(1), Operator: "between"},
+		} {
+			_, err := generateWatch(p)
+
+			Expect(err).Should(HaveOccurred(), p.Name+" "+p.Blueprint)
+		}
+	})
+
+	Context("generate command", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "blueprints")
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should generate the watches of a parameters file", func() {
+			paramsFile := filepath.Join(dir, "params.yaml")
+			Expect(ioutil.WriteFile(paramsFile, []byte(`
+watches:
+- name: watch_http_500_error
+  blueprint: error_count
+  indices: [dev-logstash-*]
+  field: response
+  value: 500
+- name: watch_cpu
+  blueprint: threshold
+  indices: dev-metricbeat-*
+  field: system.cpu.total.pct
+  threshold: 0.9
+`), 0644)).Should(Succeed())
+			outputFile := filepath.Join(dir, "watches.json")
+			cmd := &generateCmd{paramsFile: paramsFile, outputFile: outputFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+
+			cfg, err := loadWatches(outputFile)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(cfg.Watches).Should(HaveLen(2))
+			Expect(validateWatches(cfg)).Should(BeEmpty())
+			Expect(encodeJSON(cfg.Watches[0].Body)).Should(ContainSubstring(`{"match":{"response":500}}`))
+		})
+
+		It("should generate a watch from the flags", func() {
+			cmd := &generateCmd{blueprint: "error_count", name: "watch_http_404_error", indices: "dev-logstash-*", field: "response", value: "404"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+
+			p, err := cmd.flagParams()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(p.Value).Should(Equal(404.0))
+		})
+
+		It("should fail on invalid parameters", func() {
+			cmd := &generateCmd{blueprint: "threshold", name: "watch_cpu", indices: "dev-metricbeat-*", field: "cpu"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+
+		It("should reject an invalid threshold", func() {
+			cmd := &generateCmd{blueprint: "threshold", threshold: "high"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+		})
+
+		It("should list the blueprints", func() {
+			cmd := &generateCmd{list: true}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		})
+	})
+})
@@ -0,0 +1,235 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// compareOperators the operators of the compare and array_compare conditions
+var compareOperators = []string{"eq", "not_eq", "lt", "lte", "gt", "gte"}
+
+// compareValues compares two JSON values leniently as Watcher does: a number is compared as a number with
+// another number or a numeric string, while two strings are compared lexicographically, even when they are
+// numeric. It returns false if the values cannot be ordered, such as booleans or null.
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	toNumber := func(value interface{}) (float64, bool) {
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return n, err == nil
+		}
+		return 0, false
+	}
+
+	_, numberA := a.(float64)
+	_, numberB := b.(float64)
+	if x, ok := toNumber(a); ok && (numberA || numberB) {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	x, okA := a.(string)
+	y, okB := b.(string)
+	if okA && okB {
+		return strings.Compare(x, y), true
+	}
+	if a == nil && b == nil {
+		return 0, true
+	}
+	if bx, ok := a.(bool); ok {
+		if by, ok := b.(bool); ok && bx == by {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// evaluateOperator applies a compare operator to a resolved value and the expected value
+func evaluateOperator(operator string, value interface{}, expected interface{}) (bool, error) {
+	result, comparable := compareValues(value, expected)
+	switch operator {
+	case "eq":
+		return comparable && result == 0, nil
+	case "not_eq":
+		return !comparable || result != 0, nil
+	case "lt":
+		return comparable && result < 0, nil
+	case "lte":
+		return comparable && result <= 0, nil
+	case "gt":
+		return comparable && result > 0, nil
+	case "gte":
+		return comparable && result >= 0, nil
+	}
+	return false, fmt.Errorf("unknown operator '%s', expected one of %s", operator, strings.Join(compareOperators, ", "))
+}
+
+// resolveCompareValue resolves the expected value of a comparison, which can be a mustache template
+func resolveCompareValue(value interface{}, context map[string]interface{}) (interface{}, error) {
+	text, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	if strings.HasPrefix(text, "<{") && strings.HasSuffix(text, "}>") {
+		return nil, fmt.Errorf("date math value '%s' is not supported", text)
+	}
+	if !strings.Contains(text, "{{") {
+		return value, nil
+	}
+	renderer := &mustacheRenderer{}
+	rendered, err := renderer.Render(text, context)
+	if err != nil {
+		return nil, err
+	}
+	if len(renderer.Unresolved) > 0 {
+		return nil, fmt.Errorf("unresolved reference '%s'", renderer.Unresolved[0])
+	}
+	return rendered, nil
+}
+
+// singleEntry returns the single key and value of an object, such as the path and the operators of a comparison
+func singleEntry(value interface{}, what string) (string, map[string]interface{}, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", nil, fmt.Errorf("%s must define exactly one entry", what)
+	}
+	for key, item := range m {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return "", nil, fmt.Errorf("%s '%s' must be an object", what, key)
+		}
+		return key, entry, nil
+	}
+	return "", nil, nil
+}
+
+// evaluateCompare evaluates a compare condition, e.g. {"ctx.payload.hits.total": {"gt": 0}}
+func evaluateCompare(condition interface{}, context map[string]interface{}) (bool, error) {
+	path, operators, err := singleEntry(condition, "compare condition")
+	if err != nil {
+		return false, err
+	}
+	if len(operators) != 1 {
+		return false, fmt.Errorf("compare condition on '%s' must define exactly one operator", path)
+	}
+	value, _ := lookupMustache(path, []interface{}{context})
+	for operator, expected := range operators {
+		resolved, err := resolveCompareValue(expected, context)
+		if err != nil {
+			return false, err
+		}
+		return evaluateOperator(operator, value, resolved)
+	}
+	return false, nil
+}
+
+// evaluateArrayCompare evaluates an array_compare condition, e.g.
+// {"ctx.payload.aggregations.hosts.buckets": {"path": "doc_count", "gte": {"value": 25, "quantifier": "some"}}}
+func evaluateArrayCompare(condition interface{}, context map[string]interface{}) (bool, error) {
+	arrayPath, entry, err := singleEntry(condition, "array_compare condition")
+	if err != nil {
+		return false, err
+	}
+
+	itemPath, _ := entry["path"].(string)
+	var operator string
+	var comparison map[string]interface{}
+	for key, value := range entry {
+		if key == "path" {
+			continue
+		}
+		m, ok := value.(map[string]interface{})
+		if !ok || operator != "" {
+			return false, fmt.Errorf("array_compare condition on '%s' must define exactly one operator", arrayPath)
+		}
+		operator, comparison = key, m
+	}
+	if operator == "" {
+		return false, fmt.Errorf("array_compare condition on '%s' must define exactly one operator", arrayPath)
+	}
+
+	expected, err := resolveCompareValue(comparison["value"], context)
+	if err != nil {
+		return false, err
+	}
+	quantifier, _ := comparison["quantifier"].(string)
+	if quantifier == "" {
+		quantifier = "some"
+	}
+	if quantifier != "some" && quantifier != "all" {
+		return false, fmt.Errorf("unknown quantifier '%s', expected some or all", quantifier)
+	}
+
+	array, _ := lookupMustache(arrayPath, []interface{}{context})
+	items, ok := array.([]interface{})
+	if !ok {
+		return false, nil
+	}
+	for _, item := range items {
+		value := item
+		if itemPath != "" {
+			value, _ = lookupMustachePath(item, strings.Split(itemPath, "."))
+		}
+		met, err := evaluateOperator(operator, value, expected)
+		if err != nil {
+			return false, err
+		}
+		if met && quantifier == "some" {
+			return true, nil
+		}
+		if !met && quantifier == "all" {
+			return false, nil
+		}
+	}
+	// The 'all' quantifier is met by an empty array, as in Watcher
+	return quantifier == "all", nil
+}
+
+// evaluateCondition evaluates a watch or action condition against the simulated context.
+// A missing condition is always met.
+func evaluateCondition(condition interface{}, context map[string]interface{}) (bool, error) {
+	if condition == nil {
+		return true, nil
+	}
+	conditionType, value, err := singleConditionEntry(condition)
+	if err != nil {
+		return false, err
+	}
+	switch conditionType {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "compare":
+		return evaluateCompare(value, context)
+	case "array_compare":
+		return evaluateArrayCompare(value, context)
+	}
+	return false, fmt.Errorf("condition '%s' cannot be evaluated offline", conditionType)
+}
+
+func singleConditionEntry(condition interface{}) (string, interface{}, error) {
+	m, ok := condition.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", nil, fmt.Errorf("condition must define exactly one condition type")
+	}
+	for key, value := range m {
+		return key, value, nil
+	}
+	return "", nil, nil
+}
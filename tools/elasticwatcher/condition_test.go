@@ -0,0 +1,94 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher condition evaluation", func() {
+	var context map[string]interface{}
+
+	BeforeEach(func() {
+		Expect(json.Unmarshal([]byte(`{"ctx": {
+			"metadata": {"threshold": 10},
+			"payload": {
+				"hits": {"total": 12},
+				"status": "red",
+				"aggregations": {"hosts": {"buckets": [
+					{"key": "web-1", "doc_count": 30},
+					{"key": "web-2", "doc_count": 5}
+				]}}
+			}
+		}}`), &context)).Should(Succeed())
+	})
+
+	evaluate := func(condition string) (bool, error) {
+		var value interface{}
+		Expect(json.Unmarshal([]byte(condition), &value)).Should(Succeed())
+		return evaluateCondition(value, context)
+	}
+
+	It("should evaluate the conditions", func() {
+		for condition, expected := range map[string]bool{
+			`{"always": {}}`: true,
+			`{"never": {}}`:  false,
+			`{"compare": {"ctx.payload.hits.total": {"gt": 0}}}`:                                                                              true,
+			`{"compare": {"ctx.payload.hits.total": {"lte": 11}}}`:                                                                            false,
+			`{"compare": {"ctx.payload.hits.total": {"gte": "{{ctx.metadata.threshold}}"}}}`:                                                  true,
+			`{"compare": {"ctx.payload.status": {"eq": "red"}}}`:                                                                              true,
+			`{"compare": {"ctx.payload.status": {"not_eq": "green"}}}`:                                                                        true,
+			`{"compare": {"ctx.payload.missing": {"eq": 0}}}`:                                                                                 false,
+			`{"compare": {"ctx.payload.missing": {"not_eq": 0}}}`:                                                                             true,
+			`{"array_compare": {"ctx.payload.aggregations.hosts.buckets": {"path": "doc_count", "gte": {"value": 25}}}}`:                      true,
+			`{"array_compare": {"ctx.payload.aggregations.hosts.buckets": {"path": "doc_count", "gte": {"value": 25, "quantifier": "all"}}}}`: false,
+			`{"array_compare": {"ctx.payload.aggregations.hosts.buckets": {"path": "key", "eq": {"value": "web-2"}}}}`:                        true,
+			`{"array_compare": {"ctx.payload.missing": {"path": "doc_count", "gt": {"value": 0}}}}`:                                           false,
+		} {
+			met, err := evaluate(condition)
+
+			Expect(err).ShouldNot(HaveOccurred(), condition)
+			Expect(met).Should(Equal(expected), condition)
+		}
+	})
+
+	It("should compare two numeric strings lexicographically", func() {
+		context["ctx"].(map[string]interface{})["payload"].(map[string]interface{})["version"] = "10"
+
+		met, err := evaluate(`{"compare": {"ctx.payload.version": {"gt": "9"}}}`)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(met).Should(BeFalse())
+
+		met, err = evaluate(`{"compare": {"ctx.payload.hits.total": {"gt": "9"}}}`)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(met).Should(BeTrue())
+	})
+
+	It("should meet a missing condition", func() {
+		met, err := evaluateCondition(nil, context)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(met).Should(BeTrue())
+	})
+
+	It("should reject the conditions which cannot be evaluated offline", func() {
+		for _, condition := range []string{
+			`{"script": {"source": "return true"}}`,
+			`{"compare": {"ctx.payload.hits.total": {"between": 0}}}`,
+			`{"compare": {"ctx.payload.hits.total": {"gt": 0, "lt": 5}}}`,
+			`{"compare": {"ctx.execution_time": {"gte": "<{now-5m}>"}}}`,
+			`{"array_compare": {"ctx.payload.aggregations.hosts.buckets": {"path": "doc_count", "gt": {"value": 0, "quantifier": "most"}}}}`,
+			`{"always": {}, "never": {}}`,
+		} {
+			_, err := evaluate(condition)
+
+			Expect(err).Should(HaveOccurred(), condition)
+		}
+	})
+})
@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// createResult outcome of the creation of a watch
+type createResult struct {
+	Watch      string
+	StatusCode int
+	Err        error
+	// Skipped indicates that the watch was not sent since an earlier watch failed
+	Skipped bool
+}
+
+// createWatches creates the watches with at most concurrency requests in flight sharing a single HTTP client.
+// Unless continueOnError is set, no new watch is sent after the first failure, while the requests
+// in flight are completed. The results are returned in the order of the watches.
+func createWatches(host string, port int, authFile string, watches []Watch, concurrency int, continueOnError bool) []createResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]createResult, len(watches))
+	for i, watch := range watches {
+		results[i] = createResult{Watch: watch.Name, Skipped: true}
+	}
+
+	client := buildHTTPClient()
+	// slots bounds the number of requests in flight, a slot is released once the result is recorded
+	slots := make(chan struct{}, concurrency)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	failed := false
+
+	for i := range watches {
+		slots <- struct{}{}
+		mutex.Lock()
+		stop := failed && !continueOnError
+		mutex.Unlock()
+		if stop {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result := createResult{Watch: watches[i].Name}
+			err := putWatch(client, host, port, authFile, watches[i])
+			if err != nil {
+				result.Err = err
+				if reqErr, ok := err.(*watchRequestError); ok {
+					result.StatusCode = reqErr.StatusCode
+				}
+			}
+
+			mutex.Lock()
+			results[i] = result
+			if err != nil {
+				failed = true
+			}
+			mutex.Unlock()
+			<-slots
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// printCreateReport prints the outcome of each watch and returns true if all watches were created
+func printCreateReport(results []createResult) bool {
+	var succeeded, failed, skipped int
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WATCH\tRESULT\tSTATUS CODE\tERROR")
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			skipped++
+			fmt.Fprintf(w, "%s\tskipped\t-\t-\n", result.Watch)
+		case result.Err != nil:
+			failed++
+			message := result.Err.Error()
+			if reqErr, ok := result.Err.(*watchRequestError); ok {
+				message = reqErr.Message
+			}
+			fmt.Fprintf(w, "%s\tfailed\t%s\t%s\n", result.Watch, formatOptional(statusCodeText(result.StatusCode)),
+				strings.Replace(strings.TrimSpace(message), "\n", " ", -1))
+		default:
+			succeeded++
+			fmt.Fprintf(w, "%s\tcreated/updated\t-\t-\n", result.Watch)
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("Succeeded: %d, failed: %d, skipped: %d\n", succeeded, failed, skipped)
+	if skipped > 0 {
+		fmt.Println("Use -continue-on-error to create the remaining watches when a watch fails.")
+	}
+	return failed == 0 && skipped == 0
+}
+
+func statusCodeText(statusCode int) string {
+	if statusCode == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", statusCode)
+}
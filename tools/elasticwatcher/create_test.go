@@ -0,0 +1,104 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher parallel create", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	var watchesFile *os.File
+	const watcherEndpoint = "/_xpack/watcher/watch"
+	const Watches = `{"watches": [
+		{"name": "watch_a", "body": {"trigger": {"schedule": {"interval": "5m"}}}},
+		{"name": "watch_bad", "body": {"trigger": {"schedule": {"interval": "x"}}}},
+		{"name": "watch_c", "body": {"trigger": {"schedule": {"interval": "15m"}}}}
+	]}`
+	const ErrorResponse = `{"error": {"type": "parse_exception"}}`
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		u, err := url.Parse(server.URL())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		host, port, err := net.SplitHostPort(u.Host)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		elasticHost = host
+		elasticPort, err = strconv.Atoi(port)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		watchesFile, err = ioutil.TempFile("", "watches")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = watchesFile.Write([]byte(Watches))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		server.RouteToHandler("PUT", watcherEndpoint+"/watch_a", ghttp.RespondWith(http.StatusCreated, "{}"))
+		server.RouteToHandler("PUT", watcherEndpoint+"/watch_bad", ghttp.RespondWith(http.StatusBadRequest, ErrorResponse))
+		server.RouteToHandler("PUT", watcherEndpoint+"/watch_c", ghttp.RespondWith(http.StatusOK, "{}"))
+	})
+
+	AfterEach(func() {
+		if watchesFile != nil {
+			os.Remove(watchesFile.Name())
+		}
+		server.Close()
+	})
+
+	It("should stop at the first failure by default", func() {
+		cmd := &createCmd{
+			host:        elasticHost,
+			port:        elasticPort,
+			watchesFile: watchesFile.Name(),
+			concurrency: 1}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+	})
+
+	It("should create the remaining watches in parallel with continue on error", func() {
+		cmd := &createCmd{
+			host:            elasticHost,
+			port:            elasticPort,
+			watchesFile:     watchesFile.Name(),
+			concurrency:     3,
+			continueOnError: true}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		Expect(server.ReceivedRequests()).Should(HaveLen(3))
+	})
+
+	It("should report the status code and the error of the failed watches", func() {
+		cfg, err := loadWatches(watchesFile.Name())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		results := createWatches(elasticHost, elasticPort, "", cfg.Watches, 2, true)
+
+		Expect(results).Should(HaveLen(3))
+		Expect(results[0].Watch).Should(Equal("watch_a"))
+		Expect(results[0].Err).ShouldNot(HaveOccurred())
+		Expect(results[1].Watch).Should(Equal("watch_bad"))
+		Expect(results[1].StatusCode).Should(Equal(http.StatusBadRequest))
+		Expect(results[1].Err.(*watchRequestError).Message).Should(Equal(ErrorResponse))
+		Expect(results[2].Err).ShouldNot(HaveOccurred())
+		Expect(printCreateReport(results)).Should(BeFalse())
+	})
+})
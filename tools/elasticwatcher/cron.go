@@ -0,0 +1,515 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField describes the bounds of a field in a Quartz cron expression
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronSeconds     = cronField{name: "seconds", min: 0, max: 59}
+	cronMinutes     = cronField{name: "minutes", min: 0, max: 59}
+	cronHours       = cronField{name: "hours", min: 0, max: 23}
+	cronDaysOfMonth = cronField{name: "day of month", min: 1, max: 31}
+	cronMonths      = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	cronDaysOfWeek = cronField{name: "day of week", min: 1, max: 7, names: map[string]int{
+		"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7,
+	}}
+	cronYears = cronField{name: "year", min: 1970, max: 2099}
+)
+
+var (
+	monthNames   = []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	weekdayNames = []string{"", "Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+)
+
+// cronSchedule a parsed Quartz cron expression as used by Watcher:
+// <seconds> <minutes> <hours> <day of month> <month> <day of week> [year]
+type cronSchedule struct {
+	expression string
+
+	seconds []bool
+	minutes []bool
+	hours   []bool
+	months  []bool
+	// years is nil when the expression has no year field
+	years map[int]bool
+
+	// anyDayOfMonth is set when the day of month is '?'
+	anyDayOfMonth bool
+	daysOfMonth   []bool
+	// lastDayOfMonth is set for 'L', with lastDayOffset days before the end for 'L-n'
+	lastDayOfMonth bool
+	lastDayOffset  int
+	// nearestWeekday is set for 'W', meaning the weekday closest to the day(s) of month
+	nearestWeekday bool
+
+	// anyDayOfWeek is set when the day of week is '?'
+	anyDayOfWeek bool
+	daysOfWeek   []bool
+	// lastDayOfWeek is the day (1-7) of the 'nL' expression, the last such day of the month
+	lastDayOfWeek int
+	// nthDayOfWeek and nthWeek hold the 'd#n' expression, the n-th day d of the month
+	nthDayOfWeek int
+	nthWeek      int
+}
+
+func (f cronField) parseValue(value string) (int, error) {
+	if n, ok := f.names[strings.ToUpper(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value '%s'", f.name, value)
+	}
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s value %d out of range [%d-%d]", f.name, n, f.min, f.max)
+	}
+	return n, nil
+}
+
+// parseList parses a comma separated list of values, ranges and increments into a set indexed by value
+func (f cronField) parseList(spec string) ([]bool, error) {
+	set := make([]bool, f.max+1)
+	for _, part := range strings.Split(spec, ",") {
+		if part == "" {
+			return nil, fmt.Errorf("empty %s value in '%s'", f.name, spec)
+		}
+
+		base, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			base = part[:i]
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid %s increment '%s'", f.name, part[i+1:])
+			}
+			step = n
+		}
+
+		var from, to int
+		switch {
+		case base == "*":
+			from, to = f.min, f.max
+		case strings.Contains(base, "-"):
+			bounds := strings.SplitN(base, "-", 2)
+			var err error
+			if from, err = f.parseValue(bounds[0]); err != nil {
+				return nil, err
+			}
+			if to, err = f.parseValue(bounds[1]); err != nil {
+				return nil, err
+			}
+		default:
+			var err error
+			if from, err = f.parseValue(base); err != nil {
+				return nil, err
+			}
+			to = from
+			// A single value with an increment, such as 5/15, starts at the value and goes up to the maximum
+			if strings.Contains(part, "/") {
+				to = f.max
+			}
+		}
+
+		// Ranges such as FRI-MON or 22-2 wrap around the maximum value
+		span := to - from
+		if span < 0 {
+			span += f.max - f.min + 1
+		}
+		for i := 0; i <= span; i += step {
+			value := from + i
+			if value > f.max {
+				value -= f.max - f.min + 1
+			}
+			set[value] = true
+		}
+	}
+	return set, nil
+}
+
+func (c *cronSchedule) parseDayOfMonth(spec string) error {
+	switch {
+	case spec == "?":
+		c.anyDayOfMonth = true
+		return nil
+	case spec == "L":
+		c.lastDayOfMonth = true
+		return nil
+	case spec == "LW":
+		c.lastDayOfMonth = true
+		c.nearestWeekday = true
+		return nil
+	case strings.HasPrefix(spec, "L-"):
+		n, err := strconv.Atoi(spec[2:])
+		if err != nil || n < 0 || n > 30 {
+			return fmt.Errorf("invalid day of month offset '%s'", spec)
+		}
+		c.lastDayOfMonth = true
+		c.lastDayOffset = n
+		return nil
+	case strings.HasSuffix(spec, "W"):
+		day, err := cronDaysOfMonth.parseValue(strings.TrimSuffix(spec, "W"))
+		if err != nil {
+			return err
+		}
+		c.daysOfMonth = make([]bool, cronDaysOfMonth.max+1)
+		c.daysOfMonth[day] = true
+		c.nearestWeekday = true
+		return nil
+	}
+
+	// The last day can be listed with other days, e.g. '15,L' as generated for the Watcher monthly schedules
+	var days []string
+	for _, day := range strings.Split(spec, ",") {
+		if day == "L" {
+			c.lastDayOfMonth = true
+		} else {
+			days = append(days, day)
+		}
+	}
+	set, err := cronDaysOfMonth.parseList(strings.Join(days, ","))
+	if err != nil {
+		return err
+	}
+	c.daysOfMonth = set
+	return nil
+}
+
+func (c *cronSchedule) parseDayOfWeek(spec string) error {
+	switch {
+	case spec == "?":
+		c.anyDayOfWeek = true
+		return nil
+	case spec == "L":
+		c.daysOfWeek = make([]bool, cronDaysOfWeek.max+1)
+		c.daysOfWeek[7] = true
+		return nil
+	case strings.HasSuffix(spec, "L"):
+		day, err := cronDaysOfWeek.parseValue(strings.TrimSuffix(spec, "L"))
+		if err != nil {
+			return err
+		}
+		c.lastDayOfWeek = day
+		return nil
+	case strings.Contains(spec, "#"):
+		parts := strings.SplitN(spec, "#", 2)
+		day, err := cronDaysOfWeek.parseValue(parts[0])
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 1 || n > 5 {
+			return fmt.Errorf("invalid day of week occurrence '%s'", spec)
+		}
+		c.nthDayOfWeek = day
+		c.nthWeek = n
+		return nil
+	}
+
+	set, err := cronDaysOfWeek.parseList(spec)
+	if err != nil {
+		return err
+	}
+	c.daysOfWeek = set
+	return nil
+}
+
+// parseCron parses a Quartz cron expression
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 6 && len(fields) != 7 {
+		return nil, fmt.Errorf("expected 6 or 7 fields in cron expression '%s', found %d", expression, len(fields))
+	}
+
+	c := &cronSchedule{expression: expression}
+	var err error
+	if c.seconds, err = cronSeconds.parseList(fields[0]); err != nil {
+		return nil, err
+	}
+	if c.minutes, err = cronMinutes.parseList(fields[1]); err != nil {
+		return nil, err
+	}
+	if c.hours, err = cronHours.parseList(fields[2]); err != nil {
+		return nil, err
+	}
+	if err = c.parseDayOfMonth(fields[3]); err != nil {
+		return nil, err
+	}
+	if c.months, err = cronMonths.parseList(fields[4]); err != nil {
+		return nil, err
+	}
+	if err = c.parseDayOfWeek(fields[5]); err != nil {
+		return nil, err
+	}
+	if len(fields) == 7 {
+		set, err := cronYears.parseList(fields[6])
+		if err != nil {
+			return nil, err
+		}
+		c.years = make(map[int]bool)
+		for year, ok := range set {
+			if ok {
+				c.years[year] = true
+			}
+		}
+	}
+
+	if c.anyDayOfMonth == c.anyDayOfWeek {
+		return nil, fmt.Errorf("exactly one of day of month and day of week must be '?' in cron expression '%s'", expression)
+	}
+	return c, nil
+}
+
+// lastWeekdayOfMonth returns the last day of the month which is not a Saturday or a Sunday
+func lastWeekdayOfMonth(year int, month time.Month) int {
+	day := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day.Day()
+}
+
+// nearestWeekday returns the weekday closest to the given day without leaving the month, as defined by Quartz
+func nearestWeekday(year int, month time.Month, day int, lastDay int) int {
+	switch time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == lastDay {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+// matchesDay indicates if the expression fires on the given day, the month and the year are checked separately
+func (c *cronSchedule) matchesDay(day time.Time) bool {
+	year, month, dayOfMonth := day.Date()
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	weekday := int(day.Weekday()) + 1
+
+	if !c.anyDayOfMonth {
+		switch {
+		case c.lastDayOfMonth && c.nearestWeekday:
+			return dayOfMonth == lastWeekdayOfMonth(year, month)
+		case c.lastDayOfMonth:
+			return dayOfMonth == lastDay-c.lastDayOffset || (c.daysOfMonth != nil && c.daysOfMonth[dayOfMonth])
+		case c.nearestWeekday:
+			for target, ok := range c.daysOfMonth {
+				if ok {
+					return target <= lastDay && dayOfMonth == nearestWeekday(year, month, target, lastDay)
+				}
+			}
+			return false
+		}
+		return c.daysOfMonth[dayOfMonth]
+	}
+
+	switch {
+	case c.lastDayOfWeek != 0:
+		return weekday == c.lastDayOfWeek && dayOfMonth+7 > lastDay
+	case c.nthDayOfWeek != 0:
+		return weekday == c.nthDayOfWeek && (dayOfMonth-1)/7+1 == c.nthWeek
+	}
+	return c.daysOfWeek[weekday]
+}
+
+// firstTimeOfDay returns the first fire time of the day which is not before start
+func (c *cronSchedule) firstTimeOfDay(day time.Time, start time.Time) (time.Time, bool) {
+	year, month, dayOfMonth := day.Date()
+	startYear, startMonth, startDay := start.Date()
+	sameDay := year == startYear && month == startMonth && dayOfMonth == startDay
+
+	for hour := 0; hour <= cronHours.max; hour++ {
+		if !c.hours[hour] || (sameDay && hour < start.Hour()) {
+			continue
+		}
+		for minute := 0; minute <= cronMinutes.max; minute++ {
+			if !c.minutes[minute] || (sameDay && hour == start.Hour() && minute < start.Minute()) {
+				continue
+			}
+			for second := 0; second <= cronSeconds.max; second++ {
+				if !c.seconds[second] {
+					continue
+				}
+				t := time.Date(year, month, dayOfMonth, hour, minute, second, 0, time.UTC)
+				if !t.Before(start) {
+					return t, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Next returns the first fire time strictly after the given time. Watcher evaluates the cron
+// expressions in UTC. It returns false when the expression does not fire anymore.
+func (c *cronSchedule) Next(after time.Time) (time.Time, bool) {
+	start := after.UTC().Truncate(time.Second).Add(time.Second)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+
+	for day.Year() <= cronYears.max {
+		if c.years != nil && !c.years[day.Year()] {
+			day = time.Date(day.Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.months[int(day.Month())] {
+			day = time.Date(day.Year(), day.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.matchesDay(day) {
+			if t, ok := c.firstTimeOfDay(day, start); ok {
+				return t, true
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, false
+}
+
+// formatCronSet formats the values of a set in a compact form, e.g. '1-5, 10'. It returns an empty string
+// when the set contains all the values of the field.
+func formatCronSet(set []bool, f cronField, label func(int) string) string {
+	var values []int
+	for value := f.min; value <= f.max; value++ {
+		if set[value] {
+			values = append(values, value)
+		}
+	}
+	if len(values) == f.max-f.min+1 {
+		return ""
+	}
+
+	var parts []string
+	for i := 0; i < len(values); {
+		j := i
+		for j+1 < len(values) && values[j+1] == values[j]+1 {
+			j++
+		}
+		if j-i >= 2 {
+			parts = append(parts, fmt.Sprintf("%s-%s", label(values[i]), label(values[j])))
+		} else {
+			for k := i; k <= j; k++ {
+				parts = append(parts, label(values[k]))
+			}
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+func ordinal(n int) string {
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+		return fmt.Sprintf("%dth", n)
+	case n%10 == 1:
+		return fmt.Sprintf("%dst", n)
+	case n%10 == 2:
+		return fmt.Sprintf("%dnd", n)
+	case n%10 == 3:
+		return fmt.Sprintf("%drd", n)
+	}
+	return fmt.Sprintf("%dth", n)
+}
+
+func singleCronValue(set []bool) (int, bool) {
+	found := -1
+	for value, ok := range set {
+		if ok {
+			if found >= 0 {
+				return 0, false
+			}
+			found = value
+		}
+	}
+	return found, found >= 0
+}
+
+// Describe returns a readable description of the expression, e.g. 'at 09:00:00 UTC, on Monday-Friday'
+func (c *cronSchedule) Describe() string {
+	number := strconv.Itoa
+	var parts []string
+
+	hour, singleHour := singleCronValue(c.hours)
+	minute, singleMinute := singleCronValue(c.minutes)
+	second, singleSecond := singleCronValue(c.seconds)
+	if singleHour && singleMinute && singleSecond {
+		parts = append(parts, fmt.Sprintf("at %02d:%02d:%02d UTC", hour, minute, second))
+	} else {
+		for _, field := range []struct {
+			set   []bool
+			field cronField
+		}{{c.seconds, cronSeconds}, {c.minutes, cronMinutes}, {c.hours, cronHours}} {
+			if values := formatCronSet(field.set, field.field, number); values != "" {
+				parts = append(parts, fmt.Sprintf("%s %s", field.field.name, values))
+			} else {
+				parts = append(parts, fmt.Sprintf("every %s", strings.TrimSuffix(field.field.name, "s")))
+			}
+		}
+		parts[len(parts)-1] += " (UTC)"
+	}
+
+	weekday := func(day int) string { return weekdayNames[day] }
+	switch {
+	case c.lastDayOfMonth && c.nearestWeekday:
+		parts = append(parts, "on the last weekday of the month")
+	case c.lastDayOfMonth && c.lastDayOffset > 0:
+		parts = append(parts, fmt.Sprintf("%d days before the last day of the month", c.lastDayOffset))
+	case c.lastDayOfMonth && c.daysOfMonth != nil:
+		parts = append(parts, fmt.Sprintf("on day %s and the last day of the month", formatCronSet(c.daysOfMonth, cronDaysOfMonth, number)))
+	case c.lastDayOfMonth:
+		parts = append(parts, "on the last day of the month")
+	case c.nearestWeekday:
+		day, _ := singleCronValue(c.daysOfMonth)
+		parts = append(parts, fmt.Sprintf("on the weekday nearest to day %d of the month", day))
+	case c.lastDayOfWeek != 0:
+		parts = append(parts, fmt.Sprintf("on the last %s of the month", weekdayNames[c.lastDayOfWeek]))
+	case c.nthDayOfWeek != 0:
+		parts = append(parts, fmt.Sprintf("on the %s %s of the month", ordinal(c.nthWeek), weekdayNames[c.nthDayOfWeek]))
+	case !c.anyDayOfMonth:
+		if days := formatCronSet(c.daysOfMonth, cronDaysOfMonth, number); days != "" {
+			parts = append(parts, fmt.Sprintf("on day %s of the month", days))
+		} else {
+			parts = append(parts, "every day")
+		}
+	default:
+		if days := formatCronSet(c.daysOfWeek, cronDaysOfWeek, weekday); days != "" {
+			parts = append(parts, fmt.Sprintf("on %s", days))
+		} else {
+			parts = append(parts, "every day")
+		}
+	}
+
+	if months := formatCronSet(c.months, cronMonths, func(month int) string { return monthNames[month] }); months != "" {
+		parts = append(parts, fmt.Sprintf("in %s", months))
+	}
+	if c.years != nil {
+		set := make([]bool, cronYears.max+1)
+		for year := range c.years {
+			set[year] = true
+		}
+		parts = append(parts, fmt.Sprintf("in %s", formatCronSet(set, cronYears, number)))
+	}
+	return strings.Join(parts, ", ")
+}
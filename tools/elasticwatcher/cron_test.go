@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The cron parser", func() {
+	It("should parse the Quartz cron expressions", func() {
+		for _, expression := range []string{
+			"0 0/1 * * * ?",
+			"0 5 9 ? * MON-FRI",
+			"0 0 12 L * ?",
+			"0 0 12 L-2 * ?",
+			"0 0 12 15,L * ?",
+			"0 0 12 15W * ?",
+			"0 0 12 ? * 6L",
+			"0 0 12 ? * 2#1 2030",
+			"0 15,45 22-2 1-10/3 JAN,JUN ?",
+		} {
+			_, err := parseCron(expression)
+			Expect(err).ShouldNot(HaveOccurred(), expression)
+		}
+	})
+
+	It("should reject invalid cron expressions", func() {
+		for _, expression := range []string{
+			"0 0/1 * * *",
+			"0 0 12 * * *",
+			"0 0 12 ? * ?",
+			"0 60 * * * ?",
+			"0 0 24 * * ?",
+			"0 0 12 ? * MON#6",
+			"0 */0 * * * ?",
+			"0 0 12 ? FOO *",
+		} {
+			_, err := parseCron(expression)
+			Expect(err).Should(HaveOccurred(), expression)
+		}
+	})
+
+	It("should expand ranges and increments", func() {
+		c, err := parseCron("0 0/20 22-1 ? * FRI-MON")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(c.minutes[0] && c.minutes[20] && c.minutes[40]).Should(BeTrue())
+		Expect(c.minutes[10]).Should(BeFalse())
+		Expect(c.hours[22] && c.hours[23] && c.hours[0] && c.hours[1]).Should(BeTrue())
+		Expect(c.hours[2]).Should(BeFalse())
+		Expect(c.daysOfWeek[6] && c.daysOfWeek[7] && c.daysOfWeek[1] && c.daysOfWeek[2]).Should(BeTrue())
+		Expect(c.daysOfWeek[3]).Should(BeFalse())
+	})
+
+	It("should compute the next fire times", func() {
+		// Tuesday
+		from := time.Date(2018, time.March, 20, 10, 30, 0, 0, time.UTC)
+		for expression, expected := range map[string]time.Time{
+			"0 0/15 * * * ?":       time.Date(2018, time.March, 20, 10, 45, 0, 0, time.UTC),
+			"30 30 10 * * ?":       time.Date(2018, time.March, 20, 10, 30, 30, 0, time.UTC),
+			"0 30 10 * * ?":        time.Date(2018, time.March, 21, 10, 30, 0, 0, time.UTC),
+			"0 5 9 ? * MON-FRI":    time.Date(2018, time.March, 21, 9, 5, 0, 0, time.UTC),
+			"0 0 12 L * ?":         time.Date(2018, time.March, 31, 12, 0, 0, 0, time.UTC),
+			"0 0 12 L-2 * ?":       time.Date(2018, time.March, 29, 12, 0, 0, 0, time.UTC),
+			"0 0 12 1,L * ?":       time.Date(2018, time.March, 31, 12, 0, 0, 0, time.UTC),
+			"0 0 12 LW * ?":        time.Date(2018, time.March, 30, 12, 0, 0, 0, time.UTC),
+			"0 0 12 1W * ?":        time.Date(2018, time.April, 2, 12, 0, 0, 0, time.UTC),
+			"0 0 12 ? * 6L":        time.Date(2018, time.March, 30, 12, 0, 0, 0, time.UTC),
+			"0 0 12 ? * 2#1":       time.Date(2018, time.April, 2, 12, 0, 0, 0, time.UTC),
+			"0 0 0 29 FEB ?":       time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC),
+			"0 0 0 1 JAN ? 2030":   time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+			"0 0 23-1 ? * TUE,WED": time.Date(2018, time.March, 20, 23, 0, 0, 0, time.UTC),
+		} {
+			c, err := parseCron(expression)
+			Expect(err).ShouldNot(HaveOccurred(), expression)
+
+			next, ok := c.Next(from)
+			Expect(ok).Should(BeTrue(), expression)
+			Expect(next).Should(Equal(expected), expression)
+		}
+	})
+
+	It("should not fire on impossible dates", func() {
+		c, err := parseCron("0 0 0 30 FEB ?")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, ok := c.Next(time.Date(2018, time.March, 20, 0, 0, 0, 0, time.UTC))
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("should describe the expressions", func() {
+		for expression, expected := range map[string]string{
+			"0 5 9 ? * MON-FRI":    "at 09:05:00 UTC, on Monday-Friday",
+			"0 0/30 * * * ?":       "seconds 0, minutes 0, 30, every hour (UTC), every day",
+			"0 0 12 ? * 2#1":       "at 12:00:00 UTC, on the 1st Monday of the month",
+			"0 0 12 LW JAN,JUL ?":  "at 12:00:00 UTC, on the last weekday of the month, in January, July",
+			"0 0 12 1,15 * ? 2030": "at 12:00:00 UTC, on day 1, 15 of the month, in 2030",
+			"0 0 12 ? * 6L":        "at 12:00:00 UTC, on the last Friday of the month",
+			"0 0 12 15W * ?":       "at 12:00:00 UTC, on the weekday nearest to day 15 of the month",
+			"0 0 12 15,L * ?":      "at 12:00:00 UTC, on day 15 and the last day of the month",
+		} {
+			c, err := parseCron(expression)
+			Expect(err).ShouldNot(HaveOccurred(), expression)
+			Expect(c.Describe()).Should(Equal(expected), expression)
+		}
+	})
+})
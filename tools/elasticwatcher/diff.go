@@ -0,0 +1,387 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// watchDefaults values added by Watcher when it stores a watch. The keys are
+// dotted paths in the watch body where '*' matches any key, e.g. an action name.
+var watchDefaults = map[string]interface{}{
+	"input.search.request.search_type":            "query_then_fetch",
+	"input.search.request.types":                  []interface{}{},
+	"input.search.request.rest_total_hits_as_int": true,
+	"actions.*.webhook.scheme":                    "http",
+	"actions.*.webhook.method":                    "get",
+	"actions.*.webhook.params":                    map[string]interface{}{},
+	"actions.*.webhook.headers":                   map[string]interface{}{},
+}
+
+func matchDefaultPath(pattern []string, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isWatchDefault(path []string, value interface{}) bool {
+	for pattern, def := range watchDefaults {
+		if matchDefaultPath(strings.Split(pattern, "."), path) && reflect.DeepEqual(def, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripWatchDefaults removes from the installed watch the default values which are not declared in the local watch
+func stripWatchDefaults(local interface{}, installed interface{}, path []string) {
+	localMap, _ := local.(map[string]interface{})
+	installedMap, ok := installed.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, value := range installedMap {
+		keyPath := append(append([]string{}, path...), key)
+		localValue, declared := localMap[key]
+		if !declared && isWatchDefault(keyPath, value) {
+			delete(installedMap, key)
+			continue
+		}
+		stripWatchDefaults(localValue, value, keyPath)
+	}
+}
+
+// fetchWatch retrieves the definition of an installed watch without the fields managed by Watcher
+func fetchWatch(host string, port int, authFile string, watch string) (map[string]interface{}, bool, error) {
+	statusCode, content, err := doRequest(http.MethodGet, buildWatcherURL(host, port, watch), authFile, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to retrieve the watch '%s'. Error: %v", watch, err)
+	}
+	if statusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if statusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("Failed to retrieve the watch '%s'. Status Code: %d. Error: %s", watch, statusCode, string(content))
+	}
+
+	var resp struct {
+		Found bool        `json:"found"`
+		Watch interface{} `json:"watch"`
+	}
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to parse the watch '%s'. Error: %v", watch, err)
+	}
+	if !resp.Found {
+		return nil, false, nil
+	}
+
+	body, err := normalizeWatchBody(resp.Watch)
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to normalize the watch '%s'. Error: %v", watch, err)
+	}
+	return body, true, nil
+}
+
+// The JSON and text diff helpers below, up to hunkRange, are kept identical to the ones of tools/elastictemplate/diff.go
+// since both tools are built as separate binaries: apply every fix to both copies.
+
+func formatJSONValue(value interface{}) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(content)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonPathDiff lists the differences between the installed and local values, one per JSON path
+func jsonPathDiff(installed interface{}, local interface{}, path string) []string {
+	if reflect.DeepEqual(installed, local) {
+		return nil
+	}
+
+	installedMap, installedIsMap := installed.(map[string]interface{})
+	localMap, localIsMap := local.(map[string]interface{})
+	if installedIsMap && localIsMap {
+		keys := make(map[string]interface{})
+		for key := range installedMap {
+			keys[key] = nil
+		}
+		for key := range localMap {
+			keys[key] = nil
+		}
+
+		var diffs []string
+		for _, key := range sortedKeys(keys) {
+			keyPath := path + "." + key
+			installedValue, inInstalled := installedMap[key]
+			localValue, inLocal := localMap[key]
+			switch {
+			case !inLocal:
+				diffs = append(diffs, fmt.Sprintf("- %s: %s", keyPath, formatJSONValue(installedValue)))
+			case !inInstalled:
+				diffs = append(diffs, fmt.Sprintf("+ %s: %s", keyPath, formatJSONValue(localValue)))
+			default:
+				diffs = append(diffs, jsonPathDiff(installedValue, localValue, keyPath)...)
+			}
+		}
+		return diffs
+	}
+
+	installedList, installedIsList := installed.([]interface{})
+	localList, localIsList := local.([]interface{})
+	if installedIsList && localIsList && len(installedList) == len(localList) {
+		var diffs []string
+		for i := range installedList {
+			diffs = append(diffs, jsonPathDiff(installedList[i], localList[i], fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return diffs
+	}
+
+	return []string{fmt.Sprintf("~ %s: %s -> %s", path, formatJSONValue(installed), formatJSONValue(local))}
+}
+
+// unifiedDiff builds a line based diff between two texts with the given number of context lines
+func unifiedDiff(fromName string, from string, toName string, to string, context int) []string {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	// lcs[i][j] holds the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// fromLines and toLines hold the number of lines of each text before every diff line
+	var lines []string
+	var fromLines, toLines []int
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		fromLines = append(fromLines, i)
+		toLines = append(toLines, j)
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	keep := make([]bool, len(lines))
+	changed := false
+	for n, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+		changed = true
+		for k := n - context; k <= n+context; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	diff := []string{"--- " + fromName, "+++ " + toName}
+	for start := 0; start < len(lines); start++ {
+		if !keep[start] {
+			continue
+		}
+		end := start
+		fromCount, toCount := 0, 0
+		for ; end < len(lines) && keep[end]; end++ {
+			if lines[end][0] != '+' {
+				fromCount++
+			}
+			if lines[end][0] != '-' {
+				toCount++
+			}
+		}
+		diff = append(diff, fmt.Sprintf("@@ -%s +%s @@",
+			hunkRange(fromLines[start], fromCount), hunkRange(toLines[start], toCount)))
+		diff = append(diff, lines[start:end]...)
+		start = end
+	}
+	return diff
+}
+
+// hunkRange formats the range of a hunk header, an empty range starts at the line before the hunk
+func hunkRange(before int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+type diffCmd struct {
+	host        string
+	port        int
+	watchesFile string
+	authFile    string
+	watches     string
+	format      string
+	secretsFile string
+}
+
+func (*diffCmd) Name() string { return "diff" }
+func (*diffCmd) Synopsis() string {
+	return "Show the differences between the watches file and the watches installed in Elasticsearch Watcher"
+}
+
+func (*diffCmd) Usage() string {
+	return `diff [-host] <host name> [-port] <port> [-watches-file] <path to watches file> [-watches] <comma separated list of watches> [-format] <unified|path> [-auth-file] <path to basic auth file>
+        [-secrets-file] <path to secrets file>
+        Show the differences between the watches file and the watches installed in Elasticsearch Watcher.
+        The installed secret values are replaced with their placeholders before the comparison.
+        The command exits with a non-zero code when any watch differs.
+	`
+}
+
+func (d *diffCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&d.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&d.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&d.watchesFile, "watches-file", "", "Path to watches file")
+	f.StringVar(&d.watches, "watches", "", "Comma separated list of watches names to compare (default all watches in the file)")
+	f.StringVar(&d.format, "format", "unified", "Output format of the differences: unified or path")
+	f.StringVar(&d.secretsFile, "secrets-file", "", "Path to the JSON file with the secrets referenced by the ${secret:name} placeholders")
+	f.StringVar(&d.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (d *diffCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if d.format != "unified" && d.format != "path" {
+		fmt.Printf("Unknown diff format '%s'\n", d.format)
+		return subcommands.ExitUsageError
+	}
+	if _, err := os.Stat(d.watchesFile); os.IsNotExist(err) {
+		fmt.Printf("Watches file '%s' not found\n", d.watchesFile)
+		return subcommands.ExitFailure
+	}
+	cfg, err := loadWatches(d.watchesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	secrets, err := loadSecrets(d.secretsFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	declared := make(map[string]bool)
+	for _, watch := range cfg.Watches {
+		declared[watch.Name] = true
+	}
+	selected := make(map[string]bool)
+	unknown := false
+	if d.watches != "" {
+		for _, name := range parseWatchNames(d.watches) {
+			if !declared[name] {
+				fmt.Printf("Watch '%s' not found in the watches file\n", name)
+				unknown = true
+			}
+			selected[name] = true
+		}
+	}
+	if unknown {
+		return subcommands.ExitFailure
+	}
+
+	drift := false
+	for _, watch := range cfg.Watches {
+		if len(selected) > 0 && !selected[watch.Name] {
+			continue
+		}
+
+		local, err := normalizeWatchBody(watch.Body)
+		if err != nil {
+			fmt.Printf("Failed to normalize the watch '%s'. Error: %v\n", watch.Name, err)
+			return subcommands.ExitFailure
+		}
+
+		installed, found, err := fetchWatch(d.host, d.port, d.authFile, watch.Name)
+		if err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+		if !found {
+			drift = true
+			fmt.Printf("Watch '%s': not installed\n", watch.Name)
+			continue
+		}
+		removeOwnership(installed)
+		stripWatchDefaults(local, installed, nil)
+		installed = maskSecretValues(installed, secrets).(map[string]interface{})
+
+		var diffs []string
+		if d.format == "path" {
+			diffs = jsonPathDiff(installed, local, "$")
+		} else {
+			installedContent, _ := json.MarshalIndent(installed, "", "  ") // #nosec
+			localContent, _ := json.MarshalIndent(local, "", "  ")         // #nosec
+			diffs = unifiedDiff("installed/"+watch.Name, string(installedContent),
+				"local/"+watch.Name, string(localContent), 3)
+		}
+
+		if len(diffs) == 0 {
+			fmt.Printf("Watch '%s': no differences\n", watch.Name)
+			continue
+		}
+		drift = true
+		fmt.Printf("Watch '%s':\n", watch.Name)
+		for _, line := range diffs {
+			fmt.Println(line)
+		}
+	}
+
+	if drift {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
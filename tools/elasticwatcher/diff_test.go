@@ -0,0 +1,172 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher diff", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	var watchesFile *os.File
+	const watcherEndpoint = "/_xpack/watcher/watch"
+	const Watches = `{"watches": [{"name": "watch_test", "body": {
+		"trigger": {"schedule": {"interval": "5m"}},
+		"input": {"search": {"request": {"indices": ["logstash-*"]}}}
+	}}]}`
+	const SameResponse = `{"found": true, "_id": "watch_test", "_version": 3,
+		"status": {"state": {"active": true}},
+		"watch": {
+			"trigger": {"schedule": {"interval": "5m"}},
+			"input": {"search": {"request": {"search_type": "query_then_fetch", "indices": ["logstash-*"], "types": []}}}
+		}}`
+	const ChangedResponse = `{"found": true, "_id": "watch_test", "_version": 3,
+		"watch": {
+			"trigger": {"schedule": {"interval": "1m"}},
+			"input": {"search": {"request": {"indices": ["logstash-*"]}}}
+		}}`
+
+	Context("json path diff", func() {
+		It("should report changed, added and removed fields", func() {
+			installed := map[string]interface{}{"a": 1.0, "b": map[string]interface{}{"c": "x"}, "d": true}
+			local := map[string]interface{}{"a": 2.0, "b": map[string]interface{}{"c": "x"}, "e": "new"}
+
+			diffs := jsonPathDiff(installed, local, "$")
+
+			Expect(diffs).Should(Equal([]string{
+				"~ $.a: 1 -> 2",
+				"- $.d: true",
+				"+ $.e: \"new\"",
+			}))
+		})
+	})
+
+	Context("unified diff", func() {
+		It("should not report identical texts", func() {
+			Expect(unifiedDiff("a", "x\ny", "b", "x\ny", 3)).Should(BeEmpty())
+		})
+
+		It("should report the changed lines", func() {
+			diff := unifiedDiff("a", "x\ny\nz", "b", "x\nw\nz", 0)
+
+			Expect(diff).Should(Equal([]string{"--- a", "+++ b", "@@ -2,1 +2,1 @@", "-y", "+w"}))
+		})
+	})
+
+	Context("diff command", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			elasticHost = host
+			elasticPort, err = strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			watchesFile, err = ioutil.TempFile("", "watches")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = watchesFile.Write([]byte(Watches))
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if watchesFile != nil {
+				os.Remove(watchesFile.Name())
+			}
+			server.Close()
+		})
+
+		It("should succeed when the installed watch only differs by server fields", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_test"),
+					ghttp.RespondWith(http.StatusOK, SameResponse),
+				),
+			)
+
+			cmd := &diffCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: watchesFile.Name(),
+				format:      "unified"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should fail when the installed watch drifted", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_test"),
+					ghttp.RespondWith(http.StatusOK, ChangedResponse),
+				),
+			)
+
+			cmd := &diffCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: watchesFile.Name(),
+				format:      "path"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitFailure))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should fail when the watch is not installed", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_test"),
+					ghttp.RespondWith(http.StatusNotFound, `{"found": false}`),
+				),
+			)
+
+			cmd := &diffCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: watchesFile.Name(),
+				format:      "unified"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitFailure))
+		})
+
+		It("should fail when a selected watch is not in the watches file", func() {
+			cmd := &diffCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: watchesFile.Name(),
+				watches:     "watch_test,watch_unknown",
+				format:      "unified"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitFailure))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,611 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/google/subcommands"
+)
+
+// watchDoc the documentation of a watch
+type watchDoc struct {
+	Name         string
+	Anchor       string
+	Description  string
+	Schedule     []string
+	Input        string
+	Indices      []string
+	Filters      []string
+	Aggregations []string
+	Condition    string
+	Actions      []string
+	Runbook      string
+}
+
+// compareOperatorSymbols the symbols of the compare operators
+var compareOperatorSymbols = map[string]string{"eq": "==", "not_eq": "!=", "lt": "<", "lte": "<=", "gt": ">", "gte": ">="}
+
+// relativeTimePattern matches the range bounds relative to the trigger time, e.g. {{ctx.trigger.scheduled_time}}||-5m
+var relativeTimePattern = regexp.MustCompile(`^\{\{\s*ctx\.trigger\.(scheduled|triggered)_time\s*\}\}\|\|-(\w+)$`)
+
+var anchorPattern = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// docAnchor the anchor of a section in the Markdown rendered by GitHub
+func docAnchor(name string) string {
+	return anchorPattern.ReplaceAllString(strings.Replace(strings.ToLower(name), " ", "-", -1), "")
+}
+
+// singleField returns the field and the value of the objects such as {"response": 404}
+func singleField(value interface{}) (string, interface{}, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", nil, false
+	}
+	for field, item := range m {
+		return field, item, true
+	}
+	return "", nil, false
+}
+
+// describeQueryValue unwraps the long form of the query values, e.g. {"query": 404} or {"value": "web-1"}
+func describeQueryValue(value interface{}) string {
+	if m, ok := value.(map[string]interface{}); ok {
+		for _, key := range []string{"query", "value"} {
+			if inner, ok := m[key]; ok {
+				return mustacheString(inner)
+			}
+		}
+	}
+	return mustacheString(value)
+}
+
+// describeRange describes a range query, the bounds relative to the trigger time are described as a time window
+func describeRange(field string, bounds map[string]interface{}) string {
+	var parts []string
+	for _, bound := range []struct {
+		keys   []string
+		symbol string
+	}{{[]string{"gte", "from"}, ">="}, {[]string{"gt"}, ">"}, {[]string{"lte", "to"}, "<="}, {[]string{"lt"}, "<"}} {
+		for _, key := range bound.keys {
+			value, ok := bounds[key]
+			if !ok {
+				continue
+			}
+			text := mustacheString(value)
+			if match := relativeTimePattern.FindStringSubmatch(text); match != nil && bound.symbol[0] == '>' {
+				parts = append(parts, fmt.Sprintf("in the last %s", match[2]))
+			} else if !strings.Contains(text, "{{ctx.trigger.") {
+				parts = append(parts, fmt.Sprintf("%s %s", bound.symbol, text))
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%s in a range", field)
+	}
+	return fmt.Sprintf("%s %s", field, strings.Join(parts, " and "))
+}
+
+// describeQuery summarizes the filters of an Elasticsearch query, one line per filter
+func describeQuery(query interface{}) []string {
+	queryType, value, ok := singleField(query)
+	if !ok {
+		return nil
+	}
+
+	switch queryType {
+	case "bool":
+		clauses, _ := value.(map[string]interface{})
+		var lines []string
+		for _, clause := range []string{"must", "filter"} {
+			for _, item := range listValues(clauses[clause]) {
+				lines = append(lines, describeQuery(item)...)
+			}
+		}
+		if should, ok := clauses["should"]; ok {
+			var alternatives []string
+			for _, item := range listValues(should) {
+				alternatives = append(alternatives, strings.Join(describeQuery(item), " and "))
+			}
+			lines = append(lines, fmt.Sprintf("one of: %s", strings.Join(alternatives, " | ")))
+		}
+		for _, item := range listValues(clauses["must_not"]) {
+			for _, line := range describeQuery(item) {
+				lines = append(lines, "NOT "+line)
+			}
+		}
+		return lines
+	case "match_all":
+		return []string{"all documents"}
+	case "exists":
+		field, _ := value.(map[string]interface{})["field"].(string)
+		return []string{fmt.Sprintf("%s exists", field)}
+	case "query_string", "simple_query_string":
+		q, _ := value.(map[string]interface{})["query"].(string)
+		return []string{fmt.Sprintf("query %s", q)}
+	}
+
+	field, fieldValue, ok := singleField(value)
+	if !ok {
+		return []string{fmt.Sprintf("%s %s", queryType, truncate(encodeJSON(value), 80))}
+	}
+	switch queryType {
+	case "match", "match_phrase":
+		return []string{fmt.Sprintf("%s matches %s", field, describeQueryValue(fieldValue))}
+	case "term":
+		return []string{fmt.Sprintf("%s is %s", field, describeQueryValue(fieldValue))}
+	case "terms":
+		var values []string
+		for _, item := range listValues(fieldValue) {
+			values = append(values, mustacheString(item))
+		}
+		return []string{fmt.Sprintf("%s is one of %s", field, strings.Join(values, ", "))}
+	case "wildcard", "prefix", "regexp":
+		return []string{fmt.Sprintf("%s %s %s", field, queryType, describeQueryValue(fieldValue))}
+	case "range":
+		bounds, _ := fieldValue.(map[string]interface{})
+		return []string{describeRange(field, bounds)}
+	}
+	return []string{fmt.Sprintf("%s %s", queryType, truncate(encodeJSON(value), 80))}
+}
+
+// describeAggregations summarizes the aggregations of a search, e.g. "by_host: terms of host"
+func describeAggregations(aggs interface{}) []string {
+	m, _ := aggs.(map[string]interface{})
+	var lines []string
+	for _, name := range sortedKeys(m) {
+		definition, _ := m[name].(map[string]interface{})
+		for _, aggType := range sortedKeys(definition) {
+			if aggType == "aggs" || aggType == "aggregations" || aggType == "meta" {
+				continue
+			}
+			settings, _ := definition[aggType].(map[string]interface{})
+			if field, ok := settings["field"].(string); ok {
+				lines = append(lines, fmt.Sprintf("%s: %s of %s", name, aggType, field))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s: %s", name, aggType))
+			}
+		}
+	}
+	return lines
+}
+
+// describeCondition describes a watch condition as an expression
+func describeCondition(condition interface{}) string {
+	if condition == nil {
+		return "always"
+	}
+	conditionType, value, err := singleConditionEntry(condition)
+	if err != nil {
+		return truncate(encodeJSON(condition), 80)
+	}
+	switch conditionType {
+	case "always", "never":
+		return conditionType
+	case "compare":
+		if path, operators, err := singleEntry(value, "compare"); err == nil {
+			for operator, expected := range operators {
+				return fmt.Sprintf("%s %s %s", path, orDefault(compareOperatorSymbols[operator], operator), mustacheString(expected))
+			}
+		}
+	case "array_compare":
+		if path, entry, err := singleEntry(value, "array_compare"); err == nil {
+			itemPath, _ := entry["path"].(string)
+			for operator, comparison := range entry {
+				if operator == "path" {
+					continue
+				}
+				c, _ := comparison.(map[string]interface{})
+				quantifier, _ := c["quantifier"].(string)
+				if quantifier == "" {
+					quantifier = "some"
+				}
+				item := "items"
+				if itemPath != "" {
+					item = fmt.Sprintf("items with %s", itemPath)
+				}
+				return fmt.Sprintf("%s %s of %s %s %s", quantifier, item, path,
+					orDefault(compareOperatorSymbols[operator], operator), mustacheString(c["value"]))
+			}
+		}
+	case "script":
+		script := mustacheString(value)
+		if m, ok := value.(map[string]interface{}); ok {
+			for _, key := range []string{"source", "inline", "id"} {
+				if s, ok := m[key].(string); ok {
+					script = s
+					break
+				}
+			}
+		}
+		return "script " + truncate(strings.Join(strings.Fields(script), " "), 120)
+	}
+	return fmt.Sprintf("%s %s", conditionType, truncate(encodeJSON(value), 80))
+}
+
+func orDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// describeWebhook describes the target of a webhook or http request, e.g. POST https://outlook.office.com:443/path
+func describeWebhook(settings map[string]interface{}) string {
+	method := strings.ToUpper(orDefault(mustacheString(settings["method"]), "get"))
+	if url, ok := settings["url"].(string); ok {
+		return fmt.Sprintf("%s %s", method, url)
+	}
+	target := fmt.Sprintf("%s://%s", orDefault(mustacheString(settings["scheme"]), "http"), mustacheString(settings["host"]))
+	if port := mustacheString(settings["port"]); port != "" {
+		target += ":" + port
+	}
+	return fmt.Sprintf("%s %s%s", method, target, mustacheString(settings["path"]))
+}
+
+// maskWebhookValue masks a webhook value which may hold a credential, unless it is a secret placeholder
+func maskWebhookValue(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok || s == "" || secretPlaceholderPattern.MatchString(s) {
+		return value
+	}
+	return maskedValue
+}
+
+// maskWebhookCredentials masks the paths, the URLs and the query parameters of the webhook actions, since
+// they hold the credentials of webhooks such as the Teams ones, unless they are secret placeholders
+func maskWebhookCredentials(actions interface{}) {
+	m, _ := actions.(map[string]interface{})
+	for _, action := range m {
+		definition, _ := action.(map[string]interface{})
+		settings, ok := definition["webhook"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if path, ok := settings["path"].(string); ok && path != "/" && maskWebhookValue(path) == maskedValue {
+			settings["path"] = "/" + maskedValue
+		}
+		if rawURL, ok := settings["url"].(string); ok && !secretPlaceholderPattern.MatchString(rawURL) {
+			if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+				if u.Path != "" && u.Path != "/" || u.RawQuery != "" {
+					settings["url"] = fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, maskedValue)
+				}
+			} else {
+				settings["url"] = maskedValue
+			}
+		}
+		if params, ok := settings["params"].(map[string]interface{}); ok {
+			for key, value := range params {
+				params[key] = maskWebhookValue(value)
+			}
+		}
+	}
+}
+
+// describeActions describes the notification targets of the actions
+func describeActions(actions interface{}) []string {
+	m, _ := actions.(map[string]interface{})
+	var lines []string
+	for _, name := range sortedKeys(m) {
+		definition, _ := m[name].(map[string]interface{})
+		var target string
+		for _, actionType := range sortedKeys(definition) {
+			settings, ok := definition[actionType].(map[string]interface{})
+			if !ok || actionType == "condition" || actionType == "transform" {
+				continue
+			}
+			switch actionType {
+			case "webhook":
+				target = "webhook " + describeWebhook(settings)
+			case "email":
+				target = fmt.Sprintf("email to %s", strings.Join(stringValues(settings["to"]), ", "))
+			case "logging":
+				target = fmt.Sprintf("logging at level %s", orDefault(mustacheString(settings["level"]), "info"))
+			case "index":
+				target = fmt.Sprintf("index %s", mustacheString(settings["index"]))
+			case "slack":
+				message, _ := settings["message"].(map[string]interface{})
+				target = fmt.Sprintf("slack to %s", strings.Join(stringValues(message["to"]), ", "))
+			default:
+				target = actionType
+			}
+		}
+		line := fmt.Sprintf("%s: %s", name, orDefault(target, "unknown action"))
+		if throttle, ok := definition["throttle_period"]; ok {
+			line += fmt.Sprintf(" (throttled for %s)", mustacheString(throttle))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// describeInput collects the indices and the filters of the search inputs, including the inputs of a chain
+func describeInput(input interface{}, doc *watchDoc) {
+	inputType, value, ok := singleField(input)
+	if !ok {
+		return
+	}
+	switch inputType {
+	case "search":
+		request, _ := value.(map[string]interface{})["request"].(map[string]interface{})
+		doc.Indices = append(doc.Indices, stringValues(request["indices"])...)
+		body, _ := request["body"].(map[string]interface{})
+		doc.Filters = append(doc.Filters, describeQuery(body["query"])...)
+		aggs := body["aggs"]
+		if aggs == nil {
+			aggs = body["aggregations"]
+		}
+		doc.Aggregations = append(doc.Aggregations, describeAggregations(aggs)...)
+	case "chain":
+		inputs, _ := value.(map[string]interface{})["inputs"].([]interface{})
+		for _, item := range inputs {
+			if _, chained, ok := singleField(item); ok {
+				describeInput(chained, doc)
+			}
+		}
+	case "http":
+		request, _ := value.(map[string]interface{})["request"].(map[string]interface{})
+		doc.Input = "http " + describeWebhook(request)
+	default:
+		doc.Input = inputType
+	}
+}
+
+// buildWatchDoc builds the documentation of a watch, the secrets are masked
+func buildWatchDoc(watch Watch, secrets map[string]string, runbookKey string) watchDoc {
+	doc := watchDoc{Name: watch.Name, Anchor: docAnchor(watch.Name)}
+	normalized, err := normalizeWatchBody(watch.Body)
+	if err != nil {
+		doc.Description = fmt.Sprintf("Invalid watch body: %v", err)
+		return doc
+	}
+	body := maskSecrets(normalized, secrets).(map[string]interface{})
+	maskWebhookCredentials(body["actions"])
+
+	metadata, _ := body["metadata"].(map[string]interface{})
+	if description, ok := metadata["description"].(string); ok {
+		doc.Description = description
+	}
+	if runbook, ok := metadata[runbookKey].(string); ok {
+		doc.Runbook = strings.TrimSpace(runbook)
+	}
+
+	schedule, _ := lookupMustachePath(body, []string{"trigger", "schedule"})
+	if preview, err := parseTriggerSchedule(schedule); err != nil {
+		doc.Schedule = []string{fmt.Sprintf("invalid schedule: %v", err)}
+	} else {
+		doc.Schedule = preview.Descriptions
+	}
+
+	describeInput(body["input"], &doc)
+	doc.Condition = describeCondition(body["condition"])
+	doc.Actions = describeActions(body["actions"])
+	return doc
+}
+
+const markdownDocsTemplate = `# Watches
+{{range .}}
+* [{{.Name}}](#{{.Anchor}}){{end}}
+{{range .}}
+## {{.Name}}
+{{if .Description}}
+{{.Description}}
+{{end}}
+**Schedule:** {{join .Schedule "; "}}
+{{if .Input}}
+**Input:** {{code .Input}}
+{{end}}{{if .Indices}}
+**Indices:** {{range $i, $index := .Indices}}{{if $i}}, {{end}}{{code $index}}{{end}}
+{{end}}{{if .Filters}}
+**Query:**
+{{range .Filters}}
+* {{code .}}{{end}}
+{{end}}{{if .Aggregations}}
+**Aggregations:**
+{{range .Aggregations}}
+* {{code .}}{{end}}
+{{end}}
+**Condition:** {{code .Condition}}
+{{if .Actions}}
+**Notifications:**
+{{range .Actions}}
+* {{code .}}{{end}}
+{{else}}
+**Notifications:** none
+{{end}}{{if .Runbook}}
+**Runbook:**
+
+{{.Runbook}}
+{{end}}{{end}}`
+
+const htmlDocsTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Watches</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: auto; }
+code { background: #f4f4f4; padding: 1px 4px; }
+.runbook { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Watches</h1>
+<ul>
+{{- range .}}
+<li><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+{{- range .}}
+<h2 id="{{.Anchor}}">{{.Name}}</h2>
+{{- if .Description}}
+<p>{{.Description}}</p>
+{{- end}}
+<p><strong>Schedule:</strong> {{join .Schedule "; "}}</p>
+{{- if .Input}}
+<p><strong>Input:</strong> <code>{{.Input}}</code></p>
+{{- end}}
+{{- if .Indices}}
+<p><strong>Indices:</strong> {{range $i, $index := .Indices}}{{if $i}}, {{end}}<code>{{$index}}</code>{{end}}</p>
+{{- end}}
+{{- if .Filters}}
+<p><strong>Query:</strong></p>
+<ul>
+{{- range .Filters}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Aggregations}}
+<p><strong>Aggregations:</strong></p>
+<ul>
+{{- range .Aggregations}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+<p><strong>Condition:</strong> <code>{{.Condition}}</code></p>
+<p><strong>Notifications:</strong>{{if not .Actions}} none{{end}}</p>
+{{- if .Actions}}
+<ul>
+{{- range .Actions}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Runbook}}
+<p><strong>Runbook:</strong></p>
+<div class="runbook">{{.Runbook}}</div>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`
+
+// markdownCode formats a text as a Markdown code span
+func markdownCode(text string) string {
+	if strings.Contains(text, "`") {
+		return "`` " + text + " ``"
+	}
+	return "`" + text + "`"
+}
+
+// renderWatchDocs renders the documentation of the watches in Markdown or HTML
+func renderWatchDocs(docs []watchDoc, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "markdown":
+		t, err := template.New("docs").Funcs(template.FuncMap{"join": strings.Join, "code": markdownCode}).Parse(markdownDocsTemplate)
+		if err != nil {
+			return nil, err
+		}
+		err = t.Execute(&buf, docs)
+		if err != nil {
+			return nil, err
+		}
+	case "html":
+		t, err := htmltemplate.New("docs").Funcs(htmltemplate.FuncMap{"join": strings.Join}).Parse(htmlDocsTemplate)
+		if err != nil {
+			return nil, err
+		}
+		err = t.Execute(&buf, docs)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format '%s', expected markdown or html", format)
+	}
+	return buf.Bytes(), nil
+}
+
+type docsCmd struct {
+	watchesFile string
+	watches     string
+	format      string
+	outputFile  string
+	secretsFile string
+	runbookKey  string
+}
+
+func (*docsCmd) Name() string { return "docs" }
+func (*docsCmd) Synopsis() string {
+	return "Generate the runbook documentation of the watches in Markdown or HTML"
+}
+
+func (*docsCmd) Usage() string {
+	return `docs [-watches-file] <path to watches file> [-watches] <watch names> [-format] <markdown|html> [-output-file] <path to output file>
+        [-secrets-file] <path to secrets file> [-runbook-key] <metadata key>
+        Generate one section per watch with its schedule, indices, query filters, condition, notification targets and runbook
+	`
+}
+
+func (d *docsCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&d.watchesFile, "watches-file", "", "Path to watches file")
+	f.StringVar(&d.watches, "watches", "", "Comma separated list of watch names (default all the watches of the file)")
+	f.StringVar(&d.format, "format", "markdown", "Format of the documentation: markdown or html")
+	f.StringVar(&d.outputFile, "output-file", "", "Path to the output file (default standard output)")
+	f.StringVar(&d.secretsFile, "secrets-file", "", "Path to a JSON file with the secrets which are masked in the documentation")
+	f.StringVar(&d.runbookKey, "runbook-key", "runbook", "Key of the watch metadata holding the runbook text")
+}
+
+func (d *docsCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if d.format != "markdown" && d.format != "html" {
+		fmt.Printf("Unknown format '%s', expected markdown or html\n", d.format)
+		return subcommands.ExitUsageError
+	}
+	cfg, err := loadWatches(d.watchesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	secrets, err := loadSecrets(d.secretsFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	var selected []string
+	if d.watches != "" {
+		selected = parseWatchNames(d.watches)
+	}
+	var docs []watchDoc
+	for _, watch := range cfg.Watches {
+		if len(selected) > 0 && !containsString(selected, watch.Name) {
+			continue
+		}
+		docs = append(docs, buildWatchDoc(watch, secrets, d.runbookKey))
+	}
+	if len(docs) == 0 {
+		fmt.Println("No watches to document")
+		return subcommands.ExitFailure
+	}
+
+	content, err := renderWatchDocs(docs, d.format)
+	if err != nil {
+		fmt.Printf("Failed to render the documentation. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	if d.outputFile == "" {
+		fmt.Print(string(content))
+		return subcommands.ExitSuccess
+	}
+	err = ioutil.WriteFile(d.outputFile, content, 0644) // #nosec
+	if err != nil {
+		fmt.Printf("Failed to write the documentation '%s'. Error: %v\n", d.outputFile, err)
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("Documented %d watches in '%s'.\n", len(docs), d.outputFile)
+	return subcommands.ExitSuccess
+}
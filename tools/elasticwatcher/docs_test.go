@@ -0,0 +1,185 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher docs", func() {
+	const Watches = `{"watches": [{"name": "watch_http_404_error", "body": {
+		"metadata": {"description": "Too many pages not found", "runbook": "Check the ingress logs.\n\nEscalate to the web team."},
+		"trigger": {"schedule": {"interval": "15m"}},
+		"input": {"search": {"request": {
+			"indices": ["dev-logstash-*"],
+			"body": {"query": {"bool": {
+				"must": {"match": {"response": 404}},
+				"must_not": [{"term": {"agent": {"value": "kube-probe"}}}],
+				"filter": {"range": {"@timestamp": {"from": "{{ctx.trigger.scheduled_time}}||-5m", "to": "{{ctx.trigger.triggered_time}}"}}}
+			}},
+			"aggs": {"by_host": {"terms": {"field": "host"}}}}
+		}}},
+		"condition": {"array_compare": {"ctx.payload.aggregations.by_host.buckets": {"path": "doc_count", "gte": {"value": 25}}}},
+		"actions": {
+			"teams_webhook": {
+				"throttle_period": "1h",
+				"webhook": {"scheme": "https", "host": "outlook.office.com", "port": 443, "method": "post", "path": "/webhook/s3cr3t"}
+			},
+			"email_admin": {"email": {"to": ["oncall@example.com"], "subject": "404"}},
+			"secured": {"webhook": {"host": "alerts", "port": 80, "auth": {"basic": {"username": "u", "password": "p4ss"}}}}
+		}
+	}}]}`
+	var cfg WatcherConfig
+	secrets := map[string]string{"teams_webhook": "/webhook/s3cr3t"}
+
+	BeforeEach(func() {
+		Expect(json.Unmarshal([]byte(Watches), &cfg)).Should(Succeed())
+	})
+
+	It("should describe a watch with its secrets masked", func() {
+		doc := buildWatchDoc(cfg.Watches[0], secrets, "runbook")
+
+		Expect(doc.Description).Should(Equal("Too many pages not found"))
+		Expect(doc.Schedule).Should(Equal([]string{"every 15m, relative to the time the watch was activated"}))
+		Expect(doc.Indices).Should(Equal([]string{"dev-logstash-*"}))
+		Expect(doc.Filters).Should(Equal([]string{
+			"response matches 404",
+			"@timestamp in the last 5m",
+			"NOT agent is kube-probe",
+		}))
+		Expect(doc.Aggregations).Should(Equal([]string{"by_host: terms of host"}))
+		Expect(doc.Condition).Should(Equal("some items with doc_count of ctx.payload.aggregations.by_host.buckets >= 25"))
+		Expect(doc.Actions).Should(Equal([]string{
+			"email_admin: email to oncall@example.com",
+			"secured: webhook GET http://alerts:80",
+			"teams_webhook: webhook POST https://outlook.office.com:443${secret:teams_webhook} (throttled for 1h)",
+		}))
+		Expect(doc.Runbook).Should(Equal("Check the ingress logs.\n\nEscalate to the web team."))
+	})
+
+	It("should mask the webhook credentials without the secrets", func() {
+		watch := Watch{Name: "watch_teams", Body: map[string]interface{}{
+			"actions": map[string]interface{}{
+				"teams": map[string]interface{}{"webhook": map[string]interface{}{
+					"scheme": "https", "host": "outlook.office.com", "port": 443, "method": "post", "path": "/webhook/s3cr3t"}},
+				"hook": map[string]interface{}{"webhook": map[string]interface{}{
+					"url": "https://hooks.example.com/services/t0k3n?key=s3cr3t"}},
+				"placeholder": map[string]interface{}{"webhook": map[string]interface{}{
+					"host": "outlook.office.com", "path": "${secret:teams_webhook}", "params": map[string]interface{}{"token": "s3cr3t"}}},
+			},
+		}}
+
+		doc := buildWatchDoc(watch, nil, "runbook")
+
+		Expect(doc.Actions).Should(Equal([]string{
+			"hook: webhook GET https://hooks.example.com/::masked::",
+			"placeholder: webhook GET http://outlook.office.com${secret:teams_webhook}",
+			"teams: webhook POST https://outlook.office.com:443/::masked::",
+		}))
+		Expect(watch.Body.(map[string]interface{})["actions"].(map[string]interface{})["placeholder"].(map[string]interface{})["webhook"].(map[string]interface{})["params"]).
+			Should(Equal(map[string]interface{}{"token": "s3cr3t"}))
+	})
+
+	It("should describe the conditions", func() {
+		for condition, expected := range map[string]string{
+			`{"compare": {"ctx.payload.hits.total": {"gt": 0}}}`: "ctx.payload.hits.total > 0",
+			`{"always": {}}`: "always",
+			`{"script": {"source": "return ctx.payload.hits.total >\n 5;"}}`:                  "script return ctx.payload.hits.total > 5;",
+			`{"array_compare": {"ctx.payload.x": {"eq": {"value": 1, "quantifier": "all"}}}}`: "all items of ctx.payload.x == 1",
+		} {
+			var value interface{}
+			Expect(json.Unmarshal([]byte(condition), &value)).Should(Succeed())
+
+			Expect(describeCondition(value)).Should(Equal(expected), condition)
+		}
+		Expect(describeCondition(nil)).Should(Equal("always"))
+	})
+
+	It("should describe the other inputs", func() {
+		doc := buildWatchDoc(Watch{Name: "watch_health", Body: map[string]interface{}{
+			"trigger": map[string]interface{}{"schedule": map[string]interface{}{"daily": map[string]interface{}{"at": "noon"}}},
+			"input": map[string]interface{}{"http": map[string]interface{}{"request": map[string]interface{}{
+				"host": "localhost", "port": 9200, "path": "/_cluster/health",
+			}}},
+		}}, nil, "runbook")
+
+		Expect(doc.Input).Should(Equal("http GET http://localhost:9200/_cluster/health"))
+		Expect(doc.Schedule).Should(Equal([]string{"daily at 12:00 UTC"}))
+		Expect(doc.Actions).Should(BeEmpty())
+	})
+
+	It("should render the documentation in Markdown", func() {
+		content, err := renderWatchDocs([]watchDoc{buildWatchDoc(cfg.Watches[0], secrets, "runbook")}, "markdown")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(content)).Should(ContainSubstring("* [watch_http_404_error](#watch_http_404_error)"))
+		Expect(string(content)).Should(ContainSubstring("## watch_http_404_error\n\nToo many pages not found\n"))
+		Expect(string(content)).Should(ContainSubstring("**Indices:** `dev-logstash-*`"))
+		Expect(string(content)).Should(ContainSubstring("* `response matches 404`"))
+		Expect(string(content)).Should(ContainSubstring("**Runbook:**\n\nCheck the ingress logs.\n\nEscalate to the web team.\n"))
+		Expect(string(content)).ShouldNot(ContainSubstring("s3cr3t"))
+		Expect(string(content)).ShouldNot(ContainSubstring("p4ss"))
+	})
+
+	It("should render the documentation in HTML", func() {
+		doc := buildWatchDoc(cfg.Watches[0], secrets, "runbook")
+		doc.Runbook = "<script>alert(1)</script>"
+
+		content, err := renderWatchDocs([]watchDoc{doc}, "html")
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(content)).Should(ContainSubstring(`<h2 id="watch_http_404_error">watch_http_404_error</h2>`))
+		Expect(string(content)).Should(ContainSubstring("<li><code>response matches 404</code></li>"))
+		Expect(string(content)).Should(ContainSubstring("&lt;script&gt;"))
+		Expect(string(content)).ShouldNot(ContainSubstring("s3cr3t"))
+	})
+
+	Context("docs command", func() {
+		var dir, watchesFile string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "docs")
+			Expect(err).ShouldNot(HaveOccurred())
+			watchesFile = filepath.Join(dir, "watches.json")
+			Expect(ioutil.WriteFile(watchesFile, []byte(Watches), 0644)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should write the documentation", func() {
+			outputFile := filepath.Join(dir, "watches.html")
+			cmd := &docsCmd{watchesFile: watchesFile, format: "html", outputFile: outputFile, runbookKey: "runbook"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+
+			content, err := ioutil.ReadFile(outputFile)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(content)).Should(ContainSubstring("watch_http_404_error"))
+		})
+
+		It("should fail when no watch is selected", func() {
+			cmd := &docsCmd{watchesFile: watchesFile, watches: "watch_unknown", format: "markdown"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+
+		It("should reject an unknown format", func() {
+			cmd := &docsCmd{watchesFile: watchesFile, format: "pdf"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+		})
+	})
+})
@@ -0,0 +1,641 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// elastalertRuleTypes the ElastAlert rule types which can be converted into watches
+var elastalertRuleTypes = []string{"any", "frequency", "blacklist", "whitelist", "flatline", "spike"}
+
+// elastalertOptions the rule options which are translated, or which have no meaning for Watcher such as the
+// connection settings. The other options are reported as warnings.
+var elastalertOptions = map[string]bool{
+	"name": true, "type": true, "index": true, "filter": true, "description": true, "timestamp_field": true,
+	"timeframe": true, "num_events": true, "query_key": true, "realert": true, "is_enabled": true,
+	"compare_key": true, "blacklist": true, "whitelist": true, "ignore_null": true, "threshold": true,
+	"spike_height": true, "spike_type": true, "threshold_ref": true, "threshold_cur": true,
+	"alert": true, "alert_subject": true, "alert_subject_args": true, "alert_text": true, "alert_text_args": true,
+	"email": true, "cc": true, "bcc": true, "from_addr": true, "ms_teams_webhook_url": true, "ms_teams_alert_summary": true,
+	"slack_webhook_url": true, "http_post_url": true,
+	"es_host": true, "es_port": true, "use_ssl": true, "verify_certs": true, "es_username": true, "es_password": true,
+}
+
+// elastalertRuleFile an ElastAlert rule loaded from a YAML file
+type elastalertRuleFile struct {
+	File string
+	Rule map[string]interface{}
+}
+
+// elastalertConversion the outcome of the conversion of an ElastAlert rule, the watch is nil if the rule cannot be converted
+type elastalertConversion struct {
+	File     string
+	Rule     string
+	Watch    *Watch
+	Warnings []string
+}
+
+func (c *elastalertConversion) warn(format string, args ...interface{}) {
+	c.Warnings = append(c.Warnings, fmt.Sprintf(format, args...))
+}
+
+// normalizeYAML converts the maps decoded from YAML into JSON compatible maps
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprintf("%v", key)] = normalizeYAML(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+	}
+	return value
+}
+
+// loadElastalertRules loads the rules from a list of YAML files or directories of YAML files
+func loadElastalertRules(paths []string) ([]elastalertRuleFile, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the rules '%s': %v", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, _ := filepath.Glob(filepath.Join(path, pattern)) // #nosec
+			files = append(files, matches...)
+		}
+	}
+	sort.Strings(files)
+
+	var rules []elastalertRuleFile
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file) // #nosec
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the rule file '%s': %v", file, err)
+		}
+		var rule interface{}
+		err = yaml.Unmarshal(content, &rule)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse the rule file '%s': %v", file, err)
+		}
+		m, ok := normalizeYAML(rule).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("The rule file '%s' does not contain a rule", file)
+		}
+		rules = append(rules, elastalertRuleFile{File: file, Rule: m})
+	}
+	return rules, nil
+}
+
+// parseElastalertTimeframe parses an ElastAlert time period such as {minutes: 5}
+func parseElastalertTimeframe(value interface{}) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"weeks": 7 * 24 * time.Hour, "days": 24 * time.Hour, "hours": time.Hour, "minutes": time.Minute, "seconds": time.Second,
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return 0, fmt.Errorf("expected a period such as {minutes: 5}")
+	}
+	var period time.Duration
+	for unit, amount := range m {
+		d, ok := units[unit]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit '%s'", unit)
+		}
+		n, ok := elastalertNumber(amount)
+		if !ok || n <= 0 {
+			return 0, fmt.Errorf("invalid amount of %s '%v'", unit, amount)
+		}
+		period += time.Duration(n * float64(d))
+	}
+	return period, nil
+}
+
+// formatWatcherTime formats a period with the largest Watcher time unit which represents it exactly, e.g. 90m
+func formatWatcherTime(d time.Duration) string {
+	for _, unit := range []struct {
+		suffix   string
+		duration time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if d%unit.duration == 0 {
+			return fmt.Sprintf("%d%s", d/unit.duration, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
+
+func elastalertNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// stringValues converts a comma separated string or a list into a list of strings
+func stringValues(value interface{}) []string {
+	var values []string
+	switch v := value.(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+	}
+	return values
+}
+
+// convertElastalertFilter converts an ElastAlert filter into an Elasticsearch query, unwrapping the 'query' entries
+// and translating the legacy 'and', 'or' and 'not' filters into bool queries
+func convertElastalertFilter(filter interface{}) (interface{}, error) {
+	m, ok := filter.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, fmt.Errorf("filter '%s' must be an object with a single query", encodeJSON(filter))
+	}
+	for key, value := range m {
+		switch key {
+		case "query":
+			return convertElastalertFilter(value)
+		case "not":
+			query, err := convertElastalertFilter(value)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"bool": map[string]interface{}{"must_not": []interface{}{query}}}, nil
+		case "and", "or":
+			items, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("filter '%s' must contain a list of filters", key)
+			}
+			var queries []interface{}
+			for _, item := range items {
+				query, err := convertElastalertFilter(item)
+				if err != nil {
+					return nil, err
+				}
+				queries = append(queries, query)
+			}
+			if key == "and" {
+				return map[string]interface{}{"bool": map[string]interface{}{"filter": queries}}, nil
+			}
+			return map[string]interface{}{"bool": map[string]interface{}{"should": queries, "minimum_should_match": 1}}, nil
+		}
+	}
+	return filter, nil
+}
+
+var elastalertNamePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// elastalertWatchName derives the name of the watch from the name of the rule
+func elastalertWatchName(name string) string {
+	return "elastalert_" + strings.Trim(elastalertNamePattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+var elastalertFormatArgPattern = regexp.MustCompile(`\{(\d*)\}`)
+
+// formatElastalertText converts the python format arguments of an alert text, such as {0}, into mustache
+// references to the fields of the first matching document
+func formatElastalertText(text string, args []string) (string, error) {
+	next := 0
+	var err error
+	formatted := elastalertFormatArgPattern.ReplaceAllStringFunc(text, func(match string) string {
+		index := next
+		if digits := match[1 : len(match)-1]; digits != "" {
+			index, _ = strconv.Atoi(digits)
+		} else {
+			next++
+		}
+		if index >= len(args) {
+			err = fmt.Errorf("format argument %s has no value", match)
+			return match
+		}
+		return fmt.Sprintf("{{ctx.payload.hits.hits.0._source.%s}}", args[index])
+	})
+	return formatted, err
+}
+
+// webhookFromURL builds the settings of a webhook action from its URL
+func webhookFromURL(rawURL string, body string) (map[string]interface{}, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL")
+	}
+	port := 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if u.Port() != "" {
+		port, _ = strconv.Atoi(u.Port())
+	}
+	params := map[string]interface{}{}
+	for key, values := range u.Query() {
+		params[key] = strings.Join(values, ",")
+	}
+	return map[string]interface{}{
+		"scheme":  u.Scheme,
+		"host":    u.Hostname(),
+		"port":    port,
+		"method":  "post",
+		"path":    u.Path,
+		"params":  params,
+		"headers": map[string]interface{}{"Content-Type": "application/json"},
+		"body":    body,
+	}, nil
+}
+
+// convertElastalertAlerts translates the alerters of the rule into watch actions
+func convertElastalertAlerts(rule map[string]interface{}, subject string, text string, conversion *elastalertConversion) map[string]interface{} {
+	actions := map[string]interface{}{}
+	var throttle string
+	if realert, ok := rule["realert"]; ok {
+		period, err := parseElastalertTimeframe(realert)
+		if err != nil {
+			conversion.warn("realert: %v", err)
+		} else {
+			throttle = formatWatcherTime(period)
+		}
+	}
+
+	addWebhook := func(name string, option string, body string) {
+		webhookURL, _ := rule[option].(string)
+		if webhookURL == "" {
+			conversion.warn("alert '%s' requires '%s'", name, option)
+			return
+		}
+		webhook, err := webhookFromURL(webhookURL, body)
+		if err != nil {
+			conversion.warn("%s: %v", option, err)
+			return
+		}
+		actions[name] = map[string]interface{}{"webhook": webhook}
+	}
+
+	for _, alert := range stringValues(rule["alert"]) {
+		switch alert {
+		case "email":
+			to := stringValues(rule["email"])
+			if len(to) == 0 {
+				conversion.warn("alert 'email' requires 'email'")
+				continue
+			}
+			email := map[string]interface{}{"to": to, "subject": subject, "body": map[string]interface{}{"text": text}}
+			if cc := stringValues(rule["cc"]); len(cc) > 0 {
+				email["cc"] = cc
+			}
+			if bcc := stringValues(rule["bcc"]); len(bcc) > 0 {
+				email["bcc"] = bcc
+			}
+			if from, ok := rule["from_addr"].(string); ok {
+				email["from"] = from
+			}
+			actions["email"] = map[string]interface{}{"email": email}
+		case "ms_teams":
+			title := subject
+			if summary, ok := rule["ms_teams_alert_summary"].(string); ok {
+				title = summary
+			}
+			addWebhook("teams_webhook", "ms_teams_webhook_url", encodeJSON(map[string]interface{}{"title": title, "text": text}))
+		case "slack":
+			addWebhook("slack_webhook", "slack_webhook_url", encodeJSON(map[string]interface{}{"text": subject + "\n" + text}))
+		case "post":
+			addWebhook("http_post", "http_post_url", "{{#toJson}}ctx.payload{{/toJson}}")
+		case "debug":
+			actions["log"] = map[string]interface{}{"logging": map[string]interface{}{"text": subject + ": " + text}}
+		default:
+			conversion.warn("alert '%s' is not supported", alert)
+		}
+	}
+
+	if throttle != "" {
+		for _, action := range actions {
+			action.(map[string]interface{})["throttle_period"] = throttle
+		}
+	}
+	return actions
+}
+
+// convertElastalertRule converts an ElastAlert rule into a watch running at the given interval
+func convertElastalertRule(file string, rule map[string]interface{}, interval time.Duration) elastalertConversion {
+	name, _ := rule["name"].(string)
+	conversion := elastalertConversion{File: file, Rule: name}
+	if name == "" {
+		conversion.Rule = filepath.Base(file)
+		conversion.warn("the rule has no name")
+		return conversion
+	}
+	ruleType, _ := rule["type"].(string)
+	if !containsString(elastalertRuleTypes, ruleType) {
+		conversion.warn("rule type '%s' is not supported, expected one of %s", ruleType, strings.Join(elastalertRuleTypes, ", "))
+		return conversion
+	}
+
+	var options []string
+	for option := range rule {
+		if !elastalertOptions[option] {
+			options = append(options, option)
+		}
+	}
+	sort.Strings(options)
+	for _, option := range options {
+		conversion.warn("option '%s' is not translated", option)
+	}
+	if enabled, ok := rule["is_enabled"].(bool); ok && !enabled {
+		conversion.warn("the rule is disabled, deactivate the watch after creating it")
+	}
+
+	indices := stringValues(rule["index"])
+	if len(indices) == 0 {
+		conversion.warn("the rule has no index")
+		return conversion
+	}
+	for i, index := range indices {
+		if strings.Contains(index, "%") {
+			indices[i] = index[:strings.Index(index, "%")] + "*"
+			conversion.warn("index '%s' uses a strftime pattern, replaced with '%s'", index, indices[i])
+		}
+	}
+	timestampField, _ := rule["timestamp_field"].(string)
+	if timestampField == "" {
+		timestampField = "@timestamp"
+	}
+
+	var filters []interface{}
+	ruleFilters, _ := rule["filter"].([]interface{})
+	for _, filter := range ruleFilters {
+		query, err := convertElastalertFilter(filter)
+		if err != nil {
+			conversion.warn("%v", err)
+			return conversion
+		}
+		filters = append(filters, query)
+	}
+
+	window := interval
+	if ruleType == "frequency" || ruleType == "flatline" || ruleType == "spike" {
+		timeframe, err := parseElastalertTimeframe(rule["timeframe"])
+		if err != nil {
+			conversion.warn("timeframe: %v", err)
+			return conversion
+		}
+		window = timeframe
+	}
+	queryKey, _ := rule["query_key"].(string)
+
+	body := map[string]interface{}{}
+	text := fmt.Sprintf("%s: {{ctx.payload.hits.total}} matching events", name)
+	var condition interface{}
+	switch ruleType {
+	case "any":
+		condition = map[string]interface{}{"compare": map[string]interface{}{"ctx.payload.hits.total": map[string]interface{}{"gt": 0}}}
+
+	case "blacklist", "whitelist":
+		compareKey, _ := rule["compare_key"].(string)
+		values := stringValues(rule[ruleType])
+		if compareKey == "" || len(values) == 0 {
+			conversion.warn("rule type '%s' requires 'compare_key' and '%s'", ruleType, ruleType)
+			return conversion
+		}
+		terms := map[string]interface{}{"terms": map[string]interface{}{compareKey: values}}
+		if ruleType == "blacklist" {
+			filters = append(filters, terms)
+		} else {
+			filters = append(filters, map[string]interface{}{"bool": map[string]interface{}{"must_not": []interface{}{terms}}})
+			if ignoreNull, _ := rule["ignore_null"].(bool); ignoreNull {
+				filters = append(filters, map[string]interface{}{"exists": map[string]interface{}{"field": compareKey}})
+			}
+		}
+		condition = map[string]interface{}{"compare": map[string]interface{}{"ctx.payload.hits.total": map[string]interface{}{"gt": 0}}}
+
+	case "frequency":
+		numEvents, ok := elastalertNumber(rule["num_events"])
+		if !ok {
+			conversion.warn("rule type 'frequency' requires 'num_events'")
+			return conversion
+		}
+		if queryKey == "" {
+			condition = map[string]interface{}{"compare": map[string]interface{}{"ctx.payload.hits.total": map[string]interface{}{"gte": numEvents}}}
+			break
+		}
+		body["aggs"] = map[string]interface{}{
+			"by_key": map[string]interface{}{"terms": map[string]interface{}{"field": queryKey, "min_doc_count": numEvents}},
+		}
+		condition = map[string]interface{}{"array_compare": map[string]interface{}{
+			"ctx.payload.aggregations.by_key.buckets": map[string]interface{}{"path": "doc_count", "gte": map[string]interface{}{"value": numEvents}},
+		}}
+		text = fmt.Sprintf("%s:{{#ctx.payload.aggregations.by_key.buckets}} {{key}} ({{doc_count}} events){{/ctx.payload.aggregations.by_key.buckets}}", name)
+
+	case "flatline":
+		threshold, ok := elastalertNumber(rule["threshold"])
+		if !ok {
+			conversion.warn("rule type 'flatline' requires 'threshold'")
+			return conversion
+		}
+		if queryKey != "" {
+			conversion.warn("query_key '%s' is ignored, the flatline is detected across all the events", queryKey)
+		}
+		condition = map[string]interface{}{"compare": map[string]interface{}{"ctx.payload.hits.total": map[string]interface{}{"lt": threshold}}}
+		text = fmt.Sprintf("%s: only {{ctx.payload.hits.total}} events in the last %s", name, formatWatcherTime(window))
+
+	case "spike":
+		height, ok := elastalertNumber(rule["spike_height"])
+		if !ok {
+			conversion.warn("rule type 'spike' requires 'spike_height'")
+			return conversion
+		}
+		if queryKey != "" {
+			conversion.warn("query_key '%s' cannot be translated, the spike is detected across all the events", queryKey)
+		}
+		spikeType, _ := rule["spike_type"].(string)
+		if spikeType == "" {
+			spikeType = "both"
+		}
+		thresholdRef, _ := elastalertNumber(rule["threshold_ref"])
+		thresholdCur, _ := elastalertNumber(rule["threshold_cur"])
+		period := formatWatcherTime(window)
+		body["aggs"] = map[string]interface{}{
+			"windows": map[string]interface{}{"date_range": map[string]interface{}{
+				"field": timestampField,
+				"keyed": true,
+				"ranges": []interface{}{
+					map[string]interface{}{
+						"key":  "reference",
+						"from": fmt.Sprintf("{{ctx.trigger.scheduled_time}}||-%s", formatWatcherTime(2*window)),
+						"to":   fmt.Sprintf("{{ctx.trigger.scheduled_time}}||-%s", period),
+					},
+					map[string]interface{}{
+						"key":  "current",
+						"from": fmt.Sprintf("{{ctx.trigger.scheduled_time}}||-%s", period),
+						"to":   "{{ctx.trigger.scheduled_time}}",
+					},
+				},
+			}},
+		}
+		condition = map[string]interface{}{"script": map[string]interface{}{
+			"lang": "painless",
+			"source": "def buckets = ctx.payload.aggregations.windows.buckets; " +
+				"long ref = buckets.reference.doc_count; long cur = buckets.current.doc_count; " +
+				"if (ref == 0 || ref < params.threshold_ref || cur < params.threshold_cur) { return false; } " +
+				"if (params.spike_type != 'down' && cur >= ref * params.spike_height) { return true; } " +
+				"return params.spike_type != 'up' && cur * params.spike_height <= ref;",
+			"params": map[string]interface{}{
+				"spike_height": height, "spike_type": spikeType, "threshold_ref": thresholdRef, "threshold_cur": thresholdCur,
+			},
+		}}
+		window = 2 * window
+		text = fmt.Sprintf("%s: {{ctx.payload.aggregations.windows.buckets.current.doc_count}} events in the last %s, "+
+			"{{ctx.payload.aggregations.windows.buckets.reference.doc_count}} events in the previous %s", name, period, period)
+	}
+
+	filters = append([]interface{}{map[string]interface{}{"range": map[string]interface{}{
+		timestampField: map[string]interface{}{
+			"gte": fmt.Sprintf("{{ctx.trigger.scheduled_time}}||-%s", formatWatcherTime(window)),
+			"lte": "{{ctx.trigger.scheduled_time}}",
+		},
+	}}}, filters...)
+	body["query"] = map[string]interface{}{"bool": map[string]interface{}{"filter": filters}}
+
+	subject := fmt.Sprintf("ElastAlert rule '%s' matched", name)
+	if value, ok := rule["alert_subject"].(string); ok {
+		formatted, err := formatElastalertText(value, stringValues(rule["alert_subject_args"]))
+		if err != nil {
+			conversion.warn("alert_subject: %v", err)
+		}
+		subject = formatted
+	}
+	if value, ok := rule["alert_text"].(string); ok {
+		formatted, err := formatElastalertText(value, stringValues(rule["alert_text_args"]))
+		if err != nil {
+			conversion.warn("alert_text: %v", err)
+		}
+		text = formatted
+	}
+
+	actions := convertElastalertAlerts(rule, subject, text, &conversion)
+	if len(actions) == 0 {
+		conversion.warn("the rule has no alert which can be translated, the watch has no action")
+	}
+
+	metadata := map[string]interface{}{"elastalert": map[string]interface{}{"rule": name, "type": ruleType}}
+	if description, ok := rule["description"].(string); ok {
+		metadata["description"] = description
+	}
+	conversion.Watch = &Watch{
+		Name: elastalertWatchName(name),
+		Body: map[string]interface{}{
+			"metadata": metadata,
+			"trigger":  map[string]interface{}{"schedule": map[string]interface{}{"interval": formatWatcherTime(interval)}},
+			"input": map[string]interface{}{"search": map[string]interface{}{
+				"request": map[string]interface{}{"indices": indices, "body": body},
+			}},
+			"condition": condition,
+			"actions":   actions,
+		},
+	}
+	return conversion
+}
+
+type convertElastalertCmd struct {
+	rules      string
+	interval   string
+	outputFile string
+}
+
+func (*convertElastalertCmd) Name() string { return "convert-elastalert" }
+func (*convertElastalertCmd) Synopsis() string {
+	return "Convert ElastAlert rules into a watches file"
+}
+
+func (*convertElastalertCmd) Usage() string {
+	return `convert-elastalert [-rules] <rule files or directories> [-interval] <interval> [-output-file] <path to watches file>
+        Translate the any, frequency, blacklist, whitelist, flatline and spike ElastAlert rules into watches, the options
+        which cannot be translated are reported as warnings
+	`
+}
+
+func (c *convertElastalertCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.rules, "rules", "", "Comma separated list of ElastAlert rule files or directories of rule files")
+	f.StringVar(&c.interval, "interval", "1m", "Interval of the watches, as the run_every setting of ElastAlert")
+	f.StringVar(&c.outputFile, "output-file", "", "Path to the watches file (default standard output)")
+}
+
+func (c *convertElastalertCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.rules == "" {
+		fmt.Println("The ElastAlert rules are required")
+		return subcommands.ExitUsageError
+	}
+	interval, err := time.ParseDuration(c.interval)
+	if err != nil || interval < time.Second {
+		fmt.Printf("Invalid interval '%s'\n", c.interval)
+		return subcommands.ExitUsageError
+	}
+
+	rules, err := loadElastalertRules(parseWatchNames(c.rules))
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	cfg := WatcherConfig{Watches: []Watch{}}
+	var skipped int
+	for _, rule := range rules {
+		conversion := convertElastalertRule(rule.File, rule.Rule, interval)
+		for _, warning := range conversion.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: rule '%s' (%s): %s\n", conversion.Rule, conversion.File, warning)
+		}
+		if conversion.Watch == nil {
+			skipped++
+			continue
+		}
+		cfg.Watches = append(cfg.Watches, *conversion.Watch)
+	}
+
+	content, err := marshalIndent(cfg)
+	if err != nil {
+		fmt.Printf("Failed to encode the watches. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	if c.outputFile == "" {
+		fmt.Println(string(content))
+	} else {
+		err = ioutil.WriteFile(c.outputFile, append(content, '\n'), 0600)
+		if err != nil {
+			fmt.Printf("Failed to write the watches file '%s'. Error: %v\n", c.outputFile, err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("Converted %d rules into '%s', %d skipped.\n", len(cfg.Watches), c.outputFile, skipped)
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,286 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var _ = Describe("The elasticwatcher ElastAlert conversion", func() {
+	parseRule := func(rule string) map[string]interface{} {
+		var value interface{}
+		Expect(yaml.Unmarshal([]byte(rule), &value)).Should(Succeed())
+		return normalizeYAML(value).(map[string]interface{})
+	}
+
+	// convert converts a rule and returns the body of the watch as decoded JSON
+	convert := func(rule string) (map[string]interface{}, []string) {
+		conversion := convertElastalertRule("rule.yaml", parseRule(rule), time.Minute)
+		if conversion.Watch == nil {
+			return nil, conversion.Warnings
+		}
+		body, err := normalizeWatchBody(conversion.Watch.Body)
+		Expect(err).ShouldNot(HaveOccurred())
+		return body, conversion.Warnings
+	}
+
+	It("should parse the ElastAlert periods", func() {
+		for period, expected := range map[string]string{
+			"{minutes: 5}":            "5m",
+			"{hours: 1, minutes: 30}": "90m",
+			"{days: 1}":               "1d",
+			"{weeks: 1}":              "7d",
+			"{seconds: 45}":           "45s",
+		} {
+			var value interface{}
+			Expect(yaml.Unmarshal([]byte(period), &value)).Should(Succeed())
+
+			d, err := parseElastalertTimeframe(normalizeYAML(value))
+
+			Expect(err).ShouldNot(HaveOccurred(), period)
+			Expect(formatWatcherTime(d)).Should(Equal(expected), period)
+		}
+
+		_, err := parseElastalertTimeframe(map[string]interface{}{"fortnights": 1})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should convert the legacy filters", func() {
+		var filter interface{}
+		Expect(json.Unmarshal([]byte(`{"or": [
+			{"query": {"query_string": {"query": "response: 500"}}},
+			{"not": {"term": {"host": "web-1"}}}
+		]}`), &filter)).Should(Succeed())
+
+		query, err := convertElastalertFilter(filter)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(encodeJSON(query)).Should(MatchJSON(`{"bool": {"minimum_should_match": 1, "should": [
+			{"query_string": {"query": "response: 500"}},
+			{"bool": {"must_not": [{"term": {"host": "web-1"}}]}}
+		]}}`))
+	})
+
+	It("should convert a frequency rule with its alerts", func() {
+		body, warnings := convert(`
+name: HTTP 500 errors
+type: frequency
+index: logstash-*
+num_events: 50
+timeframe:
+  minutes: 10
+realert:
+  minutes: 30
+filter:
+- term:
+    response: 500
+alert:
+- ms_teams
+- email
+ms_teams_webhook_url: https://outlook.office.com/webhook/abc?team=web
+email: oncall@example.com, web@example.com
+alert_subject: "{} errors on {}"
+alert_subject_args: [response, host]
+`)
+
+		Expect(warnings).Should(BeEmpty())
+		Expect(body["trigger"]).Should(Equal(map[string]interface{}{"schedule": map[string]interface{}{"interval": "1m"}}))
+		Expect(encodeJSON(body["input"])).Should(MatchJSON(`{"search": {"request": {
+			"indices": ["logstash-*"],
+			"body": {"query": {"bool": {"filter": [
+				{"range": {"@timestamp": {"gte": "{{ctx.trigger.scheduled_time}}||-10m", "lte": "{{ctx.trigger.scheduled_time}}"}}},
+				{"term": {"response": 500}}
+			]}}}
+		}}}`))
+		Expect(body["condition"]).Should(Equal(map[string]interface{}{
+			"compare": map[string]interface{}{"ctx.payload.hits.total": map[string]interface{}{"gte": 50.0}},
+		}))
+
+		actions := body["actions"].(map[string]interface{})
+		Expect(actions).Should(HaveLen(2))
+		Expect(encodeJSON(actions["email"])).Should(MatchJSON(`{"throttle_period": "30m", "email": {
+			"to": ["oncall@example.com", "web@example.com"],
+			"subject": "{{ctx.payload.hits.hits.0._source.response}} errors on {{ctx.payload.hits.hits.0._source.host}}",
+			"body": {"text": "HTTP 500 errors: {{ctx.payload.hits.total}} matching events"}
+		}}`))
+		webhook := actions["teams_webhook"].(map[string]interface{})["webhook"].(map[string]interface{})
+		Expect(webhook["host"]).Should(Equal("outlook.office.com"))
+		Expect(webhook["port"]).Should(Equal(443.0))
+		Expect(webhook["path"]).Should(Equal("/webhook/abc"))
+		Expect(webhook["params"]).Should(Equal(map[string]interface{}{"team": "web"}))
+	})
+
+	It("should aggregate a frequency rule by its query key", func() {
+		body, warnings := convert(`
+name: errors per host
+type: frequency
+index: logstash-*
+num_events: 5
+timeframe: {hours: 1}
+query_key: host
+alert: debug
+`)
+
+		Expect(warnings).Should(BeEmpty())
+		Expect(encodeJSON(body["condition"])).Should(MatchJSON(`{"array_compare": {"ctx.payload.aggregations.by_key.buckets": {
+			"path": "doc_count", "gte": {"value": 5}
+		}}}`))
+		search := body["input"].(map[string]interface{})["search"].(map[string]interface{})
+		Expect(encodeJSON(search["request"].(map[string]interface{})["body"].(map[string]interface{})["aggs"])).Should(
+			MatchJSON(`{"by_key": {"terms": {"field": "host", "min_doc_count": 5}}}`))
+	})
+
+	It("should convert the blacklist, whitelist and flatline rules", func() {
+		body, warnings := convert(`
+name: blacklisted users
+type: blacklist
+index: audit-*
+compare_key: user
+blacklist: [root, admin]
+alert: debug
+`)
+		Expect(warnings).Should(BeEmpty())
+		Expect(encodeJSON(body["input"])).Should(ContainSubstring(`{"terms":{"user":["root","admin"]}}`))
+		Expect(encodeJSON(body["input"])).Should(ContainSubstring(`||-1m`))
+
+		body, warnings = convert(`
+name: unknown users
+type: whitelist
+index: audit-*
+compare_key: user
+whitelist: [deploy]
+ignore_null: true
+alert: debug
+`)
+		Expect(warnings).Should(BeEmpty())
+		Expect(encodeJSON(body["input"])).Should(ContainSubstring(`{"bool":{"must_not":[{"terms":{"user":["deploy"]}}]}},{"exists":{"field":"user"}}`))
+
+		body, warnings = convert(`
+name: no logs
+type: flatline
+index: logstash-*
+threshold: 1
+timeframe: {minutes: 15}
+alert: debug
+`)
+		Expect(warnings).Should(BeEmpty())
+		Expect(body["condition"]).Should(Equal(map[string]interface{}{
+			"compare": map[string]interface{}{"ctx.payload.hits.total": map[string]interface{}{"lt": 1.0}},
+		}))
+	})
+
+	It("should convert a spike rule into two windows and a script condition", func() {
+		body, warnings := convert(`
+name: traffic spike
+type: spike
+index: logstash-*
+spike_height: 3
+spike_type: up
+timeframe: {minutes: 30}
+threshold_ref: 10
+alert: debug
+`)
+
+		Expect(warnings).Should(BeEmpty())
+		input := encodeJSON(body["input"])
+		Expect(input).Should(ContainSubstring(`"gte":"{{ctx.trigger.scheduled_time}}||-1h"`))
+		Expect(input).Should(ContainSubstring(`"from":"{{ctx.trigger.scheduled_time}}||-1h","key":"reference","to":"{{ctx.trigger.scheduled_time}}||-30m"`))
+		script := body["condition"].(map[string]interface{})["script"].(map[string]interface{})
+		Expect(script["params"]).Should(Equal(map[string]interface{}{
+			"spike_height": 3.0, "spike_type": "up", "threshold_ref": 10.0, "threshold_cur": 0.0,
+		}))
+	})
+
+	It("should report what cannot be translated", func() {
+		body, warnings := convert(`
+name: new terms
+type: new_term
+index: logstash-*
+`)
+		Expect(body).Should(BeNil())
+		Expect(warnings).Should(HaveLen(1))
+		Expect(warnings[0]).Should(ContainSubstring("rule type 'new_term' is not supported"))
+
+		body, warnings = convert(`
+name: daily errors
+type: any
+index: logstash-%Y.%m.%d
+use_strftime_index: true
+aggregation: {hours: 1}
+alert: [pagerduty]
+`)
+		Expect(body).ShouldNot(BeNil())
+		Expect(warnings).Should(Equal([]string{
+			"option 'aggregation' is not translated",
+			"option 'use_strftime_index' is not translated",
+			"index 'logstash-%Y.%m.%d' uses a strftime pattern, replaced with 'logstash-*'",
+			"alert 'pagerduty' is not supported",
+			"the rule has no alert which can be translated, the watch has no action",
+		}))
+
+		body, warnings = convert(`
+name: missing timeframe
+type: frequency
+index: logstash-*
+num_events: 5
+`)
+		Expect(body).Should(BeNil())
+		Expect(warnings).Should(HaveLen(1))
+	})
+
+	Context("convert-elastalert command", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "elastalert")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(os.Mkdir(filepath.Join(dir, "rules"), 0755)).Should(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "rules", "errors.yaml"), []byte(
+				"name: errors\ntype: any\nindex: logstash-*\nalert: debug\n"), 0644)).Should(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "rules", "terms.yml"), []byte(
+				"name: terms\ntype: new_term\nindex: logstash-*\n"), 0644)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should convert the rules of a directory into a watches file", func() {
+			outputFile := filepath.Join(dir, "watches.json")
+			cmd := &convertElastalertCmd{rules: filepath.Join(dir, "rules"), interval: "5m", outputFile: outputFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+
+			cfg, err := loadWatches(outputFile)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(cfg.Watches).Should(HaveLen(1))
+			Expect(cfg.Watches[0].Name).Should(Equal("elastalert_errors"))
+			Expect(validateWatches(cfg)).Should(BeEmpty())
+		})
+
+		It("should fail on a missing rule file", func() {
+			cmd := &convertElastalertCmd{rules: filepath.Join(dir, "missing.yaml"), interval: "1m"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+
+		It("should reject an invalid interval", func() {
+			cmd := &convertElastalertCmd{rules: filepath.Join(dir, "rules"), interval: "soon"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+		})
+	})
+})
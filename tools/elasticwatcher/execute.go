@@ -0,0 +1,292 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/subcommands"
+	"github.com/oliveagle/jsonpath"
+)
+
+// actionModes the modes in which Watcher can run an action during an execution
+var actionModes = map[string]bool{
+	"simulate":       true,
+	"force_simulate": true,
+	"execute":        true,
+	"force_execute":  true,
+	"skip":           true,
+}
+
+// parseActionModes parses a comma separated list of <action id>=<mode> pairs
+func parseActionModes(modes string) (map[string]string, error) {
+	result := make(map[string]string)
+	if strings.TrimSpace(modes) == "" {
+		return result, nil
+	}
+	for _, pair := range strings.Split(modes, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid action mode '%s', expected <action id>=<mode>", pair)
+		}
+		action := strings.TrimSpace(parts[0])
+		mode := strings.TrimSpace(parts[1])
+		if !actionModes[mode] {
+			return nil, fmt.Errorf("Invalid mode '%s' for action '%s'", mode, action)
+		}
+		result[action] = mode
+	}
+	return result, nil
+}
+
+func lookupJSONPath(record interface{}, path string) interface{} {
+	value, err := jsonpath.JsonPathLookup(record, path)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// hitsTotal extracts the total number of hits from a search payload, which is either a number
+// or an object with a value in newer versions of Elasticsearch
+func hitsTotal(total interface{}) interface{} {
+	if t, ok := total.(map[string]interface{}); ok {
+		return t["value"]
+	}
+	return total
+}
+
+// summarizeWatchRecord builds a compact summary of the input, condition and actions of a watch record
+func summarizeWatchRecord(record interface{}) []string {
+	summary := []string{
+		fmt.Sprintf("Watch:     %v", lookupJSONPath(record, "$.watch_id")),
+		fmt.Sprintf("State:     %v", lookupJSONPath(record, "$.state")),
+	}
+
+	if input := lookupJSONPath(record, "$.result.input"); input != nil {
+		line := fmt.Sprintf("Input:     %v (%v)", lookupJSONPath(input, "$.type"), lookupJSONPath(input, "$.status"))
+		if total := lookupJSONPath(input, "$.payload.hits.total"); total != nil {
+			line += fmt.Sprintf(", hits: %v", hitsTotal(total))
+		}
+		summary = append(summary, line)
+	}
+
+	if condition := lookupJSONPath(record, "$.result.condition"); condition != nil {
+		summary = append(summary, fmt.Sprintf("Condition: %v (%v), met: %v",
+			lookupJSONPath(condition, "$.type"), lookupJSONPath(condition, "$.status"),
+			lookupJSONPath(condition, "$.met")))
+	}
+
+	if actions, ok := lookupJSONPath(record, "$.result.actions").([]interface{}); ok {
+		summary = append(summary, "Actions:")
+		for _, action := range actions {
+			line := fmt.Sprintf("  %v (%v): %v", lookupJSONPath(action, "$.id"),
+				lookupJSONPath(action, "$.type"), lookupJSONPath(action, "$.status"))
+			if reason := lookupJSONPath(action, "$.reason"); reason != nil {
+				line += fmt.Sprintf(", reason: %v", reason)
+			}
+			if reason := lookupJSONPath(action, "$.error.reason"); reason != nil {
+				line += fmt.Sprintf(", error: %v", reason)
+			}
+			summary = append(summary, line)
+		}
+	}
+	return summary
+}
+
+func loadJSONFile(file string) (interface{}, error) {
+	content, err := ioutil.ReadFile(file) // #nosec
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the file '%s': %v", file, err)
+	}
+	var value interface{}
+	err = json.Unmarshal(content, &value)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal the file '%s': %v", file, err)
+	}
+	return value, nil
+}
+
+type executeCmd struct {
+	host                 string
+	port                 int
+	authFile             string
+	watch                string
+	watchesFile          string
+	triggerData          string
+	alternativeInputFile string
+	ignoreCondition      bool
+	recordExecution      bool
+	actionModes          string
+	secretsFile          string
+}
+
+func (*executeCmd) Name() string { return "execute" }
+func (*executeCmd) Synopsis() string {
+	return "Execute a watch in Elasticsearch Watcher"
+}
+
+func (*executeCmd) Usage() string {
+	return `execute [-host] <host name> [-port] <port> [-watch] <watch name> [-watches-file] <path to watches file> [-trigger-data] <JSON>
+        [-alternative-input-file] <path to JSON file> [-ignore-condition] [-record-execution] [-action-modes] <action=mode,...> [-auth-file] <path to basic auth file>
+        [-secrets-file] <path to secrets file>
+        Execute an installed watch or, when a watches file is provided, the watch with the same name from the file.
+        The ${secret:name} placeholders of the watch from the file are resolved from the secrets file or from the
+        ELASTICWATCHER_SECRET_<NAME> environment variables.
+	`
+}
+
+func (e *executeCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&e.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&e.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&e.watch, "watch", "", "Name of the watch")
+	f.StringVar(&e.watchesFile, "watches-file", "", "Path to watches file, the watch is executed inline from this file")
+	f.StringVar(&e.triggerData, "trigger-data", "", "JSON object with the trigger data, e.g. {\"scheduled_time\":\"now\"}")
+	f.StringVar(&e.alternativeInputFile, "alternative-input-file", "", "Path to a JSON file used as the input payload instead of the watch input")
+	f.BoolVar(&e.ignoreCondition, "ignore-condition", false, "Ignore the watch condition and run the actions")
+	f.BoolVar(&e.recordExecution, "record-execution", false, "Record the execution in the watch history, not supported with -watches-file")
+	f.StringVar(&e.actionModes, "action-modes", "",
+		"Comma separated list of <action id>=<mode>, where mode is simulate, force_simulate, execute, force_execute or skip. Use _all for every action")
+	f.StringVar(&e.authFile, "auth-file", "", "Path to basic auth file")
+	f.StringVar(&e.secretsFile, "secrets-file", "", "Path to the JSON file with the secrets referenced by the ${secret:name} placeholders")
+}
+
+// buildRequest builds the body of the execute watch API request
+func (e *executeCmd) buildRequest() (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+
+	if e.watchesFile != "" {
+		cfg, err := loadWatches(e.watchesFile)
+		if err != nil {
+			return nil, err
+		}
+		secrets, err := loadSecrets(e.secretsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, watch := range cfg.Watches {
+			if watch.Name != e.watch {
+				continue
+			}
+			injected, err := injectSecrets([]Watch{watch}, secrets)
+			if err != nil {
+				return nil, err
+			}
+			body["watch"] = injected[0].Body
+		}
+		if body["watch"] == nil {
+			return nil, fmt.Errorf("Watch '%s' not found in the watches file", e.watch)
+		}
+	}
+
+	if e.triggerData != "" {
+		var triggerData interface{}
+		err := json.Unmarshal([]byte(e.triggerData), &triggerData)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse the trigger data: %v", err)
+		}
+		body["trigger_data"] = triggerData
+	}
+
+	if e.alternativeInputFile != "" {
+		input, err := loadJSONFile(e.alternativeInputFile)
+		if err != nil {
+			return nil, err
+		}
+		body["alternative_input"] = input
+	}
+
+	modes, err := parseActionModes(e.actionModes)
+	if err != nil {
+		return nil, err
+	}
+	if len(modes) > 0 {
+		body["action_modes"] = modes
+	}
+
+	if e.ignoreCondition {
+		body["ignore_condition"] = true
+	}
+	if e.recordExecution {
+		body["record_execution"] = true
+	}
+	return body, nil
+}
+
+func (e *executeCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if e.watch == "" {
+		fmt.Println("The name of the watch is required")
+		return subcommands.ExitUsageError
+	}
+	// Elasticsearch rejects recording the execution of an inline watch
+	if e.recordExecution && e.watchesFile != "" {
+		fmt.Println("The -record-execution flag cannot be used with -watches-file, inline watches cannot be recorded")
+		return subcommands.ExitUsageError
+	}
+	if e.watchesFile != "" {
+		if _, err := os.Stat(e.watchesFile); os.IsNotExist(err) {
+			fmt.Printf("Watches file '%s' not found\n", e.watchesFile)
+			return subcommands.ExitFailure
+		}
+	}
+
+	body, err := e.buildRequest()
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	// Inline watches are executed without an ID, which also prevents them from being recorded
+	fragment := e.watch + "/_execute"
+	if body["watch"] != nil {
+		fragment = "_execute"
+	}
+	watcherURL := buildWatcherURL(e.host, e.port, fragment)
+
+	statusCode, content, err := doRequest(http.MethodPost, watcherURL, e.authFile, body)
+	if err != nil {
+		fmt.Printf("Failed to execute the watch '%s'. Error: %v\n", e.watch, err)
+		return subcommands.ExitFailure
+	}
+	if statusCode != http.StatusOK {
+		fmt.Printf("Failed to execute the watch '%s'. Status Code: %d. Error: %v\n", e.watch, statusCode, string(content))
+		return subcommands.ExitFailure
+	}
+
+	var resp struct {
+		WatchRecord interface{} `json:"watch_record"`
+	}
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		fmt.Printf("Failed to parse the response. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+
+	for _, line := range summarizeWatchRecord(resp.WatchRecord) {
+		fmt.Println(line)
+	}
+	fmt.Println()
+
+	var prettyContent bytes.Buffer
+	err = json.Indent(&prettyContent, content, "", "    ")
+	if err != nil {
+		fmt.Printf("Failed to indent the content of the execute response. Error: %v", err)
+		return subcommands.ExitFailure
+	}
+
+	fmt.Println("Watch record:")
+	fmt.Print(string(prettyContent.Bytes()))
+	fmt.Println()
+	return subcommands.ExitSuccess
+}
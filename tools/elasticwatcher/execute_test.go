@@ -0,0 +1,210 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher execute", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	var watchesFile *os.File
+	const watcherEndpoint = "/_xpack/watcher/watch"
+	const WatchName = "watch_test"
+	const Watches = `{"watches": [{"name": "watch_test", "body": {"trigger": {"schedule": {"interval": "5m"}}}}]}`
+	const ExecuteResponse = `{"_id": "watch_test_1", "watch_record": {
+		"watch_id": "watch_test",
+		"state": "executed",
+		"result": {
+			"input": {"type": "search", "status": "success", "payload": {"hits": {"total": 3}}},
+			"condition": {"type": "compare", "status": "success", "met": true},
+			"actions": [{"id": "teams_webhook", "type": "webhook", "status": "simulated"}]
+		}}}`
+
+	Context("action modes", func() {
+		It("should parse the action modes", func() {
+			modes, err := parseActionModes("teams_webhook=simulate, email=skip")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(modes).Should(Equal(map[string]string{"teams_webhook": "simulate", "email": "skip"}))
+		})
+
+		It("should reject unknown modes", func() {
+			_, err := parseActionModes("teams_webhook=run")
+
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("watch record summary", func() {
+		It("should summarize the input, condition and actions", func() {
+			var resp map[string]interface{}
+			Expect(json.Unmarshal([]byte(ExecuteResponse), &resp)).Should(Succeed())
+
+			summary := summarizeWatchRecord(resp["watch_record"])
+
+			Expect(summary).Should(Equal([]string{
+				"Watch:     watch_test",
+				"State:     executed",
+				"Input:     search (success), hits: 3",
+				"Condition: compare (success), met: true",
+				"Actions:",
+				"  teams_webhook (webhook): simulated",
+			}))
+		})
+	})
+
+	Context("execute command", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			elasticHost = host
+			elasticPort, err = strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			watchesFile, err = ioutil.TempFile("", "watches")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = watchesFile.Write([]byte(Watches))
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if watchesFile != nil {
+				os.Remove(watchesFile.Name())
+			}
+			server.Close()
+		})
+
+		It("should execute an installed watch", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", watcherEndpoint+"/"+WatchName+"/_execute"),
+					ghttp.VerifyJSON(`{"action_modes": {"_all": "simulate"}, "ignore_condition": true,
+						"trigger_data": {"scheduled_time": "now"}}`),
+					ghttp.RespondWith(http.StatusOK, ExecuteResponse),
+				),
+			)
+
+			cmd := &executeCmd{
+				host:            elasticHost,
+				port:            elasticPort,
+				watch:           WatchName,
+				triggerData:     `{"scheduled_time": "now"}`,
+				ignoreCondition: true,
+				actionModes:     "_all=simulate"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should execute an inline watch from the watches file", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", watcherEndpoint+"/_execute"),
+					ghttp.VerifyJSON(`{"watch": {"trigger": {"schedule": {"interval": "5m"}}}}`),
+					ghttp.RespondWith(http.StatusOK, ExecuteResponse),
+				),
+			)
+
+			cmd := &executeCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watch:       WatchName,
+				watchesFile: watchesFile.Name()}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should resolve the secret placeholders of an inline watch", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", watcherEndpoint+"/_execute"),
+					ghttp.VerifyJSON(`{"watch": {"actions": {"teams": {"webhook": {"path": "/webhook/abc123"}}}}}`),
+					ghttp.RespondWith(http.StatusOK, ExecuteResponse),
+				),
+			)
+			os.Setenv("ELASTICWATCHER_SECRET_TEAMS_WEBHOOK", "/webhook/abc123")
+			defer os.Unsetenv("ELASTICWATCHER_SECRET_TEAMS_WEBHOOK")
+
+			file, err := ioutil.TempFile("", "watches")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.Remove(file.Name())
+			_, err = file.Write([]byte(`{"watches": [{"name": "` + WatchName + `",
+				"body": {"actions": {"teams": {"webhook": {"path": "${secret:teams_webhook}"}}}}}]}`))
+			Expect(err).ShouldNot(HaveOccurred())
+			file.Close()
+
+			cmd := &executeCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watch:       WatchName,
+				watchesFile: file.Name()}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should fail when a secret of an inline watch is not defined", func() {
+			file, err := ioutil.TempFile("", "watches")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.Remove(file.Name())
+			_, err = file.Write([]byte(`{"watches": [{"name": "` + WatchName + `",
+				"body": {"actions": {"teams": {"webhook": {"path": "${secret:undefined_webhook}"}}}}}]}`))
+			Expect(err).ShouldNot(HaveOccurred())
+			file.Close()
+
+			cmd := &executeCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watch:       WatchName,
+				watchesFile: file.Name()}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+
+		It("should reject recording the execution of an inline watch", func() {
+			cmd := &executeCmd{
+				host:            elasticHost,
+				port:            elasticPort,
+				watch:           WatchName,
+				watchesFile:     watchesFile.Name(),
+				recordExecution: true}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitUsageError))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,124 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/google/subcommands"
+)
+
+// exportWatches retrieves the definitions of the installed watches whose ID matches the glob pattern.
+// The known secret values are replaced with their placeholders, such that the watches can be created again
+// with the same secrets. The JSON paths of the sensitive fields which are left in clear are returned as warnings.
+func exportWatches(host string, port int, authFile string, pattern string, secrets map[string]string) (*WatcherConfig, []string, error) {
+	installed, err := fetchInstalledWatches(host, port, authFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(installed))
+	for _, watch := range installed {
+		names = append(names, watch.ID)
+	}
+	selection := watchSelection{match: pattern, all: pattern == ""}
+	names, err = selection.filterWatchNames(names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &WatcherConfig{Watches: []Watch{}}
+	var warnings []string
+	for _, name := range names {
+		body, found, err := fetchWatch(host, port, authFile, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !found {
+			// The watch was deleted after it was listed
+			continue
+		}
+		removeOwnership(body)
+		stripWatchDefaults(nil, body, nil)
+		masked := maskSecretValues(body, secrets)
+		for _, path := range unresolvedSensitivePaths(masked, "$") {
+			warnings = append(warnings, fmt.Sprintf("watch '%s': %s is not a secret placeholder and is exported in clear", name, path))
+		}
+		cfg.Watches = append(cfg.Watches, Watch{Name: name, Body: masked})
+	}
+	return cfg, warnings, nil
+}
+
+type exportCmd struct {
+	host        string
+	port        int
+	authFile    string
+	match       string
+	outputFile  string
+	secretsFile string
+}
+
+func (*exportCmd) Name() string { return "export" }
+func (*exportCmd) Synopsis() string {
+	return "Export the watches installed in Elasicsearch Watcher into a watches file"
+}
+
+func (*exportCmd) Usage() string {
+	return `export [-host] <host name> [-port] <port> [-match] <glob pattern> [-output-file] <path to watches file> [-auth-file] <path to basic auth file>
+        [-secrets-file] <path to secrets file>
+        Export the installed watches into a watches file which can be used with the create command. The known secret
+        values are replaced with their placeholders. The other values of the sensitive fields, e.g. passwords, are
+        exported in clear with a warning.
+	`
+}
+
+func (e *exportCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&e.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&e.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&e.match, "match", "", "Glob pattern selecting the watches to export, e.g. watch_http_* (default all watches)")
+	f.StringVar(&e.outputFile, "output-file", "", "Path to the exported watches file (default standard output)")
+	f.StringVar(&e.authFile, "auth-file", "", "Path to basic auth file")
+	f.StringVar(&e.secretsFile, "secrets-file", "", "Path to the JSON file with the secrets referenced by the ${secret:name} placeholders")
+}
+
+func (e *exportCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	secrets, err := loadSecrets(e.secretsFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	cfg, warnings, err := exportWatches(e.host, e.port, e.authFile, e.match, secrets)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	content, err := marshalIndent(cfg)
+	if err != nil {
+		fmt.Printf("Failed to encode the watches. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+
+	if e.outputFile == "" {
+		fmt.Println(string(content))
+		return subcommands.ExitSuccess
+	}
+
+	err = ioutil.WriteFile(e.outputFile, append(content, '\n'), 0600)
+	if err != nil {
+		fmt.Printf("Failed to write the watches file '%s'. Error: %v\n", e.outputFile, err)
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("Exported %d watches to '%s'.\n", len(cfg.Watches), e.outputFile)
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,201 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher export", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	var outputFile string
+	const watcherEndpoint = "/_xpack/watcher/watch"
+	const watchesSearchEndpoint = "/.watches/_search"
+	const SearchResponse = `{"hits": {"hits": [{"_id": "watch_http_404"}, {"_id": "watch_disk"}]}}`
+	const WatchResponse = `{"found": true, "_id": "watch_http_404", "_version": 2,
+		"status": {"state": {"active": true}},
+		"watch": {
+			"trigger": {"schedule": {"interval": "15m"}},
+			"input": {"search": {"request": {"search_type": "query_then_fetch", "indices": ["logstash-*"], "types": []}}},
+			"condition": {"always": {}},
+			"actions": {"log": {"logging": {"text": "{{ctx.watch_id}}"}}}
+		}}`
+	const ExportedWatch = `{
+		"trigger": {"schedule": {"interval": "15m"}},
+		"input": {"search": {"request": {"indices": ["logstash-*"]}}},
+		"condition": {"always": {}},
+		"actions": {"log": {"logging": {"text": "{{ctx.watch_id}}"}}}
+	}`
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		u, err := url.Parse(server.URL())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		host, port, err := net.SplitHostPort(u.Host)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		elasticHost = host
+		elasticPort, err = strconv.Atoi(port)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		file, err := ioutil.TempFile("", "export")
+		Expect(err).ShouldNot(HaveOccurred())
+		outputFile = file.Name()
+		file.Close()
+	})
+
+	AfterEach(func() {
+		os.Remove(outputFile)
+		server.Close()
+	})
+
+	Context("export command", func() {
+		It("should export the matching watches in a file which can be created again", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_http_404"),
+					ghttp.RespondWith(http.StatusOK, WatchResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_http_404"),
+					verifyStampedWatch(ExportedWatch),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			exportCmd := &exportCmd{
+				host:       elasticHost,
+				port:       elasticPort,
+				match:      "watch_http_*",
+				outputFile: outputFile}
+
+			Expect(exportCmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+
+			cfg, err := loadWatches(outputFile)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(cfg.Watches).Should(HaveLen(1))
+			Expect(cfg.Watches[0].Name).Should(Equal("watch_http_404"))
+
+			createCmd := &createCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: outputFile}
+
+			Expect(createCmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+
+		It("should export the known secrets as placeholders which are resolved by create", func() {
+			const SecretWatchResponse = `{"found": true, "_id": "watch_http_404",
+				"watch": {
+					"trigger": {"schedule": {"interval": "15m"}},
+					"input": {"search": {"request": {"indices": ["logstash-*"],
+						"body": {"query": {"term": {"auth_token_type": "bearer"}}}}}},
+					"actions": {"teams": {"webhook": {"host": "outlook.office.com", "path": "/webhook",
+						"headers": {"Authorization": "Bearer abc123"}}}}
+				}}`
+			const CreatedWatch = `{
+				"trigger": {"schedule": {"interval": "15m"}},
+				"input": {"search": {"request": {"indices": ["logstash-*"],
+					"body": {"query": {"term": {"auth_token_type": "bearer"}}}}}},
+				"actions": {"teams": {"webhook": {"host": "outlook.office.com", "path": "/webhook",
+					"headers": {"Authorization": "Bearer abc123"}}}}
+			}`
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_http_404"),
+					ghttp.RespondWith(http.StatusOK, SecretWatchResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_http_404"),
+					verifyStampedWatch(CreatedWatch),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			secretsFile, err := ioutil.TempFile("", "secrets")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.Remove(secretsFile.Name())
+			_, err = secretsFile.Write([]byte(`{"teams_token": "abc123"}`))
+			Expect(err).ShouldNot(HaveOccurred())
+			secretsFile.Close()
+
+			exportCmd := &exportCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				match:       "watch_http_*",
+				outputFile:  outputFile,
+				secretsFile: secretsFile.Name()}
+
+			Expect(exportCmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+
+			cfg, err := loadWatches(outputFile)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(cfg.Watches).Should(HaveLen(1))
+			headers := lookupJSONPath(cfg.Watches[0].Body, "$.actions.teams.webhook.headers")
+			Expect(headers).Should(Equal(map[string]interface{}{"Authorization": "Bearer ${secret:teams_token}"}))
+
+			createCmd := &createCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: outputFile,
+				secretsFile: secretsFile.Name()}
+
+			Expect(createCmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+
+		It("should report the sensitive fields which are exported in clear", func() {
+			body := map[string]interface{}{
+				"input": map[string]interface{}{"http": map[string]interface{}{"request": map[string]interface{}{
+					"auth":    map[string]interface{}{"basic": map[string]interface{}{"username": "elastic", "password": "changeme"}},
+					"headers": map[string]interface{}{"Authorization": "Bearer ${secret:token}"},
+				}}},
+			}
+
+			Expect(unresolvedSensitivePaths(body, "$")).Should(Equal([]string{"$.input.http.request.auth.basic.password"}))
+		})
+
+		It("should reject an invalid pattern", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+			)
+
+			cmd := &exportCmd{
+				host:  elasticHost,
+				port:  elasticPort,
+				match: "watch_[",
+			}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+	})
+})
@@ -0,0 +1,224 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/subcommands"
+)
+
+// historyStates the execution states of a watch record
+var historyStates = []string{
+	"awaits_execution", "checking", "execution_not_needed", "throttled", "executed",
+	"failed", "deleted_while_queued", "not_executed_already_queued", "executed_multiple_times",
+}
+
+// buildHistoryQuery builds the search request for the watch records in the history indices
+func buildHistoryQuery(watches []string, since string, until string, states []string, size int) map[string]interface{} {
+	filters := []interface{}{}
+	if len(watches) > 0 {
+		filters = append(filters, map[string]interface{}{
+			"terms": map[string]interface{}{"watch_id": watches},
+		})
+	}
+
+	timeRange := map[string]interface{}{}
+	if since != "" {
+		timeRange["gte"] = since
+	}
+	if until != "" {
+		timeRange["lte"] = until
+	}
+	if len(timeRange) > 0 {
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{"trigger_event.triggered_time": timeRange},
+		})
+	}
+
+	if len(states) > 0 {
+		filters = append(filters, map[string]interface{}{
+			"terms": map[string]interface{}{"state": states},
+		})
+	}
+
+	return map[string]interface{}{
+		"size": size,
+		"sort": []interface{}{
+			map[string]interface{}{"trigger_event.triggered_time": map[string]interface{}{"order": "desc"}},
+		},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"filter": filters},
+		},
+	}
+}
+
+// watchRecordErrors collects the error messages of a watch record
+func watchRecordErrors(record interface{}) []string {
+	var errors []string
+	if messages, ok := lookupJSONPath(record, "$.messages").([]interface{}); ok {
+		for _, message := range messages {
+			errors = append(errors, fmt.Sprintf("%v", message))
+		}
+	}
+	for _, path := range []string{"$.exception.reason", "$.result.input.error.reason", "$.result.condition.error.reason"} {
+		if reason := lookupJSONPath(record, path); reason != nil {
+			errors = append(errors, fmt.Sprintf("%v", reason))
+		}
+	}
+	if actions, ok := lookupJSONPath(record, "$.result.actions").([]interface{}); ok {
+		for _, action := range actions {
+			if reason := lookupJSONPath(action, "$.error.reason"); reason != nil {
+				errors = append(errors, fmt.Sprintf("%v: %v", lookupJSONPath(action, "$.id"), reason))
+			}
+		}
+	}
+	return errors
+}
+
+// watchRecordActions formats the status of each action of a watch record
+func watchRecordActions(record interface{}) string {
+	var statuses []string
+	if actions, ok := lookupJSONPath(record, "$.result.actions").([]interface{}); ok {
+		for _, action := range actions {
+			statuses = append(statuses, fmt.Sprintf("%v=%v", lookupJSONPath(action, "$.id"), lookupJSONPath(action, "$.status")))
+		}
+	}
+	if len(statuses) == 0 {
+		return "-"
+	}
+	return strings.Join(statuses, ",")
+}
+
+// formatOptional formats a value which may be missing, a missing or empty value is printed as '-'
+func formatOptional(value interface{}) string {
+	if value == nil || value == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+type historyCmd struct {
+	host     string
+	port     int
+	authFile string
+	watches  string
+	since    string
+	until    string
+	states   string
+	size     int
+	output   string
+}
+
+func (*historyCmd) Name() string { return "history" }
+func (*historyCmd) Synopsis() string {
+	return "Query the execution records of watches from the Watcher history"
+}
+
+func (*historyCmd) Usage() string {
+	return `history [-host] <host name> [-port] <port> [-watches] <comma separated list of watches> [-since] <time> [-until] <time>
+        [-state] <comma separated list of states> [-size] <number of records> [-output] <table|json> [-auth-file] <path to basic auth file>
+        Query the execution records of watches from the Watcher history indices. The times can be dates or date math expressions such as now-1h
+	`
+}
+
+func (h *historyCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&h.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&h.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&h.watches, "watches", "", "Comma separated list with watches names (default all watches)")
+	f.StringVar(&h.since, "since", "now-1d", "Only records triggered at or after this time")
+	f.StringVar(&h.until, "until", "", "Only records triggered at or before this time")
+	f.StringVar(&h.states, "state", "", "Comma separated list of execution states, e.g. executed, execution_not_needed, failed, throttled")
+	f.IntVar(&h.size, "size", 100, "Maximum number of records")
+	f.StringVar(&h.output, "output", "table", "Output format: table or json")
+	f.StringVar(&h.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (h *historyCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if h.output != "table" && h.output != "json" {
+		fmt.Printf("Unknown output format '%s'\n", h.output)
+		return subcommands.ExitUsageError
+	}
+
+	var watches, states []string
+	if h.watches != "" {
+		watches = parseWatchNames(h.watches)
+	}
+	if h.states != "" {
+		states = parseWatchNames(h.states)
+		for _, state := range states {
+			if !containsString(historyStates, state) {
+				fmt.Printf("Unknown state '%s', expected one of %s\n", state, strings.Join(historyStates, ", "))
+				return subcommands.ExitUsageError
+			}
+		}
+	}
+
+	query := buildHistoryQuery(watches, h.since, h.until, states, h.size)
+	searchURL := buildWatcherHistorySearchURL(h.host, h.port)
+	statusCode, content, err := doRequest(http.MethodPost, searchURL, h.authFile, query)
+	if err != nil {
+		fmt.Printf("Failed to query the watcher history. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	if statusCode != http.StatusOK {
+		fmt.Printf("Failed to query the watcher history. Status Code: %d. Error: %v\n", statusCode, string(content))
+		return subcommands.ExitFailure
+	}
+
+	var resp struct {
+		Hits struct {
+			Hits []struct {
+				Source interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		fmt.Printf("Failed to parse the response. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+
+	records := make([]interface{}, 0, len(resp.Hits.Hits))
+	for _, hit := range resp.Hits.Hits {
+		records = append(records, hit.Source)
+	}
+
+	if h.output == "json" {
+		content, err := json.MarshalIndent(records, "", "    ")
+		if err != nil {
+			fmt.Printf("Failed to encode the watch records. Error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+		fmt.Println(string(content))
+		return subcommands.ExitSuccess
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TRIGGERED\tWATCH\tSTATE\tCONDITION MET\tACTIONS\tERRORS")
+	for _, record := range records {
+		errors := "-"
+		if messages := watchRecordErrors(record); len(messages) > 0 {
+			errors = strings.Join(messages, "; ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatOptional(lookupJSONPath(record, "$.trigger_event.triggered_time")),
+			formatOptional(lookupJSONPath(record, "$.watch_id")),
+			formatOptional(lookupJSONPath(record, "$.state")),
+			formatOptional(lookupJSONPath(record, "$.result.condition.met")),
+			watchRecordActions(record),
+			errors)
+	}
+	w.Flush()
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,152 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher history", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	const historyEndpoint = "/.watcher-history-*/_search"
+	const Record = `{
+		"watch_id": "watch_test",
+		"state": "executed",
+		"trigger_event": {"triggered_time": "2018-03-20T10:00:00.000Z"},
+		"result": {
+			"condition": {"met": true},
+			"actions": [
+				{"id": "teams_webhook", "status": "failure", "error": {"reason": "connection refused"}},
+				{"id": "log", "status": "success"}
+			]
+		},
+		"messages": ["failed to execute action"]
+	}`
+	var HistoryResponse = `{"hits": {"hits": [{"_source": ` + Record + `}]}}`
+
+	Context("watch records", func() {
+		It("should extract the actions and the errors", func() {
+			var record interface{}
+			Expect(json.Unmarshal([]byte(Record), &record)).Should(Succeed())
+
+			Expect(watchRecordActions(record)).Should(Equal("teams_webhook=failure,log=success"))
+			Expect(watchRecordErrors(record)).Should(Equal([]string{
+				"failed to execute action",
+				"teams_webhook: connection refused",
+			}))
+		})
+	})
+
+	Context("history query", func() {
+		It("should query all the records without filters", func() {
+			content, err := json.Marshal(buildHistoryQuery(nil, "", "", nil, 10))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(content).Should(MatchJSON(`{
+				"size": 10,
+				"sort": [{"trigger_event.triggered_time": {"order": "desc"}}],
+				"query": {"bool": {"filter": []}}
+			}`))
+		})
+	})
+
+	Context("history command", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			elasticHost = host
+			elasticPort, err = strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should query the history with the filters", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", historyEndpoint),
+					ghttp.VerifyJSON(`{
+						"size": 10,
+						"sort": [{"trigger_event.triggered_time": {"order": "desc"}}],
+						"query": {"bool": {"filter": [
+							{"terms": {"watch_id": ["watch_test"]}},
+							{"range": {"trigger_event.triggered_time": {"gte": "now-1h", "lte": "now"}}},
+							{"terms": {"state": ["executed", "failed"]}}
+						]}}
+					}`),
+					ghttp.RespondWith(http.StatusOK, HistoryResponse),
+				),
+			)
+
+			cmd := &historyCmd{
+				host:    elasticHost,
+				port:    elasticPort,
+				watches: "watch_test",
+				since:   "now-1h",
+				until:   "now",
+				states:  "executed,failed",
+				size:    10,
+				output:  "table"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should print the records as JSON", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", historyEndpoint),
+					ghttp.RespondWith(http.StatusOK, HistoryResponse),
+				),
+			)
+
+			cmd := &historyCmd{
+				host:   elasticHost,
+				port:   elasticPort,
+				size:   100,
+				output: "json"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should reject unknown states", func() {
+			cmd := &historyCmd{
+				host:   elasticHost,
+				port:   elasticPort,
+				states: "fired",
+				output: "table"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitUsageError))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+	})
+})
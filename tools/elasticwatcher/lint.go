@@ -0,0 +1,533 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// fieldTypes the mapped types of the fields, indexed by their full path, e.g. kubernetes.pod.name
+type fieldTypes map[string]map[string]bool
+
+// clusterTemplate an index template installed in the cluster
+type clusterTemplate struct {
+	Name     string
+	Patterns []string
+	Fields   fieldTypes
+}
+
+// clusterMappings the indices, their field mappings, their aliases and the index templates of the cluster
+type clusterMappings struct {
+	Indices map[string]fieldTypes
+	// Aliases the indices of each alias
+	Aliases   map[string][]string
+	Templates []clusterTemplate
+}
+
+// fieldUsage a field used by a watch, the usage determines which field types are compatible
+type fieldUsage struct {
+	Field string
+	Usage string
+	Path  string
+}
+
+// numericFieldTypes the numeric field types of Elasticsearch
+var numericFieldTypes = []string{"long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float"}
+
+// sourceFieldPattern matches the compare paths referring to a field of a search hit
+var sourceFieldPattern = regexp.MustCompile(`^ctx\.payload\.hits\.hits\.\d+\._source\.(.+)$`)
+
+// flattenMapping collects the types of the fields of a mapping, including the object and the multi-fields
+func flattenMapping(properties interface{}, prefix string, fields fieldTypes) {
+	m, _ := properties.(map[string]interface{})
+	for name, item := range m {
+		definition, _ := item.(map[string]interface{})
+		path := prefix + name
+		fieldType, _ := definition["type"].(string)
+		if fieldType == "" {
+			fieldType = "object"
+		}
+		if fields[path] == nil {
+			fields[path] = map[string]bool{}
+		}
+		fields[path][fieldType] = true
+		if nested, ok := definition["properties"]; ok {
+			flattenMapping(nested, path+".", fields)
+		}
+		if multiFields, ok := definition["fields"]; ok {
+			flattenMapping(multiFields, path+".", fields)
+		}
+	}
+}
+
+// flattenMappings collects the fields of the mappings of an index or a template, with or without mapping types
+func flattenMappings(mappings interface{}) fieldTypes {
+	fields := fieldTypes{}
+	m, _ := mappings.(map[string]interface{})
+	if properties, ok := m["properties"]; ok {
+		flattenMapping(properties, "", fields)
+		return fields
+	}
+	for _, mappingType := range m {
+		typeMapping, _ := mappingType.(map[string]interface{})
+		flattenMapping(typeMapping["properties"], "", fields)
+	}
+	return fields
+}
+
+func fetchClusterJSON(url string, authFile string, what string) (map[string]interface{}, error) {
+	statusCode, content, err := doRequest(http.MethodGet, url, authFile, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch the %s: %v", what, err)
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch the %s: %s", what, string(content))
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(content, &result)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal the %s: %v", what, err)
+	}
+	return result, nil
+}
+
+// fetchClusterMappings fetches the mappings and the aliases of all the indices and the index templates
+func fetchClusterMappings(host string, port int, authFile string) (*clusterMappings, error) {
+	mappings, err := fetchClusterJSON(fmt.Sprintf("http://%s:%d/_mapping", host, port), authFile, "mappings")
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := fetchClusterJSON(fmt.Sprintf("http://%s:%d/_alias", host, port), authFile, "aliases")
+	if err != nil {
+		return nil, err
+	}
+	templates, err := fetchClusterJSON(fmt.Sprintf("http://%s:%d/_template", host, port), authFile, "index templates")
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &clusterMappings{Indices: map[string]fieldTypes{}, Aliases: map[string][]string{}}
+	for index, value := range mappings {
+		m, _ := value.(map[string]interface{})
+		cluster.Indices[index] = flattenMappings(m["mappings"])
+	}
+	for _, index := range sortedKeys(aliases) {
+		m, _ := aliases[index].(map[string]interface{})
+		indexAliases, _ := m["aliases"].(map[string]interface{})
+		for alias := range indexAliases {
+			cluster.Aliases[alias] = append(cluster.Aliases[alias], index)
+		}
+	}
+	for _, name := range sortedKeys(templates) {
+		m, _ := templates[name].(map[string]interface{})
+		patterns := stringValues(m["index_patterns"])
+		if legacy, ok := m["template"].(string); ok {
+			patterns = append(patterns, legacy)
+		}
+		cluster.Templates = append(cluster.Templates, clusterTemplate{Name: name, Patterns: patterns, Fields: flattenMappings(m["mappings"])})
+	}
+	return cluster, nil
+}
+
+// matchIndexPattern matches an index name with an index pattern, where '*' matches any characters
+func matchIndexPattern(pattern string, name string) bool {
+	expression := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+	matched, _ := regexp.MatchString(expression, name)
+	return matched
+}
+
+// resolveIndexPattern returns the indices matched by a pattern, directly or through their aliases, and the
+// templates which apply to these indices
+func (c *clusterMappings) resolveIndexPattern(pattern string) ([]string, []clusterTemplate) {
+	matched := map[string]bool{}
+	for index := range c.Indices {
+		if matchIndexPattern(pattern, index) {
+			matched[index] = true
+		}
+	}
+	for alias, aliasIndices := range c.Aliases {
+		if matchIndexPattern(pattern, alias) {
+			for _, index := range aliasIndices {
+				matched[index] = true
+			}
+		}
+	}
+	indices := make([]string, 0, len(matched))
+	for index := range matched {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+
+	var templates []clusterTemplate
+	for _, template := range c.Templates {
+		applies := false
+		for _, templatePattern := range template.Patterns {
+			for _, index := range indices {
+				if matchIndexPattern(templatePattern, index) {
+					applies = true
+				}
+			}
+		}
+		if applies {
+			templates = append(templates, template)
+		}
+	}
+	return indices, templates
+}
+
+// collectQueryFields collects the fields used by a query
+func collectQueryFields(query interface{}, path string, usages []fieldUsage) []fieldUsage {
+	m, _ := query.(map[string]interface{})
+	for queryType, value := range m {
+		queryPath := jsonPathKey(path, queryType)
+		settings, _ := value.(map[string]interface{})
+		switch queryType {
+		case "bool":
+			for _, clause := range []string{"must", "filter", "should", "must_not"} {
+				if items, ok := settings[clause].([]interface{}); ok {
+					for i, item := range items {
+						usages = collectQueryFields(item, fmt.Sprintf("%s[%d]", jsonPathKey(queryPath, clause), i), usages)
+					}
+				} else if item, ok := settings[clause]; ok {
+					usages = collectQueryFields(item, jsonPathKey(queryPath, clause), usages)
+				}
+			}
+		case "constant_score":
+			usages = collectQueryFields(settings["filter"], jsonPathKey(queryPath, "filter"), usages)
+		case "exists":
+			if field, ok := settings["field"].(string); ok {
+				usages = append(usages, fieldUsage{Field: field, Usage: "exists", Path: queryPath})
+			}
+		case "query_string", "simple_query_string":
+			fields := stringValues(settings["fields"])
+			if field, ok := settings["default_field"].(string); ok {
+				fields = append(fields, field)
+			}
+			for _, field := range fields {
+				usages = append(usages, fieldUsage{Field: strings.SplitN(field, "^", 2)[0], Usage: "match", Path: queryPath})
+			}
+		case "match", "match_phrase", "match_phrase_prefix":
+			for _, field := range sortedKeys(settings) {
+				usages = append(usages, fieldUsage{Field: field, Usage: "match", Path: jsonPathKey(queryPath, field)})
+			}
+		case "term", "terms", "wildcard", "prefix", "regexp", "fuzzy":
+			for _, field := range sortedKeys(settings) {
+				if field != "boost" {
+					usages = append(usages, fieldUsage{Field: field, Usage: "term", Path: jsonPathKey(queryPath, field)})
+				}
+			}
+		case "range":
+			for _, field := range sortedKeys(settings) {
+				usages = append(usages, fieldUsage{Field: field, Usage: "range", Path: jsonPathKey(queryPath, field)})
+			}
+		}
+	}
+	return usages
+}
+
+// aggregationUsages the usage of the field of each aggregation type
+var aggregationUsages = map[string]string{
+	"terms": "bucket", "significant_terms": "bucket", "cardinality": "bucket", "rare_terms": "bucket",
+	"avg": "metric", "sum": "metric", "min": "metric", "max": "metric", "stats": "metric", "extended_stats": "metric",
+	"percentiles": "metric", "percentile_ranks": "metric", "histogram": "metric", "range": "metric",
+	"date_histogram": "date", "date_range": "date",
+	"value_count": "exists", "missing": "exists",
+}
+
+// collectAggregationFields collects the fields used by the aggregations and their sub-aggregations
+func collectAggregationFields(aggs interface{}, path string, usages []fieldUsage) []fieldUsage {
+	m, _ := aggs.(map[string]interface{})
+	for _, name := range sortedKeys(m) {
+		aggPath := jsonPathKey(path, name)
+		definition, _ := m[name].(map[string]interface{})
+		for _, aggType := range sortedKeys(definition) {
+			if aggType == "aggs" || aggType == "aggregations" {
+				usages = collectAggregationFields(definition[aggType], jsonPathKey(aggPath, aggType), usages)
+				continue
+			}
+			settings, _ := definition[aggType].(map[string]interface{})
+			field, ok := settings["field"].(string)
+			if !ok {
+				continue
+			}
+			usage, ok := aggregationUsages[aggType]
+			if !ok {
+				usage = "exists"
+			}
+			usages = append(usages, fieldUsage{Field: field, Usage: usage, Path: jsonPathKey(jsonPathKey(aggPath, aggType), "field")})
+		}
+	}
+	return usages
+}
+
+// collectConditionFields collects the fields of the search hits used by the compare conditions
+func collectConditionFields(condition interface{}, path string) []fieldUsage {
+	conditionType, value, err := singleConditionEntry(condition)
+	if err != nil {
+		return nil
+	}
+	conditionPath := jsonPathKey(path, conditionType)
+	switch conditionType {
+	case "compare":
+		valuePath, operators, err := singleEntry(value, "compare")
+		if err != nil {
+			return nil
+		}
+		match := sourceFieldPattern.FindStringSubmatch(valuePath)
+		if match == nil {
+			return nil
+		}
+		usage := "compare"
+		for _, expected := range operators {
+			if _, ok := expected.(float64); ok {
+				usage = "compare_number"
+			}
+		}
+		return []fieldUsage{{Field: match[1], Usage: usage, Path: fmt.Sprintf("%s[%s]", conditionPath, strconv.Quote(valuePath))}}
+	case "array_compare":
+		arrayPath, entry, err := singleEntry(value, "array_compare")
+		if err != nil || arrayPath != "ctx.payload.hits.hits" {
+			return nil
+		}
+		itemPath, _ := entry["path"].(string)
+		if !strings.HasPrefix(itemPath, "_source.") {
+			return nil
+		}
+		return []fieldUsage{{Field: strings.TrimPrefix(itemPath, "_source."), Usage: "compare", Path: fmt.Sprintf("%s[%s]", conditionPath, strconv.Quote(arrayPath))}}
+	}
+	return nil
+}
+
+// checkFieldUsage verifies that the types of a field are compatible with its usage, it returns an empty string if they are
+func checkFieldUsage(usage fieldUsage, types map[string]bool, fields fieldTypes) string {
+	var typeNames []string
+	for fieldType := range types {
+		typeNames = append(typeNames, fieldType)
+	}
+	sort.Strings(typeNames)
+
+	compatible := func(fieldType string) bool {
+		numeric := containsString(numericFieldTypes, fieldType)
+		switch usage.Usage {
+		case "exists":
+			return true
+		case "match", "compare":
+			return fieldType != "object" && fieldType != "nested"
+		case "term", "bucket":
+			return fieldType != "object" && fieldType != "nested" && fieldType != "text"
+		case "range":
+			return numeric || strings.HasPrefix(fieldType, "date") || fieldType == "ip" || fieldType == "keyword" || strings.HasSuffix(fieldType, "_range")
+		case "metric":
+			return numeric || strings.HasPrefix(fieldType, "date")
+		case "date":
+			return strings.HasPrefix(fieldType, "date")
+		case "compare_number":
+			return numeric
+		}
+		return true
+	}
+
+	for _, fieldType := range typeNames {
+		if compatible(fieldType) {
+			continue
+		}
+		message := fmt.Sprintf("field '%s' is mapped as %s, which is not compatible with a %s", usage.Field, strings.Join(typeNames, ", "),
+			map[string]string{
+				"match": "full text query", "compare": "comparison", "term": "term-level query", "bucket": "bucket aggregation",
+				"range": "range query", "metric": "metric aggregation", "date": "date aggregation", "compare_number": "numeric comparison",
+			}[usage.Usage])
+		if (usage.Usage == "term" || usage.Usage == "bucket") && fieldType == "text" && fields[usage.Field+".keyword"]["keyword"] {
+			message += fmt.Sprintf(", use '%s.keyword'", usage.Field)
+		}
+		return message
+	}
+	return ""
+}
+
+// searchInput a search input of a watch with its JSON path
+type searchInput struct {
+	Path    string
+	Request map[string]interface{}
+}
+
+// collectSearchInputs returns the search inputs of a watch, including the inputs of a chain
+func collectSearchInputs(input interface{}, path string) []searchInput {
+	inputType, value, ok := singleField(input)
+	if !ok {
+		return nil
+	}
+	inputPath := jsonPathKey(path, inputType)
+	settings, _ := value.(map[string]interface{})
+	switch inputType {
+	case "search":
+		request, _ := settings["request"].(map[string]interface{})
+		return []searchInput{{Path: jsonPathKey(inputPath, "request"), Request: request}}
+	case "chain":
+		var inputs []searchInput
+		items, _ := settings["inputs"].([]interface{})
+		for i, item := range items {
+			if name, chained, ok := singleField(item); ok {
+				inputs = append(inputs, collectSearchInputs(chained, jsonPathKey(fmt.Sprintf("%s[%d]", jsonPathKey(inputPath, "inputs"), i), name))...)
+			}
+		}
+		return inputs
+	}
+	return nil
+}
+
+// lintWatch cross-checks the search inputs of a watch against the indices, the templates and the mappings of the cluster
+func lintWatch(watch Watch, path string, cluster *clusterMappings) []validationError {
+	v := &watchValidator{watch: watch.Name}
+	body, err := normalizeWatchBody(watch.Body)
+	if err != nil {
+		v.addError(path, "invalid watch body: %v", err)
+		return v.errors
+	}
+
+	inputs := collectSearchInputs(body["input"], jsonPathKey(path, "input"))
+	for _, input := range inputs {
+		fields := fieldTypes{}
+		resolved := false
+		for i, item := range stringValues(input.Request["indices"]) {
+			indicesPath := fmt.Sprintf("%s[%d]", jsonPathKey(input.Path, "indices"), i)
+			for _, pattern := range strings.Split(item, ",") {
+				pattern = strings.TrimSpace(pattern)
+				// The exclusions and the date math or templated index names cannot be resolved offline
+				if strings.HasPrefix(pattern, "-") || strings.HasPrefix(pattern, "<") || strings.Contains(pattern, "{{") {
+					resolved = true
+					continue
+				}
+				indices, templates := cluster.resolveIndexPattern(pattern)
+				if len(indices) == 0 {
+					v.addError(indicesPath, "index pattern '%s' matches no indices or aliases", pattern)
+				}
+				for _, index := range indices {
+					mergeFieldTypes(fields, cluster.Indices[index])
+				}
+				for _, template := range templates {
+					mergeFieldTypes(fields, template.Fields)
+				}
+				if len(indices) > 0 {
+					resolved = true
+				}
+			}
+		}
+		if !resolved || len(fields) == 0 {
+			continue
+		}
+
+		requestBody, _ := input.Request["body"].(map[string]interface{})
+		bodyPath := jsonPathKey(input.Path, "body")
+		usages := collectQueryFields(requestBody["query"], jsonPathKey(bodyPath, "query"), nil)
+		for _, key := range []string{"aggs", "aggregations"} {
+			usages = collectAggregationFields(requestBody[key], jsonPathKey(bodyPath, key), usages)
+		}
+		if len(inputs) == 1 {
+			usages = append(usages, collectConditionFields(body["condition"], jsonPathKey(path, "condition"))...)
+		}
+
+		for _, usage := range usages {
+			if strings.HasPrefix(usage.Field, "_") || strings.Contains(usage.Field, "*") {
+				continue
+			}
+			types, ok := fields[usage.Field]
+			if !ok {
+				v.addError(usage.Path, "field '%s' is not mapped in the indices or the templates", usage.Field)
+				continue
+			}
+			if problem := checkFieldUsage(usage, types, fields); problem != "" {
+				v.addError(usage.Path, "%s", problem)
+			}
+		}
+	}
+	return v.errors
+}
+
+func mergeFieldTypes(fields fieldTypes, other fieldTypes) {
+	for field, types := range other {
+		if fields[field] == nil {
+			fields[field] = map[string]bool{}
+		}
+		for fieldType := range types {
+			fields[field][fieldType] = true
+		}
+	}
+}
+
+type lintAgainstClusterCmd struct {
+	host        string
+	port        int
+	authFile    string
+	watchesFile string
+	watches     string
+}
+
+func (*lintAgainstClusterCmd) Name() string { return "lint-against-cluster" }
+func (*lintAgainstClusterCmd) Synopsis() string {
+	return "Cross-check the watches with the indices, the index templates and the mappings of Elasticsearch"
+}
+
+func (*lintAgainstClusterCmd) Usage() string {
+	return `lint-against-cluster [-host] <host name> [-port] <port> [-watches-file] <path to watches file> [-watches] <watch names> [-auth-file] <path to basic auth file>
+        Report the index patterns of the watches which match no indices, and the fields used in the queries, the aggregations
+        and the compare conditions which are not mapped or are mapped with an incompatible type
+	`
+}
+
+func (l *lintAgainstClusterCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&l.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&l.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&l.watchesFile, "watches-file", "", "Path to watches file")
+	f.StringVar(&l.watches, "watches", "", "Comma separated list of watch names (default all the watches of the file)")
+	f.StringVar(&l.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (l *lintAgainstClusterCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	cfg, err := loadWatches(l.watchesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	cluster, err := fetchClusterMappings(l.host, l.port, l.authFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	var selected []string
+	if l.watches != "" {
+		selected = parseWatchNames(l.watches)
+	}
+	var problems []validationError
+	checked := 0
+	for i, watch := range cfg.Watches {
+		if len(selected) > 0 && !containsString(selected, watch.Name) {
+			continue
+		}
+		checked++
+		problems = append(problems, lintWatch(watch, fmt.Sprintf("$.watches[%d].body", i), cluster)...)
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		fmt.Printf("Found %d problem(s) in the watches file '%s'\n", len(problems), l.watchesFile)
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("The %d watches match the indices and the mappings of the cluster\n", checked)
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,240 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher lint against cluster", func() {
+	const Mappings = `{
+		"dev-logstash-2018.03.20": {"mappings": {"doc": {"properties": {
+			"@timestamp": {"type": "date"},
+			"response": {"type": "long"},
+			"request_time": {"type": "float"},
+			"agent": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+			"kubernetes": {"properties": {"pod": {"type": "keyword"}}}
+		}}}},
+		"dev-logstash-2018.03.21": {"mappings": {"doc": {"properties": {
+			"@timestamp": {"type": "date"},
+			"response": {"type": "keyword"}
+		}}}},
+		".watches": {"mappings": {"doc": {"properties": {}}}}
+	}`
+	const Templates = `{
+		"dev-filebeat": {"index_patterns": ["dev-filebeat-*"], "mappings": {"doc": {"properties": {
+			"@timestamp": {"type": "date"},
+			"message": {"type": "text"}
+		}}}},
+		"legacy": {"template": "old-*", "mappings": {"doc": {"properties": {"level": {"type": "keyword"}}}}}
+	}`
+	var cluster *clusterMappings
+
+	BeforeEach(func() {
+		var mappings, templates map[string]interface{}
+		Expect(json.Unmarshal([]byte(Mappings), &mappings)).Should(Succeed())
+		Expect(json.Unmarshal([]byte(Templates), &templates)).Should(Succeed())
+
+		cluster = &clusterMappings{Indices: map[string]fieldTypes{}, Aliases: map[string][]string{
+			"logstash-current": {"dev-logstash-2018.03.21"},
+		}}
+		for index, value := range mappings {
+			cluster.Indices[index] = flattenMappings(value.(map[string]interface{})["mappings"])
+		}
+		for _, name := range sortedKeys(templates) {
+			m := templates[name].(map[string]interface{})
+			patterns := stringValues(m["index_patterns"])
+			if legacy, ok := m["template"].(string); ok {
+				patterns = append(patterns, legacy)
+			}
+			cluster.Templates = append(cluster.Templates, clusterTemplate{Name: name, Patterns: patterns, Fields: flattenMappings(m["mappings"])})
+		}
+	})
+
+	lint := func(body string) []string {
+		var value interface{}
+		Expect(json.Unmarshal([]byte(body), &value)).Should(Succeed())
+		var messages []string
+		for _, problem := range lintWatch(Watch{Name: "watch_test", Body: value}, "$.watches[0].body", cluster) {
+			messages = append(messages, problem.Path+": "+problem.Message)
+		}
+		return messages
+	}
+
+	It("should flatten the mappings with the object and multi-fields", func() {
+		fields := cluster.Indices["dev-logstash-2018.03.20"]
+
+		Expect(fields["kubernetes"]).Should(Equal(map[string]bool{"object": true}))
+		Expect(fields["kubernetes.pod"]).Should(Equal(map[string]bool{"keyword": true}))
+		Expect(fields["agent.keyword"]).Should(Equal(map[string]bool{"keyword": true}))
+	})
+
+	It("should resolve the index patterns against the indices, the aliases and the templates", func() {
+		indices, templates := cluster.resolveIndexPattern("dev-logstash-*")
+		Expect(indices).Should(Equal([]string{"dev-logstash-2018.03.20", "dev-logstash-2018.03.21"}))
+		Expect(templates).Should(BeEmpty())
+
+		indices, _ = cluster.resolveIndexPattern("logstash-current")
+		Expect(indices).Should(Equal([]string{"dev-logstash-2018.03.21"}))
+
+		// the templates only apply to the existing indices
+		indices, templates = cluster.resolveIndexPattern("dev-filebeat-*")
+		Expect(indices).Should(BeEmpty())
+		Expect(templates).Should(BeEmpty())
+
+		cluster.Indices["old-2018"] = fieldTypes{}
+		indices, templates = cluster.resolveIndexPattern("old-*")
+		Expect(indices).Should(Equal([]string{"old-2018"}))
+		Expect(templates).Should(HaveLen(1))
+		Expect(templates[0].Name).Should(Equal("legacy"))
+		Expect(templates[0].Fields["level"]).Should(Equal(map[string]bool{"keyword": true}))
+	})
+
+	It("should accept the watches searching an alias", func() {
+		Expect(lint(`{"input": {"search": {"request": {
+			"indices": ["logstash-current"],
+			"body": {"query": {"match": {"response": 404}}}
+		}}}}`)).Should(BeEmpty())
+	})
+
+	It("should accept the watches matching the mappings", func() {
+		Expect(lint(`{
+			"input": {"search": {"request": {
+				"indices": ["dev-logstash-*"],
+				"body": {
+					"query": {"bool": {
+						"must": {"match": {"response": 404}},
+						"filter": [
+							{"range": {"@timestamp": {"gte": "{{ctx.trigger.scheduled_time}}||-5m"}}},
+							{"term": {"agent.keyword": "kube-probe"}},
+							{"exists": {"field": "kubernetes.pod"}}
+						]
+					}},
+					"aggs": {"pods": {"terms": {"field": "kubernetes.pod"}, "aggs": {"slowest": {"max": {"field": "request_time"}}}}}
+				}
+			}}},
+			"condition": {"compare": {"ctx.payload.hits.hits.0._source.request_time": {"gt": 2}}}
+		}`)).Should(BeEmpty())
+	})
+
+	It("should report the missing fields and the incompatible types", func() {
+		Expect(lint(`{
+			"input": {"search": {"request": {
+				"indices": ["dev-logstash-*"],
+				"body": {
+					"query": {"bool": {"filter": [
+						{"term": {"agent": "kube-probe"}},
+						{"match": {"status": 404}},
+						{"range": {"kubernetes": {"gte": 1}}}
+					]}},
+					"aggs": {"avg_response": {"avg": {"field": "response"}}}
+				}
+			}}},
+			"condition": {"compare": {"ctx.payload.hits.hits.0._source.agent": {"gt": 2}}}
+		}`)).Should(Equal([]string{
+			`$.watches[0].body.input.search.request.body.query.bool.filter[0].term.agent: field 'agent' is mapped as text, which is not compatible with a term-level query, use 'agent.keyword'`,
+			`$.watches[0].body.input.search.request.body.query.bool.filter[1].match.status: field 'status' is not mapped in the indices or the templates`,
+			`$.watches[0].body.input.search.request.body.query.bool.filter[2].range.kubernetes: field 'kubernetes' is mapped as object, which is not compatible with a range query`,
+			`$.watches[0].body.input.search.request.body.aggs.avg_response.avg.field: field 'response' is mapped as keyword, long, which is not compatible with a metric aggregation`,
+			`$.watches[0].body.condition.compare["ctx.payload.hits.hits.0._source.agent"]: field 'agent' is mapped as text, which is not compatible with a numeric comparison`,
+		}))
+	})
+
+	It("should report the index patterns matching no indices", func() {
+		Expect(lint(`{"input": {"chain": {"inputs": [
+			{"first": {"search": {"request": {"indices": ["dev-filebeat-*"], "body": {"query": {"match": {"message": "error"}}}}}}},
+			{"second": {"search": {"request": {"indices": ["prod-logstash-*, <logstash-{now/d}>"]}}}}
+		]}}}`)).Should(Equal([]string{
+			`$.watches[0].body.input.chain.inputs[0].first.search.request.indices[0]: index pattern 'dev-filebeat-*' matches no indices or aliases`,
+			`$.watches[0].body.input.chain.inputs[1].second.search.request.indices[0]: index pattern 'prod-logstash-*' matches no indices or aliases`,
+		}))
+	})
+
+	Context("lint-against-cluster command", func() {
+		var server *ghttp.Server
+		var elasticHost string
+		var elasticPort int
+		var watchesFile *os.File
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+			elasticHost = host
+			elasticPort, err = strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			watchesFile, err = ioutil.TempFile("", "watches")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = watchesFile.Write([]byte(`{"watches": [
+				{"name": "watch_ok", "body": {"input": {"search": {"request": {"indices": ["dev-logstash-*"], "body": {"query": {"match": {"response": 404}}}}}}}},
+				{"name": "watch_typo", "body": {"input": {"search": {"request": {"indices": ["dev-logstash-*"], "body": {"query": {"match": {"respone": 404}}}}}}}}
+			]}`))
+			Expect(err).ShouldNot(HaveOccurred())
+			watchesFile.Close()
+		})
+
+		AfterEach(func() {
+			server.Close()
+			os.Remove(watchesFile.Name())
+		})
+
+		appendClusterHandlers := func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest(http.MethodGet, "/_mapping"),
+					ghttp.RespondWith(http.StatusOK, Mappings),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest(http.MethodGet, "/_alias"),
+					ghttp.RespondWith(http.StatusOK, `{"dev-logstash-2018.03.21": {"aliases": {"logstash-current": {}}}, ".watches": {"aliases": {}}}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest(http.MethodGet, "/_template"),
+					ghttp.RespondWith(http.StatusOK, Templates),
+				),
+			)
+		}
+
+		It("should succeed when the selected watches match the cluster", func() {
+			appendClusterHandlers()
+			cmd := &lintAgainstClusterCmd{host: elasticHost, port: elasticPort, watchesFile: watchesFile.Name(), watches: "watch_ok"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+
+		It("should fail when a watch uses an unmapped field", func() {
+			appendClusterHandlers()
+			cmd := &lintAgainstClusterCmd{host: elasticHost, port: elasticPort, watchesFile: watchesFile.Name()}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+
+		It("should fail when the mappings cannot be fetched", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest(http.MethodGet, "/_mapping"),
+				ghttp.RespondWith(http.StatusUnauthorized, `{"error": "unauthorized"}`),
+			))
+			cmd := &lintAgainstClusterCmd{host: elasticHost, port: elasticPort, watchesFile: watchesFile.Name()}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+	})
+})
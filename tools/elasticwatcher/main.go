@@ -15,12 +15,14 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/google/subcommands"
-	"github.com/oliveagle/jsonpath"
 )
 
 // BasicAuth basic authentication credentials
@@ -31,13 +33,13 @@ type BasicAuth struct {
 
 // WatcherConfig holds all watch configurations
 type WatcherConfig struct {
-	Watches []Watch
+	Watches []Watch `json:"watches"`
 }
 
 // Watch watch configuration
 type Watch struct {
-	Name string
-	Body interface{}
+	Name string      `json:"name"`
+	Body interface{} `json:"body"`
 }
 
 func buildWatcherURL(host string, port int, watchID string) string {
@@ -48,12 +50,59 @@ func buildWatcherSearchURL(host string, port int) string {
 	return fmt.Sprintf("http://%s:%d/.watches/_search", host, port)
 }
 
+func buildScrollURL(host string, port int) string {
+	return fmt.Sprintf("http://%s:%d/_search/scroll", host, port)
+}
+
+func buildWatcherHistorySearchURL(host string, port int) string {
+	return fmt.Sprintf("http://%s:%d/.watcher-history-*/_search", host, port)
+}
+
 func buildHTTPClient() *http.Client {
 	return &http.Client{
 		Timeout: time.Minute * 1,
 	}
 }
 
+// doRequest executes a request against the Elasticsearch API and returns the status code
+// together with the response content. The body is encoded as JSON when provided.
+func doRequest(method string, url string, authFile string, body interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("Failed to encode the request body: %v", err)
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to build the HTTP request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	err = setBasicAuth(req, authFile)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to set the Basic Auth Header: %v", err)
+	}
+
+	client := buildHTTPClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("Failed to read the response: %v", err)
+	}
+	return resp.StatusCode, content, nil
+}
+
 func loadBasicAuth(authFile string) (*BasicAuth, error) {
 	file, err := ioutil.ReadFile(authFile) // #nosec
 	if err != nil {
@@ -88,11 +137,38 @@ func loadWatches(watchesFile string) (*WatcherConfig, error) {
 	var w WatcherConfig
 	err = json.Unmarshal(file, &w)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to unmarshal the watches: %v", err)
+		return nil, fmt.Errorf("Failed to unmarshal the watches: %v", describeJSONError(file, err))
 	}
 	return &w, nil
 }
 
+// describeJSONError extends a JSON decoding error with the line and column where it occurred
+func describeJSONError(content []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return err
+	}
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+
+	line, column := 1, 1
+	for _, c := range content[:offset] {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Errorf("%v (line %d, column %d)", err, line, column)
+}
+
 func parseWatchNames(watches string) []string {
 	watchNames := strings.Split(watches, ",")
 	for i, name := range watchNames {
@@ -101,6 +177,154 @@ func parseWatchNames(watches string) []string {
 	return watchNames
 }
 
+// installedWatch a watch as it is stored in the Watcher index
+type installedWatch struct {
+	ID     string
+	Source map[string]interface{}
+}
+
+const (
+	// watchesPageSize number of watches fetched with a single search request
+	watchesPageSize = 100
+	// watchesScrollTimeout how long the search context is kept alive between two pages
+	watchesScrollTimeout = "1m"
+)
+
+// fetchInstalledWatches scrolls through the Watcher index and returns all installed watches sorted by their ID
+func fetchInstalledWatches(host string, port int, authFile string) ([]installedWatch, error) {
+	var watches []installedWatch
+	searchURL := fmt.Sprintf("%s?scroll=%s&size=%d", buildWatcherSearchURL(host, port), watchesScrollTimeout, watchesPageSize)
+	statusCode, content, err := doRequest(http.MethodGet, searchURL, authFile, nil)
+	for {
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list the watches: %v", err)
+		}
+		if statusCode != http.StatusOK {
+			return nil, fmt.Errorf("Failed to list the watches. Status Code: %d. Error: %s", statusCode, string(content))
+		}
+
+		var result struct {
+			ScrollID string `json:"_scroll_id"`
+			Hits     struct {
+				Hits []struct {
+					ID     string                 `json:"_id"`
+					Source map[string]interface{} `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		err = json.Unmarshal(content, &result)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse the response: %v", err)
+		}
+
+		for _, hit := range result.Hits.Hits {
+			watches = append(watches, installedWatch{ID: hit.ID, Source: hit.Source})
+		}
+		if len(result.Hits.Hits) < watchesPageSize || result.ScrollID == "" {
+			clearScroll(host, port, authFile, result.ScrollID)
+			break
+		}
+
+		scroll := map[string]interface{}{"scroll": watchesScrollTimeout, "scroll_id": result.ScrollID}
+		statusCode, content, err = doRequest(http.MethodPost, buildScrollURL(host, port), authFile, scroll)
+	}
+
+	sort.Slice(watches, func(i, j int) bool { return watches[i].ID < watches[j].ID })
+	return watches, nil
+}
+
+// clearScroll releases the search context of a scroll, failures are ignored since the context expires anyway
+func clearScroll(host string, port int, authFile string, scrollID string) {
+	if scrollID == "" {
+		return
+	}
+	body := map[string]interface{}{"scroll_id": []string{scrollID}}
+	doRequest(http.MethodDelete, buildScrollURL(host, port), authFile, body) // #nosec
+}
+
+// watchServerFields fields added by Watcher to a stored watch which are not part of its definition
+var watchServerFields = []string{"status", "_status"}
+
+// normalizeWatchBody converts a watch body into its generic JSON representation
+// without the fields managed by Watcher, such that two bodies can be compared
+func normalizeWatchBody(body interface{}) (map[string]interface{}, error) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	err = json.Unmarshal(content, &normalized)
+	if err != nil {
+		return nil, err
+	}
+	if normalized == nil {
+		normalized = map[string]interface{}{}
+	}
+	for _, field := range watchServerFields {
+		delete(normalized, field)
+	}
+	return normalized, nil
+}
+
+// watchRequestError error returned by Elasticsearch when a watch request is rejected
+type watchRequestError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *watchRequestError) Error() string {
+	return fmt.Sprintf("Failed to create/update the watch:\n  Status Code: %d.\n  Error Message: %s", e.StatusCode, e.Message)
+}
+
+// putWatch creates or updates a watch in Elasticsearch Watcher
+func putWatch(client *http.Client, host string, port int, authFile string, watch Watch) error {
+	reader, writer := io.Pipe()
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	errc := make(chan error, 1)
+	go func() {
+		defer wg.Done()
+		defer writer.Close()
+		enc := json.NewEncoder(writer)
+		enc.Encode(watch.Body) // #nosec
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer reader.Close()
+		watcherURL := buildWatcherURL(host, port, watch.Name)
+		req, err := http.NewRequest(http.MethodPut, watcherURL, reader)
+		if err != nil {
+			errc <- fmt.Errorf("Failed to build the request to create the watch: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		err = setBasicAuth(req, authFile)
+		if err != nil {
+			errc <- fmt.Errorf("Failed to set Basic Auth header: %v", err)
+			return
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			errc <- fmt.Errorf("Failed to execute the watch create/update request: %v", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			content, _ := ioutil.ReadAll(resp.Body) // #nosec
+			errc <- &watchRequestError{StatusCode: resp.StatusCode, Message: string(content)}
+			return
+		}
+		errc <- nil
+	}()
+
+	wg.Wait()
+
+	return <-errc
+}
+
 func switchWatch(host string, port int, authFile string, watch string, action string) subcommands.ExitStatus {
 	fragment := fmt.Sprintf("%s/_%s", watch, action)
 	watcherURL := buildWatcherURL(host, port, fragment)
@@ -144,10 +368,71 @@ func switchWatch(host string, port int, authFile string, watch string, action st
 	return subcommands.ExitSuccess
 }
 
+// watchSummary the state of an installed watch
+type watchSummary struct {
+	ID               string            `json:"id"`
+	Active           *bool             `json:"active,omitempty"`
+	Schedule         string            `json:"schedule"`
+	LastChecked      string            `json:"last_checked,omitempty"`
+	LastMetCondition string            `json:"last_met_condition,omitempty"`
+	Actions          map[string]string `json:"actions,omitempty"`
+	Ownership        *watchOwnership   `json:"ownership,omitempty"`
+}
+
+// describeSchedule formats a trigger schedule in a compact form, e.g. 'interval 5m'
+func describeSchedule(schedule interface{}) string {
+	scheduleMap, ok := schedule.(map[string]interface{})
+	if !ok || len(scheduleMap) == 0 {
+		return "-"
+	}
+	var parts []string
+	for _, scheduleType := range sortedKeys(scheduleMap) {
+		value := scheduleMap[scheduleType]
+		if s, ok := value.(string); ok {
+			parts = append(parts, fmt.Sprintf("%s %s", scheduleType, s))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %s", scheduleType, formatJSONValue(value)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// summarizeWatch extracts the state of a watch from its source in the Watcher index. The status
+// is stored in the 'status' field since Elasticsearch 6 and in the '_status' field before.
+func summarizeWatch(watch installedWatch) watchSummary {
+	summary := watchSummary{
+		ID:        watch.ID,
+		Schedule:  describeSchedule(lookupJSONPath(watch.Source, "$.trigger.schedule")),
+		Ownership: lookupOwnership(watch.Source),
+	}
+
+	status := watch.Source["status"]
+	if status == nil {
+		status = watch.Source["_status"]
+	}
+	if active, ok := lookupJSONPath(status, "$.state.active").(bool); ok {
+		summary.Active = &active
+	}
+	if lastChecked, ok := lookupJSONPath(status, "$.last_checked").(string); ok {
+		summary.LastChecked = lastChecked
+	}
+	if lastMet, ok := lookupJSONPath(status, "$.last_met_condition").(string); ok {
+		summary.LastMetCondition = lastMet
+	}
+	if actions, ok := lookupJSONPath(status, "$.actions").(map[string]interface{}); ok {
+		summary.Actions = make(map[string]string, len(actions))
+		for id, action := range actions {
+			summary.Actions[id] = formatOptional(lookupJSONPath(action, "$.ack.state"))
+		}
+	}
+	return summary
+}
+
 type listCmd struct {
 	host     string
 	port     int
 	authFile string
+	output   string
 }
 
 func (*listCmd) Name() string { return "list" }
@@ -156,68 +441,85 @@ func (*listCmd) Synopsis() string {
 }
 
 func (*listCmd) Usage() string {
-	return `list [-host] <host name> [-port] <port> [-auth-file] <path to basic auth file>
-        List the watches which are installed in Elasticsearch Watcher
+	return `list [-host] <host name> [-port] <port> [-output] <table|json> [-auth-file] <path to basic auth file>
+        List the watches which are installed in Elasticsearch Watcher with their state
 	`
 }
 
 func (l *listCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&l.host, "host", "localhost", "Host name of the Elasticsearch API")
 	f.IntVar(&l.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&l.output, "output", "table", "Output format: table or json")
 	f.StringVar(&l.authFile, "auth-file", "", "Path to basic auth file")
 }
 
 func (l *listCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	client := buildHTTPClient()
-
-	watcherSearchURL := buildWatcherSearchURL(l.host, l.port)
-
-	req, err := http.NewRequest(http.MethodGet, watcherSearchURL, nil)
-	if err != nil {
-		fmt.Printf("Failed to build the HTTP request. Error: %v\n", err)
-		return subcommands.ExitFailure
+	if l.output != "table" && l.output != "json" {
+		fmt.Printf("Unknown output format '%s'\n", l.output)
+		return subcommands.ExitUsageError
 	}
 
-	err = setBasicAuth(req, l.authFile)
+	watches, err := fetchInstalledWatches(l.host, l.port, l.authFile)
 	if err != nil {
-		fmt.Println("Failed to set the Basic Auth Header")
+		fmt.Println(err)
 		return subcommands.ExitFailure
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("Failed to list the watches. Error: %v\n", err)
-		return subcommands.ExitFailure
+	summaries := make([]watchSummary, 0, len(watches))
+	for _, watch := range watches {
+		summaries = append(summaries, summarizeWatch(watch))
 	}
-	defer resp.Body.Close()
 
-	content, err := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK || err != nil {
-		fmt.Printf("Failed to list the watches. Status Code: %d. Error: %v\n", resp.StatusCode, string(content))
-		return subcommands.ExitFailure
+	if l.output == "json" {
+		content, err := json.MarshalIndent(summaries, "", "    ")
+		if err != nil {
+			fmt.Printf("Failed to encode the watches. Error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+		fmt.Println(string(content))
+		return subcommands.ExitSuccess
 	}
 
-	var watches interface{}
-	err = json.Unmarshal(content, &watches)
-	if err != nil {
-		fmt.Printf("Failed to parse the response. Error: %v\n", err)
-		return subcommands.ExitFailure
+	fmt.Printf("Installed Watches (%d):\n", len(summaries))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WATCH\tACTIVE\tSCHEDULE\tLAST CHECKED\tLAST MET CONDITION\tACTIONS\tMANAGED BY\tSOURCE\tRELEASE\tHASH")
+	for _, summary := range summaries {
+		active := "-"
+		if summary.Active != nil {
+			active = strconv.FormatBool(*summary.Active)
+		}
+		var actions []string
+		for _, id := range sortedStringKeys(summary.Actions) {
+			actions = append(actions, fmt.Sprintf("%s=%s", id, summary.Actions[id]))
+		}
+		ownership := watchOwnership{}
+		if summary.Ownership != nil {
+			ownership = *summary.Ownership
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", summary.ID, active, summary.Schedule,
+			formatOptional(summary.LastChecked), formatOptional(summary.LastMetCondition), formatOptional(strings.Join(actions, ",")),
+			formatOptional(ownership.ManagedBy), formatOptional(ownership.SourceFile), formatOptional(ownership.Release), formatOptional(shortHash(ownership.ContentHash)))
 	}
+	w.Flush()
 
-	res, err := jsonpath.JsonPathLookup(watches, "$.hits.hits[*]._id")
-	if err != nil {
-		fmt.Printf("Failed to find the watch IDs in the response. Error: %v\n", err)
-		return subcommands.ExitFailure
-	}
+	return subcommands.ExitSuccess
+}
 
-	fmt.Println("Installed Watches:")
-	if watchIDs, ok := res.([]interface{}); ok == true {
-		for _, watchID := range watchIDs {
-			fmt.Printf("  %s\n", watchID)
-		}
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
-	return subcommands.ExitSuccess
+// shortHash abbreviates a content hash for display
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
 }
 
 type deactivateCmd struct {
@@ -225,6 +527,10 @@ type deactivateCmd struct {
 	port     int
 	authFile string
 	watches  string
+	match    string
+	regex    string
+	all      bool
+	yes      bool
 }
 
 func (*deactivateCmd) Name() string { return "deactivate" }
@@ -233,7 +539,7 @@ func (*deactivateCmd) Synopsis() string {
 }
 
 func (*deactivateCmd) Usage() string {
-	return `deactivate [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-auth-file] <path to basic auth file>
+	return `deactivate [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-match] <glob pattern> [-regex] <regular expression> [-all] [-yes] [-auth-file] <path to basic auth file>
         Deactivate a list of watches from Elasticsearch Watcher
 	`
 }
@@ -242,11 +548,20 @@ func (da *deactivateCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&da.host, "host", "localhost", "Host name of the Elasticsearch API")
 	f.IntVar(&da.port, "port", 9200, "Port of the Elastisearch API")
 	f.StringVar(&da.watches, "watches", "", "Comma separated list with watches names")
+	f.StringVar(&da.match, "match", "", "Glob pattern selecting the installed watches, e.g. watch_http_*")
+	f.StringVar(&da.regex, "regex", "", "Regular expression selecting the installed watches")
+	f.BoolVar(&da.all, "all", false, "Select all the installed watches")
+	f.BoolVar(&da.yes, "yes", false, "Confirm the deactivate of more than one watch")
 	f.StringVar(&da.authFile, "auth-file", "", "Path to basic auth file")
 }
 
 func (da *deactivateCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	watchNames := parseWatchNames(da.watches)
+	selection := watchSelection{watches: da.watches, match: da.match, regex: da.regex, all: da.all}
+	watchNames, err := resolveWatchNames(da.host, da.port, da.authFile, selection, "deactivate", true, da.yes)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
 
 	for _, watch := range watchNames {
 		rc := switchWatch(da.host, da.port, da.authFile, watch, "deactivate")
@@ -263,6 +578,9 @@ type activateCmd struct {
 	port     int
 	authFile string
 	watches  string
+	match    string
+	regex    string
+	all      bool
 }
 
 func (*activateCmd) Name() string { return "activate" }
@@ -271,7 +589,7 @@ func (*activateCmd) Synopsis() string {
 }
 
 func (*activateCmd) Usage() string {
-	return `activate [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-auth-file] <path to basic auth file>
+	return `activate [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-match] <glob pattern> [-regex] <regular expression> [-all] [-auth-file] <path to basic auth file>
         Activate a list of watches from Elasticsearch Watcher
 	`
 }
@@ -280,11 +598,19 @@ func (a *activateCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&a.host, "host", "localhost", "Host name of the Elasticsearch API")
 	f.IntVar(&a.port, "port", 9200, "Port of the Elastisearch API")
 	f.StringVar(&a.watches, "watches", "", "Comma separated list with watches names")
+	f.StringVar(&a.match, "match", "", "Glob pattern selecting the installed watches, e.g. watch_http_*")
+	f.StringVar(&a.regex, "regex", "", "Regular expression selecting the installed watches")
+	f.BoolVar(&a.all, "all", false, "Select all the installed watches")
 	f.StringVar(&a.authFile, "auth-file", "", "Path to basic auth file")
 }
 
 func (a *activateCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	watchNames := parseWatchNames(a.watches)
+	selection := watchSelection{watches: a.watches, match: a.match, regex: a.regex, all: a.all}
+	watchNames, err := resolveWatchNames(a.host, a.port, a.authFile, selection, "activate", false, false)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
 
 	for _, watch := range watchNames {
 		rc := switchWatch(a.host, a.port, a.authFile, watch, "activate")
@@ -301,6 +627,10 @@ type deleteCmd struct {
 	port     int
 	authFile string
 	watches  string
+	match    string
+	regex    string
+	all      bool
+	yes      bool
 }
 
 func (*deleteCmd) Name() string { return "delete" }
@@ -309,7 +639,7 @@ func (*deleteCmd) Synopsis() string {
 }
 
 func (*deleteCmd) Usage() string {
-	return `delete [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-auth-file] <path to basic auth file>
+	return `delete [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-match] <glob pattern> [-regex] <regular expression> [-all] [-yes] [-auth-file] <path to basic auth file>
         Delete a list of watches from Elasticsearch Watcher
 	`
 }
@@ -318,11 +648,20 @@ func (d *deleteCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&d.host, "host", "localhost", "Host name of the Elasticsearch API")
 	f.IntVar(&d.port, "port", 9200, "Port of the Elastisearch API")
 	f.StringVar(&d.watches, "watches", "", "Comma separated list with watches names")
+	f.StringVar(&d.match, "match", "", "Glob pattern selecting the installed watches, e.g. watch_http_*")
+	f.StringVar(&d.regex, "regex", "", "Regular expression selecting the installed watches")
+	f.BoolVar(&d.all, "all", false, "Select all the installed watches")
+	f.BoolVar(&d.yes, "yes", false, "Confirm the delete of more than one watch")
 	f.StringVar(&d.authFile, "auth-file", "", "Path to basic auth file")
 }
 
 func (d *deleteCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	watchNames := parseWatchNames(d.watches)
+	selection := watchSelection{watches: d.watches, match: d.match, regex: d.regex, all: d.all}
+	watchNames, err := resolveWatchNames(d.host, d.port, d.authFile, selection, "delete", true, d.yes)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
 	client := buildHTTPClient()
 
 	for _, watch := range watchNames {
@@ -369,10 +708,14 @@ func (d *deleteCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 }
 
 type retrieveCmd struct {
-	host     string
-	port     int
-	authFile string
-	watches  string
+	host        string
+	port        int
+	authFile    string
+	watches     string
+	match       string
+	regex       string
+	all         bool
+	secretsFile string
 }
 
 func (*retrieveCmd) Name() string { return "retrieve" }
@@ -381,8 +724,10 @@ func (*retrieveCmd) Synopsis() string {
 }
 
 func (*retrieveCmd) Usage() string {
-	return `retrieve [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-auth-file] <path to basic auth file>
-        Retrieve a list of watches from Elasticsearch Watcher by their name
+	return `retrieve [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-match] <glob pattern> [-regex] <regular expression> [-all] [-auth-file] <path to basic auth file>
+        [-secrets-file] <path to secrets file>
+        Retrieve a list of watches from Elasticsearch Watcher by their name. The known secret values are replaced with
+        their placeholders and the sensitive fields, e.g. passwords, are masked.
 	`
 }
 
@@ -390,11 +735,25 @@ func (r *retrieveCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&r.host, "host", "localhost", "Host name of the Elasticsearch API")
 	f.IntVar(&r.port, "port", 9200, "Port of the Elastisearch API")
 	f.StringVar(&r.watches, "watches", "", "Comma separated list with watches names")
+	f.StringVar(&r.match, "match", "", "Glob pattern selecting the installed watches, e.g. watch_http_*")
+	f.StringVar(&r.regex, "regex", "", "Regular expression selecting the installed watches")
+	f.BoolVar(&r.all, "all", false, "Select all the installed watches")
 	f.StringVar(&r.authFile, "auth-file", "", "Path to basic auth file")
+	f.StringVar(&r.secretsFile, "secrets-file", "", "Path to the JSON file with the secrets referenced by the ${secret:name} placeholders")
 }
 
 func (r *retrieveCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	watchNames := parseWatchNames(r.watches)
+	selection := watchSelection{watches: r.watches, match: r.match, regex: r.regex, all: r.all}
+	watchNames, err := resolveWatchNames(r.host, r.port, r.authFile, selection, "retrieve", false, false)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	secrets, err := loadSecrets(r.secretsFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
 	client := buildHTTPClient()
 
 	for _, watch := range watchNames {
@@ -425,15 +784,14 @@ func (r *retrieveCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface
 			return subcommands.ExitFailure
 		}
 
-		var prettyContent bytes.Buffer
-		err = json.Indent(&prettyContent, content, "", "    ")
+		prettyContent, err := maskJSON(content, secrets)
 		if err != nil {
 			fmt.Printf("Failed to indent the content of watch '%s'. Error: %v", watch, err)
 			return subcommands.ExitFailure
 		}
 
 		fmt.Printf("Watch: %s\n", watch)
-		fmt.Print(string(prettyContent.Bytes()))
+		fmt.Print(string(prettyContent))
 		fmt.Println()
 	}
 
@@ -441,10 +799,14 @@ func (r *retrieveCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface
 }
 
 type createCmd struct {
-	host        string
-	port        int
-	watchesFile string
-	authFile    string
+	host            string
+	port            int
+	watchesFile     string
+	authFile        string
+	release         string
+	secretsFile     string
+	concurrency     int
+	continueOnError bool
 }
 
 func (*createCmd) Name() string { return "create" }
@@ -452,8 +814,12 @@ func (*createCmd) Synopsis() string {
 	return "Register a list of watches in Elasicsearch Watcher or update them"
 }
 func (*createCmd) Usage() string {
-	return `create [-host] <host name> [-port] <port> [-watches-file] <path to watches file> [-auth-file] <path to basic auth file>
-        Register a list of watches in Elasticsearch Watcher or update them
+	return `create [-host] <host name> [-port] <port> [-watches-file] <path to watches file> [-auth-file] <path to basic auth file> [-release] <release name>
+        [-secrets-file] <path to secrets file> [-concurrency] <number of parallel requests> [-continue-on-error]
+        Register a list of watches in Elasticsearch Watcher or update them. The watches are stamped with ownership metadata
+        in 'metadata.elasticwatcher' which identifies them as managed by this tool. A report with the succeeded and the
+        failed watches is printed at the end. The ${secret:name} placeholders are resolved from the secrets file or
+        from the ELASTICWATCHER_SECRET_<NAME> environment variables.
 	`
 }
 
@@ -462,6 +828,10 @@ func (c *createCmd) SetFlags(f *flag.FlagSet) {
 	f.IntVar(&c.port, "port", 9200, "Port of the Elastisearch API")
 	f.StringVar(&c.watchesFile, "watches-file", "", "Path to watches file")
 	f.StringVar(&c.authFile, "auth-file", "", "Path to basic auth file")
+	f.StringVar(&c.release, "release", "", "Name of the chart release stamped on the watches")
+	f.StringVar(&c.secretsFile, "secrets-file", "", "Path to the JSON file with the secrets referenced by the ${secret:name} placeholders")
+	f.IntVar(&c.concurrency, "concurrency", 4, "Maximum number of watches created in parallel")
+	f.BoolVar(&c.continueOnError, "continue-on-error", false, "Create the remaining watches when a watch fails")
 }
 
 func (c *createCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -475,59 +845,25 @@ func (c *createCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 		return subcommands.ExitFailure
 	}
 
-	for _, watch := range cfg.Watches {
-		reader, writer := io.Pipe()
-		wg := sync.WaitGroup{}
-		wg.Add(2)
-		errc := make(chan error, 1)
-		go func() {
-			defer wg.Done()
-			defer writer.Close()
-			enc := json.NewEncoder(writer)
-			enc.Encode(watch.Body) // #nosec
-		}()
-
-		go func() {
-			defer wg.Done()
-			defer reader.Close()
-			watcherURL := buildWatcherURL(c.host, c.port, watch.Name)
-			req, err := http.NewRequest(http.MethodPut, watcherURL, reader)
-			if err != nil {
-				errc <- fmt.Errorf("Failed to build the request to create the watch: %v", err)
-				return
-			}
-			req.Header.Set("Content-Type", "application/json")
-			err = setBasicAuth(req, c.authFile)
-			if err != nil {
-				errc <- fmt.Errorf("Failed to set Basic Auth header: %v", err)
-				return
-			}
-
-			client := buildHTTPClient()
-			resp, err := client.Do(req)
-			if err != nil {
-				errc <- fmt.Errorf("Failed to execute the watch create/update request: %v", err)
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode >= http.StatusBadRequest {
-				content, _ := ioutil.ReadAll(resp.Body) // #nosec
-				errc <- fmt.Errorf(
-					"Failed to create/update the watch:\n  Status Code: %d.\n  Error Message: %s",
-					resp.StatusCode, string(content))
-				return
-			}
-			errc <- nil
-		}()
-
-		wg.Wait()
-
-		if err := <-errc; err != nil {
-			fmt.Println(err)
-			return subcommands.ExitFailure
-		}
-		fmt.Printf("Successfully created/updated the watch '%s'.\n", watch.Name)
+	secrets, err := loadSecrets(c.secretsFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	watches, err := injectSecrets(cfg.Watches, secrets)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	watches, err = stampWatches(watches, c.watchesFile, c.release)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	results := createWatches(c.host, c.port, c.authFile, watches, c.concurrency, c.continueOnError)
+	if !printCreateReport(results) {
+		return subcommands.ExitFailure
 	}
 	return subcommands.ExitSuccess
 }
@@ -542,6 +878,28 @@ func main() {
 	subcommands.Register(&activateCmd{}, "")
 	subcommands.Register(&deactivateCmd{}, "")
 	subcommands.Register(&listCmd{}, "")
+	subcommands.Register(&syncCmd{}, "")
+	subcommands.Register(&diffCmd{}, "")
+	subcommands.Register(&executeCmd{}, "")
+	subcommands.Register(&validateCmd{}, "")
+	subcommands.Register(&historyCmd{}, "")
+	subcommands.Register(&ackCmd{}, "")
+	subcommands.Register(&statsCmd{}, "")
+	subcommands.Register(&startCmd{}, "")
+	subcommands.Register(&stopCmd{}, "")
+	subcommands.Register(&restartCmd{}, "")
+	subcommands.Register(&exportCmd{}, "")
+	subcommands.Register(&silenceCmd{}, "")
+	subcommands.Register(&unsilenceCmd{}, "")
+	subcommands.Register(&reconcileSilencesCmd{}, "")
+	subcommands.Register(&scheduleCmd{}, "")
+	subcommands.Register(&testCmd{}, "")
+	subcommands.Register(&renderCmd{}, "")
+	subcommands.Register(&convertElastalertCmd{}, "")
+	subcommands.Register(&generateCmd{}, "")
+	subcommands.Register(&docsCmd{}, "")
+	subcommands.Register(&lintAgainstClusterCmd{}, "")
+	subcommands.Register(&sinkCmd{}, "")
 
 	flag.Parse()
 	ctx := context.Background()
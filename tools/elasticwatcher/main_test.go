@@ -6,6 +6,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -13,6 +14,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/google/subcommands"
 	. "github.com/onsi/ginkgo"
@@ -80,7 +82,7 @@ var _ = Describe("The elasticwatcher client", func() {
 			server.AppendHandlers(
 				ghttp.CombineHandlers(
 					ghttp.VerifyRequest("PUT", watcherEndpoint+"/"+WatchName),
-					ghttp.VerifyBody([]byte(WatchBody)),
+					verifyStampedWatch(WatchBody),
 				),
 			)
 
@@ -100,7 +102,7 @@ var _ = Describe("The elasticwatcher client", func() {
 			server.AppendHandlers(
 				ghttp.CombineHandlers(
 					ghttp.VerifyRequest("PUT", watcherEndpoint+"/"+WatchName),
-					ghttp.VerifyBody([]byte(WatchBody)),
+					verifyStampedWatch(WatchBody),
 					ghttp.VerifyBasicAuth(Username, Password),
 				),
 			)
@@ -416,6 +418,7 @@ var _ = Describe("The elasticwatcher client", func() {
 			cmd := &listCmd{
 				host:     elasticHost,
 				port:     elasticPort,
+				output:   "table",
 				authFile: ""}
 
 			exitStatus := cmd.Execute(nil, nil)
@@ -439,6 +442,7 @@ var _ = Describe("The elasticwatcher client", func() {
 			cmd := &listCmd{
 				host:     elasticHost,
 				port:     elasticPort,
+				output:   "table",
 				authFile: authFile}
 
 			exitStatus := cmd.Execute(nil, nil)
@@ -446,5 +450,78 @@ var _ = Describe("The elasticwatcher client", func() {
 			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
 			Expect(server.ReceivedRequests()).Should(HaveLen(1))
 		})
+
+		It("should scroll through all the watches", func() {
+			var firstPage []string
+			for i := 0; i < watchesPageSize; i++ {
+				firstPage = append(firstPage, fmt.Sprintf("{\"_id\": \"watch_%03d\"}", i))
+			}
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint, "scroll=1m&size=100"),
+					ghttp.RespondWith(http.StatusOK, fmt.Sprintf("{\"_scroll_id\": \"scroll_1\", \"hits\": {\"hits\": [%s]}}",
+						strings.Join(firstPage, ","))),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/_search/scroll"),
+					ghttp.VerifyJSON("{\"scroll\": \"1m\", \"scroll_id\": \"scroll_1\"}"),
+					ghttp.RespondWith(http.StatusOK, "{\"_scroll_id\": \"scroll_2\", \"hits\": {\"hits\": [{\"_id\": \"watch_last\"}]}}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", "/_search/scroll"),
+					ghttp.VerifyJSON("{\"scroll_id\": [\"scroll_2\"]}"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			watches, err := fetchInstalledWatches(elasticHost, elasticPort, "")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(watches).Should(HaveLen(watchesPageSize + 1))
+			Expect(watches[watchesPageSize].ID).Should(Equal("watch_last"))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+
+		It("should print the watches as JSON", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+			)
+
+			cmd := &listCmd{
+				host:   elasticHost,
+				port:   elasticPort,
+				output: "json"}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should summarize the state of a watch", func() {
+			var source map[string]interface{}
+			err := json.Unmarshal([]byte(`{
+				"trigger": {"schedule": {"interval": "15m"}},
+				"status": {
+					"state": {"active": false},
+					"last_checked": "2018-03-20T10:00:00.000Z",
+					"last_met_condition": "2018-03-20T09:00:00.000Z",
+					"actions": {"teams_webhook": {"ack": {"state": "acked"}}}
+				}
+			}`), &source)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			summary := summarizeWatch(installedWatch{ID: WatchName, Source: source})
+
+			Expect(summary.ID).Should(Equal(WatchName))
+			Expect(*summary.Active).Should(BeFalse())
+			Expect(summary.Schedule).Should(Equal("interval 15m"))
+			Expect(summary.LastChecked).Should(Equal("2018-03-20T10:00:00.000Z"))
+			Expect(summary.LastMetCondition).Should(Equal("2018-03-20T09:00:00.000Z"))
+			Expect(summary.Actions).Should(Equal(map[string]string{"teams_webhook": "acked"}))
+		})
 	})
 })
@@ -0,0 +1,325 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mustacheNode a node of a parsed mustache template
+type mustacheNode struct {
+	// kind is one of 'text', 'var', 'section' and 'inverted'
+	kind     string
+	text     string
+	name     string
+	params   string
+	escape   bool
+	children []*mustacheNode
+}
+
+// parseMustache parses a mustache template into a tree of nodes. Partials and delimiter changes are not supported.
+func parseMustache(template string) ([]*mustacheNode, error) {
+	root := &mustacheNode{kind: "section"}
+	stack := []*mustacheNode{root}
+
+	for i := 0; i < len(template); {
+		current := stack[len(stack)-1]
+		open := strings.Index(template[i:], "{{")
+		if open < 0 {
+			current.children = append(current.children, &mustacheNode{kind: "text", text: template[i:]})
+			break
+		}
+		if open > 0 {
+			current.children = append(current.children, &mustacheNode{kind: "text", text: template[i : i+open]})
+		}
+
+		start := i + open + 2
+		end := "}}"
+		triple := strings.HasPrefix(template[start:], "{")
+		if triple {
+			start++
+			end = "}}}"
+		}
+		stop := strings.Index(template[start:], end)
+		if stop < 0 || strings.Contains(template[start:start+stop], "{{") {
+			return nil, fmt.Errorf("unclosed placeholder starting at '%s'", truncate(template[i+open:], 30))
+		}
+		tag := strings.TrimSpace(template[start : start+stop])
+		if tag == "" {
+			return nil, fmt.Errorf("empty placeholder at position %d", i+open)
+		}
+		i = start + stop + len(end)
+
+		if triple {
+			current.children = append(current.children, &mustacheNode{kind: "var", name: tag})
+			continue
+		}
+
+		sigil, name := tag[0], strings.TrimSpace(tag[1:])
+		switch sigil {
+		case '!':
+			// Comment
+		case '&':
+			current.children = append(current.children, &mustacheNode{kind: "var", name: name})
+		case '#', '^':
+			node := &mustacheNode{kind: "section", name: name}
+			if sigil == '^' {
+				node.kind = "inverted"
+			}
+			if fields := strings.SplitN(name, " ", 2); len(fields) == 2 {
+				node.name, node.params = fields[0], strings.TrimSpace(fields[1])
+			}
+			current.children = append(current.children, node)
+			stack = append(stack, node)
+		case '/':
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("section '%s' closed without being opened", name)
+			}
+			if current.name != name {
+				return nil, fmt.Errorf("section '%s' closed while section '%s' is open", name, current.name)
+			}
+			stack = stack[:len(stack)-1]
+		case '>', '=':
+			return nil, fmt.Errorf("unsupported placeholder '%s'", tag)
+		default:
+			current.children = append(current.children, &mustacheNode{kind: "var", name: tag, escape: true})
+		}
+	}
+
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("section '%s' is not closed", stack[len(stack)-1].name)
+	}
+	return root.children, nil
+}
+
+// mustacheRenderer renders mustache templates as Watcher does, the values of the '{{name}}' tags are JSON escaped
+// while the ones of the '{{{name}}}' tags are not. The references which cannot be resolved are collected.
+type mustacheRenderer struct {
+	Unresolved []string
+}
+
+// lookupMustache resolves a dotted name, such as ctx.payload.hits.total, against the context stack.
+// The numeric parts of the name are indexes in arrays.
+func lookupMustache(name string, stack []interface{}) (interface{}, bool) {
+	if name == "." {
+		return stack[len(stack)-1], true
+	}
+	parts := strings.Split(name, ".")
+	for i := len(stack) - 1; i >= 0; i-- {
+		m, ok := stack[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := m[parts[0]]
+		if !ok {
+			continue
+		}
+		return lookupMustachePath(value, parts[1:])
+	}
+	return nil, false
+}
+
+func lookupMustachePath(value interface{}, parts []string) (interface{}, bool) {
+	for _, part := range parts {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			item, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			value = item
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// mustacheString converts a value into its text representation, the objects and arrays are rendered as JSON
+func mustacheString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		if v == float64(int64(v)) {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return encodeJSON(value)
+}
+
+// encodeJSON encodes a value as compact JSON without escaping the HTML characters
+func encodeJSON(value interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// escapeJSONString escapes a text such that it can be embedded in a JSON string
+func escapeJSONString(text string) string {
+	quoted := encodeJSON(text)
+	return quoted[1 : len(quoted)-1]
+}
+
+func isFalsy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// parseJoinDelimiter extracts the delimiter of the join function, e.g. delimiter=' '
+func parseJoinDelimiter(params string) string {
+	if !strings.HasPrefix(params, "delimiter=") {
+		return ","
+	}
+	delimiter := strings.TrimPrefix(params, "delimiter=")
+	if len(delimiter) >= 2 && (delimiter[0] == '\'' || delimiter[0] == '"') && delimiter[len(delimiter)-1] == delimiter[0] {
+		delimiter = delimiter[1 : len(delimiter)-1]
+	}
+	return delimiter
+}
+
+func (r *mustacheRenderer) unresolved(name string) {
+	for _, existing := range r.Unresolved {
+		if existing == name {
+			return
+		}
+	}
+	r.Unresolved = append(r.Unresolved, name)
+}
+
+func (r *mustacheRenderer) renderNodes(nodes []*mustacheNode, stack []interface{}, out *bytes.Buffer) {
+	for _, node := range nodes {
+		switch node.kind {
+		case "text":
+			out.WriteString(node.text)
+
+		case "var":
+			value, found := lookupMustache(node.name, stack)
+			if !found {
+				r.unresolved(node.name)
+				continue
+			}
+			text := mustacheString(value)
+			if node.escape {
+				text = escapeJSONString(text)
+			}
+			out.WriteString(text)
+
+		case "inverted":
+			value, _ := lookupMustache(node.name, stack)
+			if isFalsy(value) {
+				r.renderNodes(node.children, stack, out)
+			}
+
+		case "section":
+			// The toJson and join functions of the Elasticsearch mustache engine take a path as content
+			if node.name == "toJson" || node.name == "join" {
+				var content bytes.Buffer
+				r.renderNodes(node.children, stack, &content)
+				path := strings.TrimSpace(content.String())
+				value, found := lookupMustache(path, stack)
+				if !found {
+					r.unresolved(path)
+					continue
+				}
+				if node.name == "toJson" {
+					out.WriteString(encodeJSON(value))
+					continue
+				}
+				var items []string
+				for _, item := range listValues(value) {
+					items = append(items, mustacheString(item))
+				}
+				out.WriteString(strings.Join(items, parseJoinDelimiter(node.params)))
+				continue
+			}
+
+			value, found := lookupMustache(node.name, stack)
+			if !found {
+				r.unresolved(node.name)
+				continue
+			}
+			if isFalsy(value) {
+				continue
+			}
+			if list, ok := value.([]interface{}); ok {
+				for _, item := range list {
+					r.renderNodes(node.children, append(stack, item), out)
+				}
+				continue
+			}
+			r.renderNodes(node.children, append(stack, value), out)
+		}
+	}
+}
+
+// Render renders a template against a context such as {"ctx": {...}}
+func (r *mustacheRenderer) Render(template string, context map[string]interface{}) (string, error) {
+	nodes, err := parseMustache(template)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	r.renderNodes(nodes, []interface{}{context}, &out)
+	return out.String(), nil
+}
+
+// renderTemplates renders all the strings of a value, such as the definition of an action
+func (r *mustacheRenderer) renderTemplates(value interface{}, context map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return r.Render(v, context)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			renderedItem, err := r.renderTemplates(item, context)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			rendered[key] = renderedItem
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			renderedItem, err := r.renderTemplates(item, context)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+			rendered[i] = renderedItem
+		}
+		return rendered, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher mustache renderer", func() {
+	var context map[string]interface{}
+
+	BeforeEach(func() {
+		Expect(json.Unmarshal([]byte(`{"ctx": {
+			"watch_id": "watch_test",
+			"payload": {
+				"hits": {"total": 2, "hits": [
+					{"_source": {"host": "web-1", "message": "say \"hi\""}},
+					{"_source": {"host": "web-2", "message": "down"}}
+				]},
+				"hosts": ["web-1", "web-2"],
+				"ratio": 0.5,
+				"empty": []
+			}
+		}}`), &context)).Should(Succeed())
+	})
+
+	render := func(template string) (string, []string) {
+		renderer := &mustacheRenderer{}
+		text, err := renderer.Render(template, context)
+		Expect(err).ShouldNot(HaveOccurred(), template)
+		return text, renderer.Unresolved
+	}
+
+	It("should render the variables", func() {
+		for template, expected := range map[string]string{
+			"{{ctx.watch_id}}: {{ctx.payload.hits.total}} hits": "watch_test: 2 hits",
+			"{{ctx.payload.ratio}}":                             "0.5",
+			"{{ctx.payload.hits.hits.0._source.host}}":          "web-1",
+			"{{ctx.payload.hits.hits.0._source.message}}":       `say \"hi\"`,
+			"{{{ctx.payload.hits.hits.0._source.message}}}":     `say "hi"`,
+			"{{&ctx.payload.hits.hits.0._source.message}}":      `say "hi"`,
+			"{{! comment }}text":                                "text",
+		} {
+			text, unresolved := render(template)
+
+			Expect(text).Should(Equal(expected), template)
+			Expect(unresolved).Should(BeEmpty(), template)
+		}
+	})
+
+	It("should render the sections", func() {
+		for template, expected := range map[string]string{
+			"{{#ctx.payload.hits.hits}}{{_source.host}} {{/ctx.payload.hits.hits}}": "web-1 web-2 ",
+			"{{#ctx.payload.empty}}items{{/ctx.payload.empty}}":                     "",
+			"{{^ctx.payload.empty}}none{{/ctx.payload.empty}}":                      "none",
+			"{{#ctx.payload.hits}}{{total}}{{/ctx.payload.hits}}":                   "2",
+			"{{#toJson}}ctx.payload.hits.total{{/toJson}}":                          "2",
+			"{{#toJson}}ctx.payload.empty{{/toJson}}":                               "[]",
+			"{{#join delimiter=' | '}}ctx.payload.hosts{{/join}}":                   "web-1 | web-2",
+			"{{#join}}ctx.payload.hosts{{/join}}":                                   "web-1,web-2",
+		} {
+			text, _ := render(template)
+
+			Expect(text).Should(Equal(expected), template)
+		}
+	})
+
+	It("should collect the unresolved references", func() {
+		text, unresolved := render("{{ctx.payload.missing}} {{ctx.payload.missing}} {{#ctx.unknown}}x{{/ctx.unknown}}")
+
+		Expect(text).Should(Equal("  "))
+		Expect(unresolved).Should(Equal([]string{"ctx.payload.missing", "ctx.unknown"}))
+	})
+
+	It("should render the templates of an action", func() {
+		renderer := &mustacheRenderer{}
+		rendered, err := renderer.renderTemplates(map[string]interface{}{
+			"webhook": map[string]interface{}{
+				"body":   "{{ctx.payload.hits.total}} errors",
+				"params": []interface{}{"{{ctx.watch_id}}", 1.0},
+			},
+		}, context)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rendered).Should(Equal(map[string]interface{}{
+			"webhook": map[string]interface{}{
+				"body":   "2 errors",
+				"params": []interface{}{"watch_test", 1.0},
+			},
+		}))
+	})
+
+	It("should reject the invalid templates", func() {
+		for _, template := range []string{
+			"{{ctx.watch_id",
+			"{{#ctx.payload}}text",
+			"{{/ctx.payload}}",
+			"{{> partial}}",
+			"{{}}",
+		} {
+			renderer := &mustacheRenderer{}
+			_, err := renderer.Render(template, context)
+
+			Expect(err).Should(HaveOccurred(), template)
+		}
+	})
+})
@@ -0,0 +1,143 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	// ownershipKey key of the ownership metadata in the metadata of a watch
+	ownershipKey = "elasticwatcher"
+	// managedByMarker marks the watches created by this tool
+	managedByMarker = "elasticwatcher"
+)
+
+// watchOwnership metadata stamped on the watches created by this tool
+type watchOwnership struct {
+	ManagedBy   string `json:"managed_by"`
+	SourceFile  string `json:"source_file,omitempty"`
+	ContentHash string `json:"content_hash"`
+	Release     string `json:"release,omitempty"`
+}
+
+// removeOwnership removes the ownership metadata from a normalized watch body
+func removeOwnership(body map[string]interface{}) {
+	metadata, ok := body["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(metadata, ownershipKey)
+	if len(metadata) == 0 {
+		delete(body, "metadata")
+	}
+}
+
+// watchContentHash computes the hash of a watch body without its ownership metadata
+func watchContentHash(body interface{}) (string, error) {
+	normalized, err := normalizeWatchBody(body)
+	if err != nil {
+		return "", err
+	}
+	removeOwnership(normalized)
+	// The keys of the maps are sorted by the JSON encoder, which makes the hash stable
+	content, err := json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// stampWatch returns a copy of the watch with the ownership metadata injected into its body
+func stampWatch(watch Watch, sourceFile string, release string) (Watch, error) {
+	body, err := normalizeWatchBody(watch.Body)
+	if err != nil {
+		return watch, fmt.Errorf("Failed to normalize the watch '%s': %v", watch.Name, err)
+	}
+	hash, err := watchContentHash(body)
+	if err != nil {
+		return watch, fmt.Errorf("Failed to hash the watch '%s': %v", watch.Name, err)
+	}
+
+	ownership := watchOwnership{
+		ManagedBy:   managedByMarker,
+		ContentHash: hash,
+		Release:     release,
+	}
+	if sourceFile != "" {
+		ownership.SourceFile = filepath.Base(sourceFile)
+	}
+
+	metadata, ok := body["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+	}
+	stamp, err := normalizeOwnership(ownership)
+	if err != nil {
+		return watch, err
+	}
+	metadata[ownershipKey] = stamp
+	body["metadata"] = metadata
+
+	return Watch{Name: watch.Name, Body: body}, nil
+}
+
+// stampWatches stamps the ownership metadata on all the watches of a watches file
+func stampWatches(watches []Watch, sourceFile string, release string) ([]Watch, error) {
+	stamped := make([]Watch, 0, len(watches))
+	for _, watch := range watches {
+		s, err := stampWatch(watch, sourceFile, release)
+		if err != nil {
+			return nil, err
+		}
+		stamped = append(stamped, s)
+	}
+	return stamped, nil
+}
+
+// normalizeOwnership converts the ownership metadata into its generic JSON representation
+func normalizeOwnership(ownership watchOwnership) (map[string]interface{}, error) {
+	content, err := json.Marshal(ownership)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	err = json.Unmarshal(content, &normalized)
+	return normalized, err
+}
+
+// lookupOwnership extracts the ownership metadata from an installed watch, it returns nil if the
+// watch was not created by this tool
+func lookupOwnership(source map[string]interface{}) *watchOwnership {
+	stamp, ok := lookupJSONPath(source, "$.metadata."+ownershipKey).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	content, err := json.Marshal(stamp)
+	if err != nil {
+		return nil
+	}
+	var ownership watchOwnership
+	if err := json.Unmarshal(content, &ownership); err != nil || ownership.ManagedBy != managedByMarker {
+		return nil
+	}
+	return &ownership
+}
+
+// isPrunable indicates if an installed watch can be deleted by a prune. Only the watches carrying
+// the ownership marker are pruned and, when a release is given, only the ones of that release.
+func isPrunable(watch installedWatch, release string) bool {
+	ownership := lookupOwnership(watch.Source)
+	if ownership == nil {
+		return false
+	}
+	return release == "" || ownership.Release == release
+}
@@ -0,0 +1,94 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// verifyStampedWatch verifies that the request creates a watch stamped with the ownership
+// metadata and whose body without this metadata matches the expected JSON
+func verifyStampedWatch(expected string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		content, err := ioutil.ReadAll(req.Body)
+		Expect(err).ShouldNot(HaveOccurred())
+		req.Body.Close()
+
+		var body map[string]interface{}
+		Expect(json.Unmarshal(content, &body)).Should(Succeed())
+		Expect(lookupOwnership(body)).ShouldNot(BeNil())
+
+		removeOwnership(body)
+		actual, err := json.Marshal(body)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(actual).Should(MatchJSON(expected))
+	}
+}
+
+var _ = Describe("The elasticwatcher ownership metadata", func() {
+	var watch Watch
+
+	BeforeEach(func() {
+		watch = Watch{Name: "watch_a", Body: map[string]interface{}{
+			"trigger":  map[string]interface{}{"schedule": map[string]interface{}{"interval": "5m"}},
+			"metadata": map[string]interface{}{"team": "ops"},
+		}}
+	})
+
+	It("should stamp the ownership metadata next to the existing metadata", func() {
+		stamped, err := stampWatch(watch, "/config/watches.json", "elk")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		body := stamped.Body.(map[string]interface{})
+		Expect(lookupJSONPath(body, "$.metadata.team")).Should(Equal("ops"))
+
+		ownership := lookupOwnership(body)
+		Expect(ownership).ShouldNot(BeNil())
+		Expect(ownership.ManagedBy).Should(Equal("elasticwatcher"))
+		Expect(ownership.SourceFile).Should(Equal("watches.json"))
+		Expect(ownership.Release).Should(Equal("elk"))
+		Expect(ownership.ContentHash).Should(HaveLen(64))
+	})
+
+	It("should compute the hash without the ownership metadata", func() {
+		stamped, err := stampWatch(watch, "watches.json", "elk")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		restamped, err := stampWatch(stamped, "other.json", "dev")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		first := lookupOwnership(stamped.Body.(map[string]interface{}))
+		second := lookupOwnership(restamped.Body.(map[string]interface{}))
+		Expect(second.ContentHash).Should(Equal(first.ContentHash))
+	})
+
+	It("should remove the ownership metadata", func() {
+		stamped, err := stampWatch(Watch{Name: "watch_b", Body: map[string]interface{}{}}, "", "")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		body := stamped.Body.(map[string]interface{})
+		removeOwnership(body)
+		Expect(body).Should(BeEmpty())
+	})
+
+	It("should only prune the watches carrying the marker", func() {
+		stamped, err := stampWatch(watch, "watches.json", "elk")
+		Expect(err).ShouldNot(HaveOccurred())
+		managed := installedWatch{ID: "watch_a", Source: stamped.Body.(map[string]interface{})}
+		manual := installedWatch{ID: "watch_kibana", Source: map[string]interface{}{
+			"metadata": map[string]interface{}{"elasticwatcher": map[string]interface{}{"managed_by": "someone"}}}}
+
+		Expect(isPrunable(managed, "")).Should(BeTrue())
+		Expect(isPrunable(managed, "elk")).Should(BeTrue())
+		Expect(isPrunable(managed, "dev")).Should(BeFalse())
+		Expect(isPrunable(manual, "")).Should(BeFalse())
+	})
+})
@@ -0,0 +1,231 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// renderedActionTypes the action types whose templates are rendered
+var renderedActionTypes = []string{"webhook", "email", "logging"}
+
+// renderedActionFields the templates of each action type which are rendered, by their path in the action
+var renderedActionFields = map[string][]string{
+	"webhook": {"body"},
+	"email":   {"subject", "body", "body.text", "body.html"},
+	"logging": {"text"},
+}
+
+// renderedField a rendered template of an action
+type renderedField struct {
+	Path       string
+	Text       string
+	Unresolved []string
+	Err        error
+	// Invalid is set when a body which looks like JSON does not render into valid JSON
+	Invalid error
+}
+
+// renderedAction the rendered templates of an action
+type renderedAction struct {
+	Name   string
+	Type   string
+	Fields []renderedField
+}
+
+// sampleWatchContext builds the context of a watch from a sample ctx, which can be either the ctx object or
+// an object with a 'ctx' key. The fields missing from the sample are filled with simulated values.
+func sampleWatchContext(name string, body map[string]interface{}, sample interface{}, now time.Time) (map[string]interface{}, error) {
+	context := buildWatchContext(name, body, nil, now)
+	if sample == nil {
+		return context, nil
+	}
+	fields, ok := sample.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the sample ctx must be a JSON object")
+	}
+	if inner, ok := fields["ctx"].(map[string]interface{}); ok && len(fields) == 1 {
+		fields = inner
+	}
+	ctx := context["ctx"].(map[string]interface{})
+	for key, value := range fields {
+		ctx[key] = value
+	}
+	return context, nil
+}
+
+// renderField renders a template of an action
+func renderField(path string, template string, context map[string]interface{}) renderedField {
+	field := renderedField{Path: path}
+	renderer := &mustacheRenderer{}
+	field.Text, field.Err = renderer.Render(template, context)
+	field.Unresolved = renderer.Unresolved
+	if field.Err != nil {
+		return field
+	}
+
+	trimmed := strings.TrimSpace(field.Text)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var value interface{}
+		if err := json.Unmarshal([]byte(trimmed), &value); err != nil {
+			field.Invalid = describeJSONError([]byte(trimmed), err)
+		}
+	}
+	return field
+}
+
+// renderActions renders the templates of all the actions of a watch against the context
+func renderActions(body map[string]interface{}, context map[string]interface{}) []renderedAction {
+	actions, _ := body["actions"].(map[string]interface{})
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rendered []renderedAction
+	for _, name := range names {
+		definition, _ := actions[name].(map[string]interface{})
+		for _, actionType := range renderedActionTypes {
+			settings, ok := definition[actionType]
+			if !ok {
+				continue
+			}
+			action := renderedAction{Name: name, Type: actionType}
+			for _, path := range renderedActionFields[actionType] {
+				value, found := lookupMustachePath(settings, strings.Split(path, "."))
+				if template, ok := value.(string); found && ok {
+					action.Fields = append(action.Fields, renderField(actionType+"."+path, template, context))
+				}
+			}
+			rendered = append(rendered, action)
+		}
+	}
+	return rendered
+}
+
+// printRenderedActions prints the rendered templates and returns false if any of them has a problem
+func printRenderedActions(actions []renderedAction) bool {
+	ok := true
+	for _, action := range actions {
+		fmt.Printf("Action: %s (%s)\n", action.Name, action.Type)
+		if len(action.Fields) == 0 {
+			fmt.Println("  no templates")
+		}
+		for _, field := range action.Fields {
+			fmt.Printf("  %s:\n", field.Path)
+			if field.Err != nil {
+				fmt.Printf("    ERROR: %v\n", field.Err)
+				ok = false
+				continue
+			}
+			fmt.Printf("    %s\n", strings.Replace(field.Text, "\n", "\n    ", -1))
+			if len(field.Unresolved) > 0 {
+				fmt.Printf("    UNRESOLVED: %s\n", strings.Join(field.Unresolved, ", "))
+				ok = false
+			}
+			if field.Invalid != nil {
+				fmt.Printf("    INVALID JSON: %v\n", field.Invalid)
+				ok = false
+			}
+		}
+	}
+	return ok
+}
+
+type renderCmd struct {
+	watchesFile string
+	watch       string
+	ctxFile     string
+	time        string
+}
+
+func (*renderCmd) Name() string { return "render" }
+func (*renderCmd) Synopsis() string {
+	return "Render the action templates of a watch against a sample ctx"
+}
+
+func (*renderCmd) Usage() string {
+	return `render [-watches-file] <path to watches file> [-watch] <watch name> [-ctx-file] <path to sample ctx> [-time] <RFC3339 time>
+        Render the webhook bodies, the email subjects and bodies and the logging texts of the watch actions offline,
+        and report the references which do not resolve against the sample ctx
+	`
+}
+
+func (r *renderCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&r.watchesFile, "watches-file", "", "Path to watches file")
+	f.StringVar(&r.watch, "watch", "", "Name of the watch")
+	f.StringVar(&r.ctxFile, "ctx-file", "", "Path to a JSON file with the sample ctx, e.g. {\"payload\": {\"hits\": {\"total\": 3}}}")
+	f.StringVar(&r.time, "time", "", "Simulated execution time in RFC3339 format (default now)")
+}
+
+func (r *renderCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if r.watch == "" {
+		fmt.Println("The name of the watch is required")
+		return subcommands.ExitUsageError
+	}
+	now := time.Now()
+	if r.time != "" {
+		var err error
+		now, err = time.Parse(time.RFC3339, r.time)
+		if err != nil {
+			fmt.Printf("Invalid time '%s'. Error: %v\n", r.time, err)
+			return subcommands.ExitUsageError
+		}
+	}
+
+	cfg, err := loadWatches(r.watchesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	var body map[string]interface{}
+	for _, watch := range cfg.Watches {
+		if watch.Name == r.watch {
+			body, err = normalizeWatchBody(watch.Body)
+			if err != nil {
+				fmt.Printf("Invalid body of the watch '%s'. Error: %v\n", r.watch, err)
+				return subcommands.ExitFailure
+			}
+		}
+	}
+	if body == nil {
+		fmt.Printf("Watch '%s' not found in the watches file\n", r.watch)
+		return subcommands.ExitFailure
+	}
+
+	var sample interface{}
+	if r.ctxFile != "" {
+		sample, err = loadJSONFile(r.ctxFile)
+		if err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+	}
+	context, err := sampleWatchContext(r.watch, body, sample, now)
+	if err != nil {
+		fmt.Printf("Invalid sample ctx. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+
+	actions := renderActions(body, context)
+	if len(actions) == 0 {
+		fmt.Printf("Watch '%s' has no webhook, email or logging action\n", r.watch)
+		return subcommands.ExitSuccess
+	}
+	if !printRenderedActions(actions) {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher render", func() {
+	const Body = `{
+		"metadata": {"team": "web"},
+		"actions": {
+			"teams_webhook": {"webhook": {"body": "{\"text\": \"{{ctx.metadata.team}}: {{ctx.payload.hits.total}} errors\"}"}},
+			"mail": {"email": {"subject": "{{ctx.watch_id}} fired", "body": {"text": "{{ctx.payload.hits.totl}} errors"}}},
+			"log": {"logging": {"text": "{{{ctx.payload.message}}}"}},
+			"index": {"index": {"index": "alerts"}}
+		}
+	}`
+	now := time.Date(2018, time.March, 20, 10, 30, 0, 0, time.UTC)
+	var body map[string]interface{}
+
+	BeforeEach(func() {
+		Expect(json.Unmarshal([]byte(Body), &body)).Should(Succeed())
+	})
+
+	It("should fill the sample ctx with the simulated fields", func() {
+		for _, sample := range []string{
+			`{"payload": {"hits": {"total": 3}}}`,
+			`{"ctx": {"payload": {"hits": {"total": 3}}}}`,
+		} {
+			var value interface{}
+			Expect(json.Unmarshal([]byte(sample), &value)).Should(Succeed())
+
+			context, err := sampleWatchContext("watch_test", body, value, now)
+
+			Expect(err).ShouldNot(HaveOccurred(), sample)
+			ctx := context["ctx"].(map[string]interface{})
+			Expect(ctx["watch_id"]).Should(Equal("watch_test"))
+			Expect(ctx["execution_time"]).Should(Equal("2018-03-20T10:30:00.000Z"))
+			Expect(ctx["metadata"]).Should(Equal(map[string]interface{}{"team": "web"}))
+			Expect(ctx["payload"]).Should(Equal(map[string]interface{}{"hits": map[string]interface{}{"total": 3.0}}))
+		}
+	})
+
+	It("should reject a sample ctx which is not an object", func() {
+		_, err := sampleWatchContext("watch_test", body, []interface{}{}, now)
+
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should render the templates of the actions and flag the unresolved references", func() {
+		var sample interface{}
+		Expect(json.Unmarshal([]byte(`{"payload": {"hits": {"total": 3}, "message": "disk \"full\""}}`), &sample)).Should(Succeed())
+		context, err := sampleWatchContext("watch_test", body, sample, now)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		actions := renderActions(body, context)
+
+		Expect(actions).Should(HaveLen(3))
+		Expect(actions[0].Name).Should(Equal("log"))
+		Expect(actions[0].Fields).Should(HaveLen(1))
+		Expect(actions[0].Fields[0].Text).Should(Equal(`disk "full"`))
+
+		Expect(actions[1].Name).Should(Equal("mail"))
+		Expect(actions[1].Fields).Should(HaveLen(2))
+		Expect(actions[1].Fields[0].Path).Should(Equal("email.subject"))
+		Expect(actions[1].Fields[0].Text).Should(Equal("watch_test fired"))
+		Expect(actions[1].Fields[1].Path).Should(Equal("email.body.text"))
+		Expect(actions[1].Fields[1].Unresolved).Should(Equal([]string{"ctx.payload.hits.totl"}))
+
+		Expect(actions[2].Name).Should(Equal("teams_webhook"))
+		Expect(actions[2].Fields[0].Text).Should(Equal(`{"text": "web: 3 errors"}`))
+		Expect(actions[2].Fields[0].Invalid).ShouldNot(HaveOccurred())
+		Expect(printRenderedActions(actions)).Should(BeFalse())
+	})
+
+	It("should flag the webhook bodies which render into invalid JSON", func() {
+		field := renderField("webhook.body", `{"text": "{{{ctx.payload.message}}}"}`, map[string]interface{}{
+			"ctx": map[string]interface{}{"payload": map[string]interface{}{"message": `disk "full"`}},
+		})
+
+		Expect(field.Unresolved).Should(BeEmpty())
+		Expect(field.Invalid).Should(HaveOccurred())
+	})
+
+	Context("render command", func() {
+		var dir, watchesFile, ctxFile string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "render")
+			Expect(err).ShouldNot(HaveOccurred())
+			watchesFile = filepath.Join(dir, "watches.json")
+			Expect(ioutil.WriteFile(watchesFile, []byte(`{"watches": [
+				{"name": "watch_test", "body": {"actions": {"log": {"logging": {"text": "{{ctx.payload.hits.total}} errors"}}}}}
+			]}`), 0644)).Should(Succeed())
+			ctxFile = filepath.Join(dir, "ctx.json")
+			Expect(ioutil.WriteFile(ctxFile, []byte(`{"payload": {"hits": {"total": 3}}}`), 0644)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should render the actions of the watch", func() {
+			cmd := &renderCmd{watchesFile: watchesFile, watch: "watch_test", ctxFile: ctxFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		})
+
+		It("should fail when the references do not resolve", func() {
+			cmd := &renderCmd{watchesFile: watchesFile, watch: "watch_test"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+
+		It("should fail on an unknown watch", func() {
+			cmd := &renderCmd{watchesFile: watchesFile, watch: "watch_unknown", ctxFile: ctxFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+
+		It("should require the watch name", func() {
+			cmd := &renderCmd{watchesFile: watchesFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+		})
+	})
+})
@@ -0,0 +1,521 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// schedulePreview a trigger schedule with its description, which can compute the next fire times
+type schedulePreview struct {
+	Descriptions []string
+	crons        []*cronSchedule
+	// interval is the period in seconds of an interval schedule
+	interval int64
+}
+
+// Next returns the next n fire times after the given time. The interval schedules fire relative
+// to the time the watch was activated, their fire times are computed from the given time.
+func (p *schedulePreview) Next(after time.Time, n int) []time.Time {
+	var times []time.Time
+	if p.interval > 0 {
+		for i := 1; i <= n; i++ {
+			times = append(times, after.Add(time.Duration(int64(i)*p.interval)*time.Second))
+		}
+		return times
+	}
+
+	current := after
+	for len(times) < n {
+		var next time.Time
+		found := false
+		for _, c := range p.crons {
+			if t, ok := c.Next(current); ok && (!found || t.Before(next)) {
+				next = t
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+		times = append(times, next)
+		current = next
+	}
+	return times
+}
+
+var scheduleDayNames = map[string]int{
+	"sunday": 1, "monday": 2, "tuesday": 3, "wednesday": 4, "thursday": 5, "friday": 6, "saturday": 7,
+	"sun": 1, "mon": 2, "tue": 3, "wed": 4, "thu": 5, "fri": 6, "sat": 7,
+}
+
+var scheduleMonthNames = map[string]int{
+	"january": 1, "february": 2, "march": 3, "april": 4, "may": 5, "june": 6, "july": 7,
+	"august": 8, "september": 9, "october": 10, "november": 11, "december": 12,
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// listValues converts a single value or a list of values into a list
+func listValues(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{value}
+}
+
+// scheduleDaysOfMonth parses the days of a monthly or yearly schedule, where last_day is the last day of the month,
+// into the day of month field of a cron expression and its description
+func scheduleDaysOfMonth(value interface{}) (string, string, error) {
+	var days []interface{}
+	last := false
+	for _, item := range listValues(value) {
+		if s, ok := item.(string); ok && strings.ToLower(s) == "last_day" {
+			last = true
+			continue
+		}
+		days = append(days, item)
+	}
+	if !last {
+		numbers, err := scheduleInts(value, nil, 1, 31, "day of month")
+		if err != nil {
+			return "", "", err
+		}
+		return joinInts(numbers), "on day " + joinInts(numbers), nil
+	}
+	if len(days) == 0 {
+		return "L", "on the last day", nil
+	}
+	numbers, err := scheduleInts(days, nil, 1, 31, "day of month")
+	if err != nil {
+		return "", "", err
+	}
+	return joinInts(numbers) + ",L", fmt.Sprintf("on day %s and the last day", joinInts(numbers)), nil
+}
+
+// scheduleInts parses a number or a list of numbers, the values can also be names such as 'friday'
+func scheduleInts(value interface{}, names map[string]int, min int, max int, what string) ([]int, error) {
+	var values []int
+	for _, item := range listValues(value) {
+		var n int
+		switch v := item.(type) {
+		case float64:
+			n = int(v)
+			if float64(n) != v {
+				return nil, fmt.Errorf("invalid %s %v", what, v)
+			}
+		case string:
+			if named, ok := names[strings.ToLower(v)]; ok {
+				n = named
+				break
+			}
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s '%s'", what, v)
+			}
+			n = parsed
+		default:
+			return nil, fmt.Errorf("invalid %s %v", what, item)
+		}
+		if n < min || n > max {
+			return nil, fmt.Errorf("%s %d out of range [%d-%d]", what, n, min, max)
+		}
+		values = append(values, n)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("missing %s", what)
+	}
+	return values, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, strconv.Itoa(value))
+	}
+	return strings.Join(parts, ",")
+}
+
+// dayTimes the hours and minutes of the 'at' field of a daily, weekly, monthly or yearly schedule
+type dayTimes struct {
+	hours   []int
+	minutes []int
+}
+
+// parseDayTimes parses the 'at' field, which is either a time such as '17:30', 'midnight' or 'noon',
+// a list of times, or an object with the hour and minute lists. It defaults to midnight.
+func parseDayTimes(at interface{}) ([]dayTimes, error) {
+	if at == nil {
+		return []dayTimes{{hours: []int{0}, minutes: []int{0}}}, nil
+	}
+	if object, ok := at.(map[string]interface{}); ok {
+		hours, err := scheduleInts(object["hour"], nil, 0, 23, "hour")
+		if err != nil {
+			return nil, err
+		}
+		minutes := []int{0}
+		if minute, ok := object["minute"]; ok {
+			if minutes, err = scheduleInts(minute, nil, 0, 59, "minute"); err != nil {
+				return nil, err
+			}
+		}
+		return []dayTimes{{hours: hours, minutes: minutes}}, nil
+	}
+
+	var times []dayTimes
+	for _, item := range listValues(at) {
+		value, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid time %v, expected a time such as '17:30'", item)
+		}
+		switch strings.ToLower(value) {
+		case "midnight":
+			value = "00:00"
+		case "noon":
+			value = "12:00"
+		}
+		parsed, err := time.Parse("15:04", value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time '%s', expected a time such as '17:30'", value)
+		}
+		times = append(times, dayTimes{hours: []int{parsed.Hour()}, minutes: []int{parsed.Minute()}})
+	}
+	return times, nil
+}
+
+func describeDayTimes(times []dayTimes) string {
+	var parts []string
+	for _, t := range times {
+		for _, hour := range t.hours {
+			for _, minute := range t.minutes {
+				parts = append(parts, fmt.Sprintf("%02d:%02d", hour, minute))
+			}
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// scheduleCrons converts the entries of a daily, weekly, monthly or yearly schedule into cron expressions,
+// as done by Watcher. The entries are either an object or a list of objects.
+func scheduleCrons(value interface{}, convert func(entry map[string]interface{}) ([]string, string, error)) ([]string, []string, error) {
+	var expressions, descriptions []string
+	for _, item := range listValues(value) {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid schedule %v, expected an object", item)
+		}
+		crons, description, err := convert(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		expressions = append(expressions, crons...)
+		descriptions = append(descriptions, description)
+	}
+	return expressions, descriptions, nil
+}
+
+func dayTimesCrons(times []dayTimes, days string) []string {
+	var crons []string
+	for _, t := range times {
+		crons = append(crons, fmt.Sprintf("0 %s %s %s", joinInts(t.minutes), joinInts(t.hours), days))
+	}
+	return crons
+}
+
+// parseTriggerSchedule parses the 'trigger.schedule' block of a watch
+func parseTriggerSchedule(schedule interface{}) (*schedulePreview, error) {
+	scheduleMap, ok := schedule.(map[string]interface{})
+	if !ok || len(scheduleMap) != 1 {
+		return nil, fmt.Errorf("the schedule must define exactly one of %s", strings.Join(scheduleTypes, ", "))
+	}
+
+	preview := &schedulePreview{}
+	var expressions []string
+	for scheduleType, value := range scheduleMap {
+		switch scheduleType {
+		case "interval":
+			seconds, err := parseInterval(value)
+			if err != nil {
+				return nil, err
+			}
+			preview.interval = seconds
+			every := fmt.Sprintf("%ds", seconds)
+			if text, ok := value.(string); ok {
+				every = strings.TrimSpace(text)
+			}
+			preview.Descriptions = []string{fmt.Sprintf("every %s, relative to the time the watch was activated", every)}
+			return preview, nil
+
+		case "cron":
+			for _, expression := range listValues(value) {
+				s, ok := expression.(string)
+				if !ok {
+					return nil, fmt.Errorf("cron expression must be a string")
+				}
+				expressions = append(expressions, s)
+			}
+
+		case "hourly":
+			entry, _ := value.(map[string]interface{})
+			minutes := []int{0}
+			if minute, ok := entry["minute"]; ok {
+				var err error
+				if minutes, err = scheduleInts(minute, nil, 0, 59, "minute"); err != nil {
+					return nil, err
+				}
+			}
+			expressions = append(expressions, fmt.Sprintf("0 %s * * * ?", joinInts(minutes)))
+			preview.Descriptions = append(preview.Descriptions, fmt.Sprintf("hourly at minute %s (UTC)", joinInts(minutes)))
+
+		case "daily":
+			entry, _ := value.(map[string]interface{})
+			times, err := parseDayTimes(entry["at"])
+			if err != nil {
+				return nil, err
+			}
+			expressions = append(expressions, dayTimesCrons(times, "* * ?")...)
+			preview.Descriptions = append(preview.Descriptions, fmt.Sprintf("daily at %s UTC", describeDayTimes(times)))
+
+		case "weekly":
+			crons, descriptions, err := scheduleCrons(value, func(entry map[string]interface{}) ([]string, string, error) {
+				on := entry["on"]
+				if on == nil {
+					on = "monday"
+				}
+				days, err := scheduleInts(on, scheduleDayNames, 1, 7, "day of week")
+				if err != nil {
+					return nil, "", err
+				}
+				times, err := parseDayTimes(entry["at"])
+				if err != nil {
+					return nil, "", err
+				}
+				var names []string
+				for _, day := range days {
+					names = append(names, weekdayNames[day])
+				}
+				return dayTimesCrons(times, fmt.Sprintf("? * %s", joinInts(days))),
+					fmt.Sprintf("weekly on %s at %s UTC", strings.Join(names, ", "), describeDayTimes(times)), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			expressions = append(expressions, crons...)
+			preview.Descriptions = append(preview.Descriptions, descriptions...)
+
+		case "monthly":
+			crons, descriptions, err := scheduleCrons(value, func(entry map[string]interface{}) ([]string, string, error) {
+				on := entry["on"]
+				if on == nil {
+					on = 1.0
+				}
+				days, onDays, err := scheduleDaysOfMonth(on)
+				if err != nil {
+					return nil, "", err
+				}
+				times, err := parseDayTimes(entry["at"])
+				if err != nil {
+					return nil, "", err
+				}
+				return dayTimesCrons(times, fmt.Sprintf("%s * ?", days)),
+					fmt.Sprintf("monthly %s at %s UTC", onDays, describeDayTimes(times)), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			expressions = append(expressions, crons...)
+			preview.Descriptions = append(preview.Descriptions, descriptions...)
+
+		case "yearly":
+			crons, descriptions, err := scheduleCrons(value, func(entry map[string]interface{}) ([]string, string, error) {
+				in := entry["in"]
+				if in == nil {
+					in = "january"
+				}
+				months, err := scheduleInts(in, scheduleMonthNames, 1, 12, "month")
+				if err != nil {
+					return nil, "", err
+				}
+				on := entry["on"]
+				if on == nil {
+					on = 1.0
+				}
+				days, onDays, err := scheduleDaysOfMonth(on)
+				if err != nil {
+					return nil, "", err
+				}
+				times, err := parseDayTimes(entry["at"])
+				if err != nil {
+					return nil, "", err
+				}
+				var names []string
+				for _, month := range months {
+					names = append(names, monthNames[month])
+				}
+				return dayTimesCrons(times, fmt.Sprintf("%s %s ?", days, joinInts(months))),
+					fmt.Sprintf("yearly in %s %s at %s UTC", strings.Join(names, ", "), onDays, describeDayTimes(times)), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			expressions = append(expressions, crons...)
+			preview.Descriptions = append(preview.Descriptions, descriptions...)
+
+		default:
+			return nil, fmt.Errorf("unknown schedule type '%s'", scheduleType)
+		}
+	}
+
+	describeCrons := len(preview.Descriptions) == 0
+	for _, expression := range expressions {
+		c, err := parseCron(expression)
+		if err != nil {
+			return nil, err
+		}
+		preview.crons = append(preview.crons, c)
+		if describeCrons {
+			preview.Descriptions = append(preview.Descriptions, fmt.Sprintf("%s: %s", expression, c.Describe()))
+		}
+	}
+	return preview, nil
+}
+
+type scheduleCmd struct {
+	host        string
+	port        int
+	authFile    string
+	watchesFile string
+	watches     string
+	count       int
+	timezone    string
+	from        string
+}
+
+func (*scheduleCmd) Name() string { return "schedule" }
+func (*scheduleCmd) Synopsis() string {
+	return "Describe the schedules of watches and show their next fire times"
+}
+
+func (*scheduleCmd) Usage() string {
+	return `schedule [-watches-file] <path to watches file> [-host] <host name> [-port] <port> [-auth-file] <path to basic auth file>
+        [-watches] <comma separated list of watches> [-count] <number of fire times> [-timezone] <timezone> [-from] <RFC3339 time>
+        Describe the trigger schedule of the watches from the watches file, or from Elasticsearch Watcher when no watches
+        file is given, and show their next fire times
+	`
+}
+
+func (s *scheduleCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&s.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&s.watchesFile, "watches-file", "", "Path to watches file (default the watches installed in Elasticsearch Watcher)")
+	f.StringVar(&s.watches, "watches", "", "Comma separated list of watches names (default all watches)")
+	f.IntVar(&s.count, "count", 5, "Number of fire times shown for each watch")
+	f.StringVar(&s.timezone, "timezone", "UTC", "Timezone in which the fire times are shown, e.g. Europe/Berlin")
+	f.StringVar(&s.from, "from", "", "Time after which the fire times are computed, in RFC3339 format (default now)")
+	f.StringVar(&s.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (s *scheduleCmd) loadWatches() ([]Watch, error) {
+	if s.watchesFile != "" {
+		if _, err := os.Stat(s.watchesFile); os.IsNotExist(err) {
+			return nil, fmt.Errorf("Watches file '%s' not found", s.watchesFile)
+		}
+		cfg, err := loadWatches(s.watchesFile)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.Watches, nil
+	}
+
+	installed, err := fetchInstalledWatches(s.host, s.port, s.authFile)
+	if err != nil {
+		return nil, err
+	}
+	watches := make([]Watch, 0, len(installed))
+	for _, watch := range installed {
+		watches = append(watches, Watch{Name: watch.ID, Body: watch.Source})
+	}
+	return watches, nil
+}
+
+func (s *scheduleCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if s.timezone == "" {
+		s.timezone = "UTC"
+	}
+	location, err := time.LoadLocation(s.timezone)
+	if err != nil {
+		fmt.Printf("Unknown timezone '%s'. Error: %v\n", s.timezone, err)
+		return subcommands.ExitUsageError
+	}
+	from := time.Now()
+	if s.from != "" {
+		if from, err = time.Parse(time.RFC3339, s.from); err != nil {
+			fmt.Printf("Invalid time '%s', expected the RFC3339 format. Error: %v\n", s.from, err)
+			return subcommands.ExitUsageError
+		}
+	}
+	if s.count <= 0 {
+		s.count = 5
+	}
+
+	watches, err := s.loadWatches()
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	selected := make(map[string]bool)
+	if s.watches != "" {
+		for _, name := range parseWatchNames(s.watches) {
+			selected[name] = true
+		}
+	}
+
+	failed := false
+	for _, watch := range watches {
+		if len(selected) > 0 && !selected[watch.Name] {
+			continue
+		}
+
+		fmt.Printf("Watch: %s\n", watch.Name)
+		schedule := lookupJSONPath(watch.Body, "$.trigger.schedule")
+		preview, err := parseTriggerSchedule(schedule)
+		if err != nil {
+			failed = true
+			fmt.Printf("  Invalid schedule %s: %v\n\n", formatJSONValue(schedule), err)
+			continue
+		}
+
+		fmt.Printf("  Schedule: %s\n", formatJSONValue(schedule))
+		for _, description := range preview.Descriptions {
+			fmt.Printf("  %s\n", description)
+		}
+		times := preview.Next(from, s.count)
+		if len(times) == 0 {
+			fmt.Println("  The schedule does not fire anymore")
+		} else {
+			fmt.Printf("  Next fire times (%s):\n", location)
+		}
+		for _, t := range times {
+			fmt.Printf("    %s\n", t.In(location).Format("Mon 2006-01-02 15:04:05 MST"))
+		}
+		fmt.Println()
+	}
+
+	if failed {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
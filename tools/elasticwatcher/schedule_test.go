@@ -0,0 +1,158 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher schedule preview", func() {
+	// Tuesday
+	from := time.Date(2018, time.March, 20, 10, 30, 0, 0, time.UTC)
+
+	parseSchedule := func(schedule string) (*schedulePreview, error) {
+		var value interface{}
+		Expect(json.Unmarshal([]byte(schedule), &value)).Should(Succeed())
+		return parseTriggerSchedule(value)
+	}
+
+	It("should compute the fire times of the Watcher schedules", func() {
+		for schedule, expected := range map[string][]time.Time{
+			`{"interval": "90m"}`: {
+				time.Date(2018, time.March, 20, 12, 0, 0, 0, time.UTC),
+				time.Date(2018, time.March, 20, 13, 30, 0, 0, time.UTC),
+			},
+			`{"hourly": {"minute": [0, 45]}}`: {
+				time.Date(2018, time.March, 20, 10, 45, 0, 0, time.UTC),
+				time.Date(2018, time.March, 20, 11, 0, 0, 0, time.UTC),
+			},
+			`{"daily": {"at": ["midnight", "noon"]}}`: {
+				time.Date(2018, time.March, 20, 12, 0, 0, 0, time.UTC),
+				time.Date(2018, time.March, 21, 0, 0, 0, 0, time.UTC),
+			},
+			`{"daily": {"at": {"hour": [9, 17], "minute": 30}}}`: {
+				time.Date(2018, time.March, 20, 17, 30, 0, 0, time.UTC),
+				time.Date(2018, time.March, 21, 9, 30, 0, 0, time.UTC),
+			},
+			`{"weekly": [{"on": "friday", "at": "17:00"}, {"on": "monday", "at": "08:00"}]}`: {
+				time.Date(2018, time.March, 23, 17, 0, 0, 0, time.UTC),
+				time.Date(2018, time.March, 26, 8, 0, 0, 0, time.UTC),
+			},
+			`{"monthly": {"on": [1, 31], "at": "noon"}}`: {
+				time.Date(2018, time.March, 31, 12, 0, 0, 0, time.UTC),
+				time.Date(2018, time.April, 1, 12, 0, 0, 0, time.UTC),
+			},
+			`{"monthly": {"on": "last_day", "at": "noon"}}`: {
+				time.Date(2018, time.March, 31, 12, 0, 0, 0, time.UTC),
+				time.Date(2018, time.April, 30, 12, 0, 0, 0, time.UTC),
+			},
+			`{"yearly": {"in": ["february", "june"], "on": [10, "last_day"], "at": "08:15"}}`: {
+				time.Date(2018, time.June, 10, 8, 15, 0, 0, time.UTC),
+				time.Date(2018, time.June, 30, 8, 15, 0, 0, time.UTC),
+			},
+			`{"yearly": {"in": "june", "on": 10, "at": "08:15"}}`: {
+				time.Date(2018, time.June, 10, 8, 15, 0, 0, time.UTC),
+				time.Date(2019, time.June, 10, 8, 15, 0, 0, time.UTC),
+			},
+			`{"cron": ["0 0 9 ? * MON", "0 0 8 ? * TUE"]}`: {
+				time.Date(2018, time.March, 26, 9, 0, 0, 0, time.UTC),
+				time.Date(2018, time.March, 27, 8, 0, 0, 0, time.UTC),
+			},
+		} {
+			preview, err := parseSchedule(schedule)
+
+			Expect(err).ShouldNot(HaveOccurred(), schedule)
+			Expect(preview.Descriptions).ShouldNot(BeEmpty(), schedule)
+			Expect(preview.Next(from, 2)).Should(Equal(expected), schedule)
+		}
+	})
+
+	It("should describe the schedules", func() {
+		preview, err := parseSchedule(`{"weekly": {"on": ["mon", "friday"], "at": ["17:00", "09:30"]}}`)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(preview.Descriptions).Should(Equal([]string{"weekly on Monday, Friday at 09:30, 17:00 UTC"}))
+	})
+
+	It("should describe the schedules on the last day of the month", func() {
+		preview, err := parseSchedule(`{"monthly": [{"on": "last_day", "at": "noon"}, {"on": [1, "last_day"], "at": "09:00"}]}`)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(preview.Descriptions).Should(Equal([]string{
+			"monthly on the last day at 12:00 UTC",
+			"monthly on day 1 and the last day at 09:00 UTC",
+		}))
+	})
+
+	It("should reject the invalid schedules", func() {
+		for _, schedule := range []string{
+			`{"cron": "0 0 12 * * *"}`,
+			`{"interval": "5x"}`,
+			`{"daily": {"at": "25:00"}}`,
+			`{"weekly": {"on": "someday"}}`,
+			`{"monthly": {"on": 32}}`,
+			`{"daily": {}, "hourly": {}}`,
+			`{"every": "5m"}`,
+		} {
+			_, err := parseSchedule(schedule)
+			Expect(err).Should(HaveOccurred(), schedule)
+		}
+	})
+
+	Context("schedule command", func() {
+		var watchesFile string
+
+		BeforeEach(func() {
+			file, err := ioutil.TempFile("", "watches")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = file.Write([]byte(`{"watches": [
+				{"name": "watch_daily", "body": {"trigger": {"schedule": {"daily": {"at": "noon"}}}}},
+				{"name": "watch_broken", "body": {"trigger": {"schedule": {"cron": "0 0 12 * * *"}}}}
+			]}`))
+			Expect(err).ShouldNot(HaveOccurred())
+			file.Close()
+			watchesFile = file.Name()
+		})
+
+		AfterEach(func() {
+			os.Remove(watchesFile)
+		})
+
+		It("should preview the schedules in a timezone", func() {
+			cmd := &scheduleCmd{
+				watchesFile: watchesFile,
+				watches:     "watch_daily",
+				count:       3,
+				timezone:    "America/New_York",
+				from:        "2018-03-20T10:30:00Z"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		})
+
+		It("should fail on the invalid schedules", func() {
+			cmd := &scheduleCmd{
+				watchesFile: watchesFile,
+				from:        "2018-03-20T10:30:00Z"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+
+		It("should reject an unknown timezone", func() {
+			cmd := &scheduleCmd{
+				watchesFile: watchesFile,
+				timezone:    "Mars/Olympus"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+		})
+	})
+})
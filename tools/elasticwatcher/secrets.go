@@ -0,0 +1,241 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// secretEnvPrefix prefix of the environment variables holding secrets, e.g.
+	// ELASTICWATCHER_SECRET_TEAMS_WEBHOOK resolves the placeholder ${secret:teams_webhook}
+	secretEnvPrefix = "ELASTICWATCHER_SECRET_"
+	// maskedValue replaces the values of the sensitive fields in the output
+	maskedValue = "::masked::"
+)
+
+// secretPlaceholderPattern matches the secret placeholders, e.g. ${secret:teams_webhook}
+var secretPlaceholderPattern = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_]+)\}`)
+
+// sensitiveFieldPattern matches the names of the fields whose values are always masked
+var sensitiveFieldPattern = regexp.MustCompile(`(?i)(password|secret|token|authorization)`)
+
+// loadSecrets loads the secrets from the environment variables and from the optional secrets file,
+// which is a JSON object mapping the secret names to their values. The secrets file takes precedence.
+func loadSecrets(secretsFile string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for _, env := range os.Environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 && strings.HasPrefix(parts[0], secretEnvPrefix) && parts[0] != secretEnvPrefix {
+			secrets[strings.ToLower(strings.TrimPrefix(parts[0], secretEnvPrefix))] = parts[1]
+		}
+	}
+
+	if secretsFile == "" {
+		return secrets, nil
+	}
+	content, err := ioutil.ReadFile(secretsFile) // #nosec
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the secrets file: %v", err)
+	}
+	var fileSecrets map[string]string
+	err = json.Unmarshal(content, &fileSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal the secrets: %v", describeJSONError(content, err))
+	}
+	for name, value := range fileSecrets {
+		secrets[name] = value
+	}
+	return secrets, nil
+}
+
+func lookupSecret(secrets map[string]string, name string) (string, bool) {
+	if value, ok := secrets[name]; ok {
+		return value, true
+	}
+	value, ok := secrets[strings.ToLower(name)]
+	return value, ok
+}
+
+// resolveSecrets replaces the secret placeholders in the string values of a watch body and
+// collects the names of the secrets which are not defined
+func resolveSecrets(value interface{}, secrets map[string]string, missing map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved[key] = resolveSecrets(item, secrets, missing)
+		}
+		return resolved
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved[i] = resolveSecrets(item, secrets, missing)
+		}
+		return resolved
+	case string:
+		return secretPlaceholderPattern.ReplaceAllStringFunc(v, func(placeholder string) string {
+			name := secretPlaceholderPattern.FindStringSubmatch(placeholder)[1]
+			secret, ok := lookupSecret(secrets, name)
+			if !ok {
+				missing[name] = true
+				return placeholder
+			}
+			return secret
+		})
+	default:
+		return value
+	}
+}
+
+// injectSecrets resolves the secret placeholders of all the watches. It fails if a secret is not defined.
+func injectSecrets(watches []Watch, secrets map[string]string) ([]Watch, error) {
+	injected := make([]Watch, 0, len(watches))
+	for _, watch := range watches {
+		body, err := normalizeWatchBody(watch.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to normalize the watch '%s': %v", watch.Name, err)
+		}
+		missing := make(map[string]bool)
+		resolved := resolveSecrets(body, secrets, missing)
+		if len(missing) > 0 {
+			names := make([]string, 0, len(missing))
+			for name := range missing {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("Undefined secrets in the watch '%s': %s. Define them in the secrets file or as %s<NAME> environment variables",
+				watch.Name, strings.Join(names, ", "), secretEnvPrefix)
+		}
+		injected = append(injected, Watch{Name: watch.Name, Body: resolved})
+	}
+	return injected, nil
+}
+
+// maskSecretValues replaces the known secret values in the string values of a watch body with their placeholders
+func maskSecretValues(value interface{}, secrets map[string]string) interface{} {
+	// The longest values are replaced first, such that a secret containing another one is fully masked
+	names := make([]string, 0, len(secrets))
+	for name, secret := range secrets {
+		if secret != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(secrets[names[i]]) != len(secrets[names[j]]) {
+			return len(secrets[names[i]]) > len(secrets[names[j]])
+		}
+		return names[i] < names[j]
+	})
+
+	var mask func(value interface{}) interface{}
+	mask = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			masked := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				masked[key] = mask(item)
+			}
+			return masked
+		case []interface{}:
+			masked := make([]interface{}, len(v))
+			for i, item := range v {
+				masked[i] = mask(item)
+			}
+			return masked
+		case string:
+			for _, name := range names {
+				v = strings.Replace(v, secrets[name], fmt.Sprintf("${secret:%s}", name), -1)
+			}
+			return v
+		default:
+			return value
+		}
+	}
+	return mask(value)
+}
+
+// maskSecrets masks the known secret values with their placeholders and the values of the sensitive fields,
+// e.g. passwords, such that a watch can be printed
+func maskSecrets(value interface{}, secrets map[string]string) interface{} {
+	var maskFields func(value interface{}) interface{}
+	maskFields = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			masked := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				s, isString := item.(string)
+				if isString && sensitiveFieldPattern.MatchString(key) && !secretPlaceholderPattern.MatchString(s) {
+					masked[key] = maskedValue
+				} else {
+					masked[key] = maskFields(item)
+				}
+			}
+			return masked
+		case []interface{}:
+			masked := make([]interface{}, len(v))
+			for i, item := range v {
+				masked[i] = maskFields(item)
+			}
+			return masked
+		default:
+			return value
+		}
+	}
+	return maskFields(maskSecretValues(value, secrets))
+}
+
+// unresolvedSensitivePaths lists the JSON paths of the sensitive fields, e.g. passwords, whose values are
+// not secret placeholders
+func unresolvedSensitivePaths(value interface{}, path string) []string {
+	var paths []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			s, isString := v[key].(string)
+			if isString && sensitiveFieldPattern.MatchString(key) && !secretPlaceholderPattern.MatchString(s) {
+				paths = append(paths, jsonPathKey(path, key))
+				continue
+			}
+			paths = append(paths, unresolvedSensitivePaths(v[key], jsonPathKey(path, key))...)
+		}
+	case []interface{}:
+		for i, item := range v {
+			paths = append(paths, unresolvedSensitivePaths(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return paths
+}
+
+// maskJSON masks the secrets in a JSON document and indents it
+func maskJSON(content []byte, secrets map[string]string) ([]byte, error) {
+	var document interface{}
+	err := json.Unmarshal(content, &document)
+	if err != nil {
+		return nil, err
+	}
+	return marshalIndent(maskSecrets(document, secrets))
+}
+
+// marshalIndent encodes a value as indented JSON without escaping the HTML characters, e.g. in URLs
+func marshalIndent(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	err := enc.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher secrets", func() {
+	var secretsFile string
+	const Secrets = `{"teams_webhook": "/webhook/abc?token=1&x=2"}`
+	const Watches = `{"watches": [{"name": "watch_teams", "body": {
+		"actions": {"teams": {"webhook": {"path": "${secret:teams_webhook}",
+			"auth": {"basic": {"username": "elk", "password": "${secret:WEBHOOK_PASSWORD}"}}}}}
+	}}]}`
+
+	writeTempFile := func(prefix string, content string) string {
+		file, err := ioutil.TempFile("", prefix)
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = file.Write([]byte(content))
+		Expect(err).ShouldNot(HaveOccurred())
+		file.Close()
+		return file.Name()
+	}
+
+	BeforeEach(func() {
+		secretsFile = writeTempFile("secrets", Secrets)
+		os.Setenv(secretEnvPrefix+"WEBHOOK_PASSWORD", "s3cr3t")
+	})
+
+	AfterEach(func() {
+		os.Remove(secretsFile)
+		os.Unsetenv(secretEnvPrefix + "WEBHOOK_PASSWORD")
+	})
+
+	Context("injection", func() {
+		It("should resolve the placeholders from the secrets file and the environment", func() {
+			secrets, err := loadSecrets(secretsFile)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			watches, err := injectSecrets([]Watch{{Name: "watch_teams", Body: map[string]interface{}{
+				"path":     "${secret:teams_webhook}",
+				"password": "${secret:WEBHOOK_PASSWORD}",
+				"text":     "Count {{ctx.payload.hits.total}}",
+			}}}, secrets)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(watches[0].Body).Should(Equal(map[string]interface{}{
+				"path":     "/webhook/abc?token=1&x=2",
+				"password": "s3cr3t",
+				"text":     "Count {{ctx.payload.hits.total}}",
+			}))
+		})
+
+		It("should fail on undefined secrets", func() {
+			_, err := injectSecrets([]Watch{{Name: "watch_teams", Body: map[string]interface{}{
+				"path": []interface{}{"${secret:missing_b}", "${secret:missing_a}"},
+			}}}, map[string]string{})
+
+			Expect(err).Should(MatchError(ContainSubstring("missing_a, missing_b")))
+		})
+	})
+
+	Context("masking", func() {
+		It("should replace the secret values with placeholders and mask the sensitive fields", func() {
+			secrets := map[string]string{"teams_webhook": "/webhook/abc"}
+			body := map[string]interface{}{
+				"path":    "/webhook/abc?x=1",
+				"headers": map[string]interface{}{"Authorization": "Basic ZWxrOmVsaw=="},
+				"auth":    map[string]interface{}{"basic": map[string]interface{}{"username": "elk", "password": "::es_redacted::"}},
+			}
+
+			Expect(maskSecrets(body, secrets)).Should(Equal(map[string]interface{}{
+				"path":    "${secret:teams_webhook}?x=1",
+				"headers": map[string]interface{}{"Authorization": maskedValue},
+				"auth":    map[string]interface{}{"basic": map[string]interface{}{"username": "elk", "password": maskedValue}},
+			}))
+		})
+
+		It("should not escape the HTML characters of the masked JSON", func() {
+			content, err := maskJSON([]byte(`{"url": "/a?b=1&c=2"}`), nil)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(content)).Should(Equal("{\n    \"url\": \"/a?b=1&c=2\"\n}"))
+		})
+	})
+
+	Context("create command", func() {
+		var server *ghttp.Server
+		var watchesFile string
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			watchesFile = writeTempFile("watches", Watches)
+		})
+
+		AfterEach(func() {
+			os.Remove(watchesFile)
+			server.Close()
+		})
+
+		It("should create the watches with the resolved secrets", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", "/_xpack/watcher/watch/watch_teams"),
+					verifyStampedWatch(`{"actions": {"teams": {"webhook": {"path": "/webhook/abc?token=1&x=2",
+						"auth": {"basic": {"username": "elk", "password": "s3cr3t"}}}}}}`),
+				),
+			)
+
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+			elasticPort, err := strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			cmd := &createCmd{
+				host:        host,
+				port:        elasticPort,
+				watchesFile: watchesFile,
+				secretsFile: secretsFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should not create any watch when a secret is undefined", func() {
+			os.Unsetenv(secretEnvPrefix + "WEBHOOK_PASSWORD")
+
+			cmd := &createCmd{
+				host:        "localhost",
+				port:        9200,
+				watchesFile: watchesFile,
+				secretsFile: secretsFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// watchSelection selects watches either by an exact list of names, or by resolving a glob pattern,
+// a regular expression or all against the installed watches
+type watchSelection struct {
+	watches string
+	match   string
+	regex   string
+	all     bool
+}
+
+// isPattern indicates if the selection has to be resolved against the installed watches
+func (s watchSelection) isPattern() bool {
+	return s.match != "" || s.regex != "" || s.all
+}
+
+func (s watchSelection) validate() error {
+	count := 0
+	for _, set := range []bool{s.watches != "", s.match != "", s.regex != "", s.all} {
+		if set {
+			count++
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("No watches selected, use one of -watches, -match, -regex or -all")
+	}
+	if count > 1 {
+		return fmt.Errorf("Only one of -watches, -match, -regex or -all can be used")
+	}
+	return nil
+}
+
+// filterWatchNames returns the names which match the glob pattern, the regular expression or all names
+func (s watchSelection) filterWatchNames(names []string) ([]string, error) {
+	var re *regexp.Regexp
+	if s.regex != "" {
+		var err error
+		re, err = regexp.Compile(s.regex)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression '%s': %v", s.regex, err)
+		}
+	}
+
+	var selected []string
+	for _, name := range names {
+		switch {
+		case s.all:
+			selected = append(selected, name)
+		case re != nil:
+			if re.MatchString(name) {
+				selected = append(selected, name)
+			}
+		case s.match != "":
+			matched, err := path.Match(s.match, name)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid pattern '%s': %v", s.match, err)
+			}
+			if matched {
+				selected = append(selected, name)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// resolve returns the names of the selected watches. The exact list of names is used as it is,
+// while the patterns are resolved against the installed watches.
+func (s watchSelection) resolve(host string, port int, authFile string) ([]string, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if !s.isPattern() {
+		return parseWatchNames(s.watches), nil
+	}
+
+	installed, err := fetchInstalledWatches(host, port, authFile)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(installed))
+	for _, watch := range installed {
+		names = append(names, watch.ID)
+	}
+
+	selected, err := s.filterWatchNames(names)
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("No installed watch matches the selection")
+	}
+	return selected, nil
+}
+
+// resolveWatchNames resolves the watches selected by the command flags and prints the resolved set.
+// A destructive action on more than one watch requires an explicit confirmation.
+func resolveWatchNames(host string, port int, authFile string, selection watchSelection, action string, destructive bool, yes bool) ([]string, error) {
+	names, err := selection.resolve(host, port, authFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Selected watches (%d): %s\n", len(names), strings.Join(names, ", "))
+	if destructive && len(names) > 1 && !yes {
+		return nil, fmt.Errorf("Refusing to %s %d watches without the -yes flag", action, len(names))
+	}
+	return names, nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher watch selection", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	const watcherEndpoint = "/_xpack/watcher/watch"
+	const watchesSearchEndpoint = "/.watches/_search"
+	const SearchResponse = `{"hits": {"hits": [{"_id": "watch_http_404"}, {"_id": "watch_http_500"}, {"_id": "watch_disk"}]}}`
+	var names = []string{"watch_http_404", "watch_http_500", "watch_disk"}
+
+	Context("filter", func() {
+		It("should select the watches with a glob pattern", func() {
+			selected, err := watchSelection{match: "watch_http_*"}.filterWatchNames(names)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(selected).Should(Equal([]string{"watch_http_404", "watch_http_500"}))
+		})
+
+		It("should select the watches with a regular expression", func() {
+			selected, err := watchSelection{regex: "_(404|disk)$"}.filterWatchNames(names)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(selected).Should(Equal([]string{"watch_http_404", "watch_disk"}))
+		})
+
+		It("should select all the watches", func() {
+			selected, err := watchSelection{all: true}.filterWatchNames(names)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(selected).Should(Equal(names))
+		})
+
+		It("should reject combined or missing selections", func() {
+			Expect(watchSelection{}.validate()).ShouldNot(Succeed())
+			Expect(watchSelection{watches: "a", all: true}.validate()).ShouldNot(Succeed())
+			Expect(watchSelection{match: "a*"}.validate()).Should(Succeed())
+		})
+	})
+
+	Context("commands", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			elasticHost = host
+			elasticPort, err = strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should deactivate the watches matching a pattern when confirmed", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_http_404/_deactivate"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_http_500/_deactivate"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &deactivateCmd{
+				host:  elasticHost,
+				port:  elasticPort,
+				match: "watch_http_*",
+				yes:   true}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+
+		It("should refuse to delete several watches without confirmation", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+			)
+
+			cmd := &deleteCmd{
+				host: elasticHost,
+				port: elasticPort,
+				all:  true}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should refuse to deactivate an explicit list of several watches without confirmation", func() {
+			cmd := &deactivateCmd{
+				host:    elasticHost,
+				port:    elasticPort,
+				watches: "watch_a,watch_b"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+
+		It("should delete a single explicit watch without confirmation", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", "/_xpack/watcher/watch/watch_a"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &deleteCmd{
+				host:    elasticHost,
+				port:    elasticPort,
+				watches: "watch_a"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should retrieve the watches matching a regular expression without confirmation", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_http_404"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_http_500"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &retrieveCmd{
+				host:  elasticHost,
+				port:  elasticPort,
+				regex: "^watch_http_"}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+	})
+})
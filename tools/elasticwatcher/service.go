@@ -0,0 +1,243 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/google/subcommands"
+)
+
+func buildWatcherServiceURL(host string, port int, fragment string) string {
+	return fmt.Sprintf("http://%s:%d/_xpack/watcher/%s", host, port, fragment)
+}
+
+// watcherNodeStats the Watcher statistics of a node
+type watcherNodeStats struct {
+	NodeID              string `json:"node_id"`
+	WatcherState        string `json:"watcher_state"`
+	WatchCount          int    `json:"watch_count"`
+	ExecutionThreadPool struct {
+		QueueSize int `json:"queue_size"`
+		MaxSize   int `json:"max_size"`
+	} `json:"execution_thread_pool"`
+	CurrentWatches []watcherExecution `json:"current_watches"`
+	QueuedWatches  []watcherExecution `json:"queued_watches"`
+}
+
+// watcherExecution a watch which is executing or waiting to be executed
+type watcherExecution struct {
+	WatchID        string `json:"watch_id"`
+	WatchRecordID  string `json:"watch_record_id"`
+	TriggeredTime  string `json:"triggered_time"`
+	ExecutionTime  string `json:"execution_time"`
+	ExecutionPhase string `json:"execution_phase"`
+}
+
+// parseWatcherStats parses the stats response, which holds a list of node stats since Elasticsearch 6
+// and the stats of a single node in previous versions
+func parseWatcherStats(content []byte) (bool, []watcherNodeStats, error) {
+	var resp struct {
+		ManuallyStopped bool               `json:"manually_stopped"`
+		Stats           []watcherNodeStats `json:"stats"`
+		watcherNodeStats
+	}
+	err := json.Unmarshal(content, &resp)
+	if err != nil {
+		return false, nil, err
+	}
+	if resp.Stats == nil {
+		return resp.ManuallyStopped, []watcherNodeStats{resp.watcherNodeStats}, nil
+	}
+	return resp.ManuallyStopped, resp.Stats, nil
+}
+
+// controlWatcher starts or stops the Watcher service
+func controlWatcher(host string, port int, authFile string, action string) error {
+	serviceURL := buildWatcherServiceURL(host, port, "_"+action)
+	statusCode, content, err := doRequest(http.MethodPost, serviceURL, authFile, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to %s the watcher service. Error: %v", action, err)
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("Failed to %s the watcher service. Status Code: %d. Error: %s", action, statusCode, string(content))
+	}
+
+	var prettyContent bytes.Buffer
+	err = json.Indent(&prettyContent, content, "", "    ")
+	if err != nil {
+		return fmt.Errorf("Failed to indent the content of %s response. Error: %v", action, err)
+	}
+
+	fmt.Printf("%s watcher service:\n", action)
+	fmt.Print(string(prettyContent.Bytes()))
+	fmt.Println()
+	return nil
+}
+
+type statsCmd struct {
+	host     string
+	port     int
+	authFile string
+}
+
+func (*statsCmd) Name() string { return "stats" }
+func (*statsCmd) Synopsis() string {
+	return "Show the state and the statistics of the Elasticsearch Watcher service"
+}
+
+func (*statsCmd) Usage() string {
+	return `stats [-host] <host name> [-port] <port> [-auth-file] <path to basic auth file>
+        Show if Elasticsearch Watcher is started, the number of watches, the execution queue and the executing watches
+	`
+}
+
+func (s *statsCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&s.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&s.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (s *statsCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	statsURL := buildWatcherServiceURL(s.host, s.port, "stats/_all")
+	statusCode, content, err := doRequest(http.MethodGet, statsURL, s.authFile, nil)
+	if err != nil {
+		fmt.Printf("Failed to retrieve the watcher stats. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	if statusCode != http.StatusOK {
+		fmt.Printf("Failed to retrieve the watcher stats. Status Code: %d. Error: %v\n", statusCode, string(content))
+		return subcommands.ExitFailure
+	}
+
+	manuallyStopped, nodes, err := parseWatcherStats(content)
+	if err != nil {
+		fmt.Printf("Failed to parse the watcher stats. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+
+	fmt.Printf("Manually stopped: %t\n", manuallyStopped)
+	for _, node := range nodes {
+		if node.NodeID != "" {
+			fmt.Printf("Node: %s\n", node.NodeID)
+		}
+		fmt.Printf("  Watcher state:   %s\n", node.WatcherState)
+		fmt.Printf("  Watch count:     %d\n", node.WatchCount)
+		fmt.Printf("  Execution queue: %d (max size %d)\n", node.ExecutionThreadPool.QueueSize, node.ExecutionThreadPool.MaxSize)
+		fmt.Printf("  Executing watches: %d\n", len(node.CurrentWatches))
+		for _, execution := range node.CurrentWatches {
+			fmt.Printf("    %s (phase: %s, triggered: %s, started: %s)\n", execution.WatchID,
+				execution.ExecutionPhase, execution.TriggeredTime, execution.ExecutionTime)
+		}
+		fmt.Printf("  Queued watches: %d\n", len(node.QueuedWatches))
+		for _, execution := range node.QueuedWatches {
+			fmt.Printf("    %s (triggered: %s)\n", execution.WatchID, execution.TriggeredTime)
+		}
+	}
+
+	return subcommands.ExitSuccess
+}
+
+type startCmd struct {
+	host     string
+	port     int
+	authFile string
+}
+
+func (*startCmd) Name() string { return "start" }
+func (*startCmd) Synopsis() string {
+	return "Start the Elasticsearch Watcher service"
+}
+
+func (*startCmd) Usage() string {
+	return `start [-host] <host name> [-port] <port> [-auth-file] <path to basic auth file>
+        Start the Elasticsearch Watcher service
+	`
+}
+
+func (s *startCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&s.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&s.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (s *startCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := controlWatcher(s.host, s.port, s.authFile, "start"); err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type stopCmd struct {
+	host     string
+	port     int
+	authFile string
+}
+
+func (*stopCmd) Name() string { return "stop" }
+func (*stopCmd) Synopsis() string {
+	return "Stop the Elasticsearch Watcher service"
+}
+
+func (*stopCmd) Usage() string {
+	return `stop [-host] <host name> [-port] <port> [-auth-file] <path to basic auth file>
+        Stop the Elasticsearch Watcher service
+	`
+}
+
+func (s *stopCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&s.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&s.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (s *stopCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if err := controlWatcher(s.host, s.port, s.authFile, "stop"); err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type restartCmd struct {
+	host     string
+	port     int
+	authFile string
+}
+
+func (*restartCmd) Name() string { return "restart" }
+func (*restartCmd) Synopsis() string {
+	return "Restart the Elasticsearch Watcher service"
+}
+
+func (*restartCmd) Usage() string {
+	return `restart [-host] <host name> [-port] <port> [-auth-file] <path to basic auth file>
+        Restart the Elasticsearch Watcher service by stopping and starting it
+	`
+}
+
+func (r *restartCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&r.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&r.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&r.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (r *restartCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	// The restart API was removed in Elasticsearch 6, hence the service is stopped and started explicitly
+	for _, action := range []string{"stop", "start"} {
+		if err := controlWatcher(r.host, r.port, r.authFile, action); err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,146 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher service", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	const watcherEndpoint = "/_xpack/watcher"
+	const NodesStatsResponse = `{"manually_stopped": false, "stats": [{
+		"node_id": "node-1",
+		"watcher_state": "started",
+		"watch_count": 2,
+		"execution_thread_pool": {"queue_size": 1, "max_size": 10},
+		"current_watches": [{"watch_id": "watch_test", "execution_phase": "input"}]
+	}]}`
+	const NodeStatsResponse = `{"watcher_state": "stopped", "watch_count": 5,
+		"execution_thread_pool": {"queue_size": 0, "max_size": 0}}`
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		u, err := url.Parse(server.URL())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		host, port, err := net.SplitHostPort(u.Host)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		elasticHost = host
+		elasticPort, err = strconv.Atoi(port)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("stats", func() {
+		It("should parse the stats of every node", func() {
+			stopped, nodes, err := parseWatcherStats([]byte(NodesStatsResponse))
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stopped).Should(BeFalse())
+			Expect(nodes).Should(HaveLen(1))
+			Expect(nodes[0].WatchCount).Should(Equal(2))
+			Expect(nodes[0].ExecutionThreadPool.QueueSize).Should(Equal(1))
+			Expect(nodes[0].CurrentWatches[0].WatchID).Should(Equal("watch_test"))
+		})
+
+		It("should parse the stats of a single node", func() {
+			_, nodes, err := parseWatcherStats([]byte(NodeStatsResponse))
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(nodes).Should(HaveLen(1))
+			Expect(nodes[0].WatcherState).Should(Equal("stopped"))
+			Expect(nodes[0].WatchCount).Should(Equal(5))
+		})
+
+		It("should show the stats", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/stats/_all"),
+					ghttp.RespondWith(http.StatusOK, NodesStatsResponse),
+				),
+			)
+
+			cmd := &statsCmd{
+				host: elasticHost,
+				port: elasticPort}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+	})
+
+	Context("service control", func() {
+		It("should start the service", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", watcherEndpoint+"/_start"),
+					ghttp.RespondWith(http.StatusOK, `{"acknowledged": true}`),
+				),
+			)
+
+			cmd := &startCmd{
+				host: elasticHost,
+				port: elasticPort}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should stop the service", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", watcherEndpoint+"/_stop"),
+					ghttp.RespondWith(http.StatusOK, `{"acknowledged": true}`),
+				),
+			)
+
+			cmd := &stopCmd{
+				host: elasticHost,
+				port: elasticPort}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("should restart the service", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", watcherEndpoint+"/_stop"),
+					ghttp.RespondWith(http.StatusOK, `{"acknowledged": true}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", watcherEndpoint+"/_start"),
+					ghttp.RespondWith(http.StatusOK, `{"acknowledged": true}`),
+				),
+			)
+
+			cmd := &restartCmd{
+				host: elasticHost,
+				port: elasticPort}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(2))
+		})
+	})
+})
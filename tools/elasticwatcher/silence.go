@@ -0,0 +1,435 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+const (
+	// defaultSilencesIndex index where the maintenance windows are recorded
+	defaultSilencesIndex = "elasticwatcher-silences"
+	// silenceActive state of a maintenance window which did not end yet
+	silenceActive = "active"
+	// silenceEnded state of a maintenance window which expired or was ended manually
+	silenceEnded = "ended"
+)
+
+// silence a maintenance window during which a list of watches is deactivated
+type silence struct {
+	ID        string    `json:"-"`
+	Watches   []string  `json:"watches"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	State     string    `json:"state"`
+	// Active records if each watch was active when the maintenance window started, such that
+	// the watches which were deactivated on purpose are not reactivated when it ends
+	Active map[string]bool `json:"active,omitempty"`
+}
+
+func buildSilencesURL(host string, port int, index string, fragment string) string {
+	return fmt.Sprintf("http://%s:%d/%s/%s", host, port, index, fragment)
+}
+
+// recordSilence stores a maintenance window and returns its ID
+func recordSilence(host string, port int, authFile string, index string, s silence) (string, error) {
+	silencesURL := buildSilencesURL(host, port, index, "_doc?refresh=true")
+	statusCode, content, err := doRequest(http.MethodPost, silencesURL, authFile, s)
+	if err != nil {
+		return "", fmt.Errorf("Failed to record the silence. Error: %v", err)
+	}
+	if statusCode != http.StatusCreated && statusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to record the silence. Status Code: %d. Error: %s", statusCode, string(content))
+	}
+
+	var resp struct {
+		ID string `json:"_id"`
+	}
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse the silence record response. Error: %v", err)
+	}
+	return resp.ID, nil
+}
+
+// fetchActiveSilences returns the maintenance windows which were not ended yet
+func fetchActiveSilences(host string, port int, authFile string, index string) ([]silence, error) {
+	query := map[string]interface{}{
+		"size":  1000,
+		"query": map[string]interface{}{"match": map[string]interface{}{"state": silenceActive}},
+	}
+	statusCode, content, err := doRequest(http.MethodPost, buildSilencesURL(host, port, index, "_search"), authFile, query)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to search the silences. Error: %v", err)
+	}
+	if statusCode == http.StatusNotFound {
+		// No silence was recorded yet
+		return nil, nil
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to search the silences. Status Code: %d. Error: %s", statusCode, string(content))
+	}
+
+	var resp struct {
+		Hits struct {
+			Hits []struct {
+				ID     string  `json:"_id"`
+				Source silence `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the silences. Error: %v", err)
+	}
+
+	silences := make([]silence, 0, len(resp.Hits.Hits))
+	for _, hit := range resp.Hits.Hits {
+		s := hit.Source
+		s.ID = hit.ID
+		silences = append(silences, s)
+	}
+	return silences, nil
+}
+
+// endSilence marks a maintenance window as ended
+func endSilence(host string, port int, authFile string, index string, id string) error {
+	update := map[string]interface{}{"doc": map[string]interface{}{"state": silenceEnded}}
+	silenceURL := buildSilencesURL(host, port, index, fmt.Sprintf("_doc/%s/_update?refresh=true", id))
+	statusCode, content, err := doRequest(http.MethodPost, silenceURL, authFile, update)
+	if err != nil {
+		return fmt.Errorf("Failed to end the silence '%s'. Error: %v", id, err)
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("Failed to end the silence '%s'. Status Code: %d. Error: %s", id, statusCode, string(content))
+	}
+	return nil
+}
+
+// fetchWatchActive returns if an installed watch is active
+func fetchWatchActive(host string, port int, authFile string, watch string) (bool, error) {
+	statusCode, content, err := doRequest(http.MethodGet, buildWatcherURL(host, port, watch), authFile, nil)
+	if err != nil {
+		return false, fmt.Errorf("Failed to retrieve the watch '%s'. Error: %v", watch, err)
+	}
+	if statusCode != http.StatusOK {
+		return false, fmt.Errorf("Failed to retrieve the watch '%s'. Status Code: %d. Error: %s", watch, statusCode, string(content))
+	}
+
+	var resp struct {
+		Status struct {
+			State struct {
+				Active bool `json:"active"`
+			} `json:"state"`
+		} `json:"status"`
+	}
+	err = json.Unmarshal(content, &resp)
+	if err != nil {
+		return false, fmt.Errorf("Failed to parse the watch '%s'. Error: %v", watch, err)
+	}
+	return resp.Status.State.Active, nil
+}
+
+// watchesActiveState returns if each watch was active before the maintenance window. The watches already
+// deactivated by another active silence keep the state recorded by that silence.
+func watchesActiveState(host string, port int, authFile string, watches []string, active []silence) (map[string]bool, error) {
+	recorded := make(map[string]bool)
+	for _, s := range active {
+		for _, watch := range s.Watches {
+			if state, ok := s.Active[watch]; ok {
+				recorded[watch] = recorded[watch] || state
+			} else if s.Active == nil {
+				// the silences recorded without the state assume the watches were active
+				recorded[watch] = true
+			}
+		}
+	}
+
+	states := make(map[string]bool, len(watches))
+	for _, watch := range watches {
+		if state, ok := recorded[watch]; ok {
+			states[watch] = state
+			continue
+		}
+		state, err := fetchWatchActive(host, port, authFile, watch)
+		if err != nil {
+			return nil, err
+		}
+		states[watch] = state
+	}
+	return states, nil
+}
+
+// watchesToReactivate returns the watches of the ending silences which were active before the silence
+// and are not part of another active silence
+func watchesToReactivate(ending []silence, remaining []silence) []string {
+	silenced := make(map[string]bool)
+	for _, s := range remaining {
+		for _, watch := range s.Watches {
+			silenced[watch] = true
+		}
+	}
+
+	reactivate := make(map[string]bool)
+	for _, s := range ending {
+		for _, watch := range s.Watches {
+			if active, ok := s.Active[watch]; ok && !active {
+				continue
+			}
+			if !silenced[watch] {
+				reactivate[watch] = true
+			}
+		}
+	}
+
+	watches := make([]string, 0, len(reactivate))
+	for watch := range reactivate {
+		watches = append(watches, watch)
+	}
+	sort.Strings(watches)
+	return watches
+}
+
+// endSilences reactivates the watches of the ending silences and marks them as ended
+func endSilences(host string, port int, authFile string, index string, ending []silence, remaining []silence) subcommands.ExitStatus {
+	for _, watch := range watchesToReactivate(ending, remaining) {
+		rc := switchWatch(host, port, authFile, watch, "activate")
+		if rc != subcommands.ExitSuccess {
+			return rc
+		}
+	}
+
+	for _, s := range ending {
+		if err := endSilence(host, port, authFile, index, s.ID); err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("Ended silence '%s' of watches %s (%s).\n", s.ID, strings.Join(s.Watches, ", "), s.Reason)
+	}
+	return subcommands.ExitSuccess
+}
+
+func currentUser() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "unknown"
+}
+
+type silenceCmd struct {
+	host     string
+	port     int
+	authFile string
+	watches  string
+	match    string
+	regex    string
+	all      bool
+	yes      bool
+	duration time.Duration
+	reason   string
+	by       string
+	index    string
+}
+
+func (*silenceCmd) Name() string { return "silence" }
+func (*silenceCmd) Synopsis() string {
+	return "Deactivate a list of watches from Elasicsearch Watcher for a maintenance window"
+}
+
+func (*silenceCmd) Usage() string {
+	return `silence [-host] <host name> [-port] <port> [-watches] <comma separated list of watchers> [-match] <glob pattern> [-regex] <regular expression> [-all] [-yes]
+        [-duration] <duration> [-reason] <reason> [-by] <name> [-index] <silences index> [-auth-file] <path to basic auth file>
+        Deactivate a list of watches and record the maintenance window, the watches are reactivated by reconcile-silences once it expired
+	`
+}
+
+func (s *silenceCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&s.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&s.watches, "watches", "", "Comma separated list with watches names")
+	f.StringVar(&s.match, "match", "", "Glob pattern selecting the installed watches, e.g. watch_http_*")
+	f.StringVar(&s.regex, "regex", "", "Regular expression selecting the installed watches")
+	f.BoolVar(&s.all, "all", false, "Select all the installed watches")
+	f.BoolVar(&s.yes, "yes", false, "Confirm the silence of more than one watch")
+	f.DurationVar(&s.duration, "duration", time.Hour, "Duration of the maintenance window, e.g. 30m or 2h")
+	f.StringVar(&s.reason, "reason", "", "Reason of the maintenance window")
+	f.StringVar(&s.by, "by", currentUser(), "Name of the person who silences the watches")
+	f.StringVar(&s.index, "index", defaultSilencesIndex, "Index where the maintenance windows are recorded")
+	f.StringVar(&s.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (s *silenceCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if s.duration <= 0 {
+		fmt.Println("The duration of the maintenance window must be positive")
+		return subcommands.ExitUsageError
+	}
+	if s.reason == "" {
+		fmt.Println("The reason of the maintenance window is required")
+		return subcommands.ExitUsageError
+	}
+
+	selection := watchSelection{watches: s.watches, match: s.match, regex: s.regex, all: s.all}
+	watchNames, err := resolveWatchNames(s.host, s.port, s.authFile, selection, "silence", true, s.yes)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	silences, err := fetchActiveSilences(s.host, s.port, s.authFile, s.index)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	states, err := watchesActiveState(s.host, s.port, s.authFile, watchNames, silences)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	// The window is recorded first, such that the watches are reactivated even if a deactivation fails
+	createdAt := time.Now().UTC()
+	window := silence{
+		Watches:   watchNames,
+		Reason:    s.reason,
+		CreatedBy: s.by,
+		CreatedAt: createdAt,
+		ExpiresAt: createdAt.Add(s.duration),
+		State:     silenceActive,
+		Active:    states,
+	}
+	id, err := recordSilence(s.host, s.port, s.authFile, s.index, window)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	for _, watch := range watchNames {
+		rc := switchWatch(s.host, s.port, s.authFile, watch, "deactivate")
+		if rc != subcommands.ExitSuccess {
+			return rc
+		}
+	}
+
+	fmt.Printf("Silenced watches %s until %s (silence '%s').\n", strings.Join(watchNames, ", "),
+		window.ExpiresAt.Format(time.RFC3339), id)
+	return subcommands.ExitSuccess
+}
+
+type unsilenceCmd struct {
+	host     string
+	port     int
+	authFile string
+	id       string
+	index    string
+}
+
+func (*unsilenceCmd) Name() string { return "unsilence" }
+func (*unsilenceCmd) Synopsis() string {
+	return "End a maintenance window and reactivate its watches"
+}
+
+func (*unsilenceCmd) Usage() string {
+	return `unsilence [-host] <host name> [-port] <port> [-id] <silence id> [-index] <silences index> [-auth-file] <path to basic auth file>
+        End a maintenance window before it expires and reactivate its watches which are not part of another active window
+	`
+}
+
+func (u *unsilenceCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&u.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&u.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&u.id, "id", "", "ID of the silence")
+	f.StringVar(&u.index, "index", defaultSilencesIndex, "Index where the maintenance windows are recorded")
+	f.StringVar(&u.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (u *unsilenceCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if u.id == "" {
+		fmt.Println("The ID of the silence is required")
+		return subcommands.ExitUsageError
+	}
+
+	silences, err := fetchActiveSilences(u.host, u.port, u.authFile, u.index)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	var ending, remaining []silence
+	for _, s := range silences {
+		if s.ID == u.id {
+			ending = append(ending, s)
+		} else {
+			remaining = append(remaining, s)
+		}
+	}
+	if len(ending) == 0 {
+		fmt.Printf("No active silence with ID '%s'\n", u.id)
+		return subcommands.ExitFailure
+	}
+
+	return endSilences(u.host, u.port, u.authFile, u.index, ending, remaining)
+}
+
+type reconcileSilencesCmd struct {
+	host     string
+	port     int
+	authFile string
+	index    string
+}
+
+func (*reconcileSilencesCmd) Name() string { return "reconcile-silences" }
+func (*reconcileSilencesCmd) Synopsis() string {
+	return "Reactivate the watches whose maintenance window expired"
+}
+
+func (*reconcileSilencesCmd) Usage() string {
+	return `reconcile-silences [-host] <host name> [-port] <port> [-index] <silences index> [-auth-file] <path to basic auth file>
+        Reactivate the watches whose maintenance window expired, this command is meant to be executed periodically
+	`
+}
+
+func (r *reconcileSilencesCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&r.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&r.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&r.index, "index", defaultSilencesIndex, "Index where the maintenance windows are recorded")
+	f.StringVar(&r.authFile, "auth-file", "", "Path to basic auth file")
+}
+
+func (r *reconcileSilencesCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	silences, err := fetchActiveSilences(r.host, r.port, r.authFile, r.index)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	now := time.Now()
+	var expired, remaining []silence
+	for _, s := range silences {
+		if s.ExpiresAt.After(now) {
+			remaining = append(remaining, s)
+		} else {
+			expired = append(expired, s)
+		}
+	}
+	if len(expired) == 0 {
+		fmt.Println("No expired silence.")
+		return subcommands.ExitSuccess
+	}
+
+	return endSilences(r.host, r.port, r.authFile, r.index, expired, remaining)
+}
@@ -0,0 +1,243 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher silences", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	const watcherEndpoint = "/_xpack/watcher/watch"
+	const silencesEndpoint = "/" + defaultSilencesIndex
+	const SilencesResponse = `{"hits": {"hits": [
+		{"_id": "expired", "_source": {"watches": ["watch_a", "watch_b"], "reason": "deployment",
+			"expires_at": "2018-03-20T10:00:00Z", "state": "active"}},
+		{"_id": "running", "_source": {"watches": ["watch_b"], "reason": "migration",
+			"expires_at": "2999-01-01T00:00:00Z", "state": "active"}}
+	]}}`
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		u, err := url.Parse(server.URL())
+		Expect(err).ShouldNot(HaveOccurred())
+
+		host, port, err := net.SplitHostPort(u.Host)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		elasticHost = host
+		elasticPort, err = strconv.Atoi(port)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("reactivation", func() {
+		It("should not reactivate the watches of another active silence", func() {
+			ending := []silence{{Watches: []string{"watch_b", "watch_a"}}}
+			remaining := []silence{{Watches: []string{"watch_b"}}}
+
+			Expect(watchesToReactivate(ending, remaining)).Should(Equal([]string{"watch_a"}))
+		})
+
+		It("should not reactivate the watches which were inactive before the silence", func() {
+			ending := []silence{{Watches: []string{"watch_a", "watch_b"}, Active: map[string]bool{"watch_a": true, "watch_b": false}}}
+
+			Expect(watchesToReactivate(ending, nil)).Should(Equal([]string{"watch_a"}))
+		})
+
+		It("should keep the state recorded by an overlapping silence", func() {
+			active := []silence{
+				{Watches: []string{"watch_a", "watch_b"}, Active: map[string]bool{"watch_a": true, "watch_b": false}},
+				{Watches: []string{"watch_c"}},
+			}
+
+			states, err := watchesActiveState(elasticHost, elasticPort, "", []string{"watch_a", "watch_b", "watch_c"}, active)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(states).Should(Equal(map[string]bool{"watch_a": true, "watch_b": false, "watch_c": true}))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+	})
+
+	Context("silence command", func() {
+		It("should record the silence with the state of the watches and deactivate them", func() {
+			var recorded silence
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_search"),
+					ghttp.RespondWith(http.StatusNotFound, `{"error": {"type": "index_not_found_exception"}}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_a"),
+					ghttp.RespondWith(http.StatusOK, `{"found": true, "status": {"state": {"active": true}}}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watcherEndpoint+"/watch_b"),
+					ghttp.RespondWith(http.StatusOK, `{"found": true, "status": {"state": {"active": false}}}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_doc", "refresh=true"),
+					func(w http.ResponseWriter, req *http.Request) {
+						body, err := ioutil.ReadAll(req.Body)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(json.Unmarshal(body, &recorded)).Should(Succeed())
+					},
+					ghttp.RespondWith(http.StatusCreated, `{"_id": "silence_1"}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_a/_deactivate"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_b/_deactivate"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &silenceCmd{
+				host:     elasticHost,
+				port:     elasticPort,
+				watches:  "watch_a,watch_b",
+				yes:      true,
+				duration: 2 * time.Hour,
+				reason:   "deployment",
+				by:       "test",
+				index:    defaultSilencesIndex}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(6))
+			Expect(recorded.Active).Should(Equal(map[string]bool{"watch_a": true, "watch_b": false}))
+		})
+
+		It("should require a reason", func() {
+			cmd := &silenceCmd{
+				host:     elasticHost,
+				port:     elasticPort,
+				watches:  "watch_a",
+				duration: time.Hour,
+				index:    defaultSilencesIndex}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+			Expect(server.ReceivedRequests()).Should(BeEmpty())
+		})
+	})
+
+	Context("reconcile-silences command", func() {
+		It("should reactivate the watches of the expired silences", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_search"),
+					ghttp.RespondWith(http.StatusOK, SilencesResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_a/_activate"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_doc/expired/_update"),
+					ghttp.VerifyJSON(`{"doc": {"state": "ended"}}`),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &reconcileSilencesCmd{
+				host:  elasticHost,
+				port:  elasticPort,
+				index: defaultSilencesIndex}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+
+		It("should succeed when no silence was recorded yet", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_search"),
+					ghttp.RespondWith(http.StatusNotFound, `{"error": {"type": "index_not_found_exception"}}`),
+				),
+			)
+
+			cmd := &reconcileSilencesCmd{
+				host:  elasticHost,
+				port:  elasticPort,
+				index: defaultSilencesIndex}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		})
+	})
+
+	Context("reconcile-silences of a watch inactive before the silence", func() {
+		It("should only reactivate the watches which were active", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_search"),
+					ghttp.RespondWith(http.StatusOK, `{"hits": {"hits": [
+						{"_id": "expired", "_source": {"watches": ["watch_a", "watch_b"], "reason": "deployment",
+							"expires_at": "2018-03-20T10:00:00Z", "state": "active", "active": {"watch_a": true, "watch_b": false}}}
+					]}}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_a/_activate"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_doc/expired/_update"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &reconcileSilencesCmd{
+				host:  elasticHost,
+				port:  elasticPort,
+				index: defaultSilencesIndex}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+	})
+
+	Context("unsilence command", func() {
+		It("should end the given silence", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_search"),
+					ghttp.RespondWith(http.StatusOK, SilencesResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", silencesEndpoint+"/_doc/running/_update"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &unsilenceCmd{
+				host:  elasticHost,
+				port:  elasticPort,
+				id:    "running",
+				index: defaultSilencesIndex}
+
+			// watch_b is still part of the expired silence, which is not ended yet
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(2))
+		})
+	})
+})
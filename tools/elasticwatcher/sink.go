@@ -0,0 +1,177 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// sinkRequest a webhook request recorded by the sink
+type sinkRequest struct {
+	Time    string              `json:"time"`
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   string              `json:"query,omitempty"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body"`
+	Status  int                 `json:"status"`
+}
+
+// webhookSink records the requests it receives and answers them with the configured status codes
+type webhookSink struct {
+	mutex    sync.Mutex
+	statuses []int
+	count    int
+	out      io.Writer
+	log      io.Writer
+	now      func() time.Time
+}
+
+// parseStatuses parses a comma-separated list of HTTP status codes
+func parseStatuses(value string) ([]int, error) {
+	var statuses []int
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		status, err := strconv.Atoi(s)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid status code '%s'", s)
+		}
+		statuses = append(statuses, status)
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("no status code")
+	}
+	return statuses, nil
+}
+
+// nextStatus returns the status code of the next request, the last status code is repeated once the list is consumed
+func (s *webhookSink) nextStatus() int {
+	status := s.statuses[len(s.statuses)-1]
+	if s.count < len(s.statuses) {
+		status = s.statuses[s.count]
+	}
+	s.count++
+	return status
+}
+
+// ServeHTTP records the request to the output and to the log, and answers with the next status code
+func (s *webhookSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body) // #nosec
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	request := sinkRequest{
+		Time:    s.now().UTC().Format(time.RFC3339Nano),
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.RawQuery,
+		Headers: r.Header,
+		Body:    string(body),
+		Status:  s.nextStatus(),
+	}
+	if s.log != nil {
+		line, err := json.Marshal(request)
+		if err == nil {
+			_, err = fmt.Fprintf(s.log, "%s\n", line)
+		}
+		if err != nil {
+			fmt.Fprintf(s.out, "Failed to record the request. Error: %v\n", err)
+		}
+	}
+	printSinkRequest(s.out, s.count, request)
+
+	w.WriteHeader(request.Status)
+}
+
+// printSinkRequest prints a recorded request, the JSON bodies are indented
+func printSinkRequest(w io.Writer, number int, request sinkRequest) {
+	fmt.Fprintf(w, "#%d %s %s %s -> %d\n", number, request.Time, request.Method, request.Path, request.Status)
+	for _, name := range sortedHeaderNames(request.Headers) {
+		fmt.Fprintf(w, "%s: %s\n", name, strings.Join(request.Headers[name], ", "))
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(request.Body), "", "  "); err == nil {
+		fmt.Fprintf(w, "\n%s\n\n", indented.String())
+	} else {
+		fmt.Fprintf(w, "\n%s\n\n", request.Body)
+	}
+}
+
+// sortedHeaderNames returns the names of the headers in alphabetical order
+func sortedHeaderNames(headers map[string][]string) []string {
+	values := make(map[string]string, len(headers))
+	for name := range headers {
+		values[name] = ""
+	}
+	return sortedStringKeys(values)
+}
+
+type sinkCmd struct {
+	listen     string
+	outputFile string
+	status     string
+}
+
+func (*sinkCmd) Name() string { return "sink" }
+func (*sinkCmd) Synopsis() string {
+	return "Start a local webhook server which records the requests sent by the watch actions"
+}
+
+func (*sinkCmd) Usage() string {
+	return `sink [-listen] <address> [-output-file] <path to JSONL file> [-status] <status codes>
+        Start a local HTTP server which prints every received webhook request, its headers, path and body,
+        and appends it as a JSON line to the output file
+	`
+}
+
+func (s *sinkCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.listen, "listen", ":8080", "Address the server listens on")
+	f.StringVar(&s.outputFile, "output-file", "", "Path to the JSONL file the requests are appended to")
+	f.StringVar(&s.status, "status", "200", "Comma-separated status codes answered in order to the requests, the last one is repeated, e.g. 500,200")
+}
+
+func (s *sinkCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	statuses, err := parseStatuses(s.status)
+	if err != nil {
+		fmt.Printf("Invalid status codes '%s'. Error: %v\n", s.status, err)
+		return subcommands.ExitUsageError
+	}
+	sink := &webhookSink{statuses: statuses, out: os.Stdout, now: time.Now}
+	if s.outputFile != "" {
+		file, err := os.OpenFile(s.outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Printf("Failed to open the output file '%s'. Error: %v\n", s.outputFile, err)
+			return subcommands.ExitFailure
+		}
+		defer file.Close() // #nosec
+		sink.log = file
+	}
+
+	fmt.Printf("Recording the webhook requests on '%s'\n", s.listen)
+	if err := http.ListenAndServe(s.listen, sink); err != nil {
+		fmt.Printf("Failed to serve the webhook requests. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher webhook sink", func() {
+	var out, log *bytes.Buffer
+	var server *httptest.Server
+
+	newSink := func(statuses ...int) {
+		out = &bytes.Buffer{}
+		log = &bytes.Buffer{}
+		now := time.Date(2018, 3, 20, 10, 0, 0, 0, time.UTC)
+		server = httptest.NewServer(&webhookSink{statuses: statuses, out: out, log: log, now: func() time.Time { return now }})
+	}
+
+	post := func(path string, body string) int {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		Expect(err).ShouldNot(HaveOccurred())
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should record the requests as JSON lines", func() {
+		newSink(200)
+
+		Expect(post("/webhookb2/abc?x=1", `{"title": "Errors"}`)).Should(Equal(http.StatusOK))
+		Expect(post("/other", `plain text`)).Should(Equal(http.StatusOK))
+
+		lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+		Expect(lines).Should(HaveLen(2))
+		var request sinkRequest
+		Expect(json.Unmarshal([]byte(lines[0]), &request)).Should(Succeed())
+		Expect(request.Time).Should(Equal("2018-03-20T10:00:00Z"))
+		Expect(request.Method).Should(Equal("POST"))
+		Expect(request.Path).Should(Equal("/webhookb2/abc"))
+		Expect(request.Query).Should(Equal("x=1"))
+		Expect(request.Headers["Content-Type"]).Should(Equal([]string{"application/json"}))
+		Expect(request.Body).Should(Equal(`{"title": "Errors"}`))
+		Expect(request.Status).Should(Equal(http.StatusOK))
+	})
+
+	It("should print the requests with their indented JSON bodies", func() {
+		newSink(200)
+
+		post("/webhookb2/abc", `{"title": "Errors"}`)
+
+		Expect(out.String()).Should(HavePrefix("#1 2018-03-20T10:00:00Z POST /webhookb2/abc -> 200\n"))
+		Expect(out.String()).Should(ContainSubstring("Content-Type: application/json\n"))
+		Expect(out.String()).Should(HaveSuffix("\n{\n  \"title\": \"Errors\"\n}\n\n"))
+	})
+
+	It("should answer with the status codes in order and repeat the last one", func() {
+		newSink(500, 429, 200)
+
+		Expect(post("/", "{}")).Should(Equal(http.StatusInternalServerError))
+		Expect(post("/", "{}")).Should(Equal(http.StatusTooManyRequests))
+		Expect(post("/", "{}")).Should(Equal(http.StatusOK))
+		Expect(post("/", "{}")).Should(Equal(http.StatusOK))
+		Expect(out.String()).Should(ContainSubstring("#4 "))
+	})
+
+	It("should parse the status codes", func() {
+		Expect(parseStatuses("500, 200")).Should(Equal([]int{500, 200}))
+		_, err := parseStatuses("ok")
+		Expect(err).Should(HaveOccurred())
+		_, err = parseStatuses("")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should reject invalid status codes", func() {
+		server = httptest.NewServer(http.NotFoundHandler())
+		cmd := &sinkCmd{listen: "127.0.0.1:0", status: "42"}
+
+		Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitUsageError))
+	})
+})
@@ -0,0 +1,233 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// syncPlan holds the changes required to reconcile the installed watches with a watches file
+type syncPlan struct {
+	Create    []Watch
+	Update    []Watch
+	Delete    []string
+	Unchanged []string
+	// Unmanaged installed watches which are not declared, but are never pruned since
+	// they were not created by this tool or belong to another release
+	Unmanaged []string
+}
+
+// InSync indicates if the installed watches already match the watches file
+func (p *syncPlan) InSync(prune bool) bool {
+	return len(p.Create) == 0 && len(p.Update) == 0 && (!prune || len(p.Delete) == 0)
+}
+
+func watchNames(watches []Watch) []string {
+	names := make([]string, 0, len(watches))
+	for _, watch := range watches {
+		names = append(names, watch.Name)
+	}
+	return names
+}
+
+// computeSyncPlan compares the declared watches with the installed ones
+func computeSyncPlan(declared []Watch, installed []installedWatch, release string) (*syncPlan, error) {
+	installedByID := make(map[string]map[string]interface{}, len(installed))
+	prunable := make(map[string]bool, len(installed))
+	for _, watch := range installed {
+		prunable[watch.ID] = isPrunable(watch, release)
+		body, err := normalizeWatchBody(watch.Source)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to normalize the installed watch '%s': %v", watch.ID, err)
+		}
+		installedByID[watch.ID] = body
+	}
+
+	plan := &syncPlan{}
+	declaredNames := make(map[string]bool, len(declared))
+	for _, watch := range declared {
+		declaredNames[watch.Name] = true
+		current, ok := installedByID[watch.Name]
+		if !ok {
+			plan.Create = append(plan.Create, watch)
+			continue
+		}
+		body, err := normalizeWatchBody(watch.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to normalize the watch '%s': %v", watch.Name, err)
+		}
+		// the defaults added by Watcher are not part of the declared watch
+		stripWatchDefaults(body, current, nil)
+		if reflect.DeepEqual(body, current) {
+			plan.Unchanged = append(plan.Unchanged, watch.Name)
+		} else {
+			plan.Update = append(plan.Update, watch)
+		}
+	}
+
+	for id := range installedByID {
+		if declaredNames[id] {
+			continue
+		}
+		if prunable[id] {
+			plan.Delete = append(plan.Delete, id)
+		} else {
+			plan.Unmanaged = append(plan.Unmanaged, id)
+		}
+	}
+	sort.Strings(plan.Delete)
+	sort.Strings(plan.Unmanaged)
+	return plan, nil
+}
+
+func printSyncPlan(plan *syncPlan, prune bool) {
+	format := func(names []string) string {
+		if len(names) == 0 {
+			return "-"
+		}
+		return strings.Join(names, ", ")
+	}
+
+	deleteNote := ""
+	if !prune && len(plan.Delete) > 0 {
+		deleteNote = " (skipped, use -prune to delete)"
+	}
+
+	fmt.Println("Sync plan:")
+	fmt.Printf("  create:    %s\n", format(watchNames(plan.Create)))
+	fmt.Printf("  update:    %s\n", format(watchNames(plan.Update)))
+	fmt.Printf("  delete:    %s%s\n", format(plan.Delete), deleteNote)
+	fmt.Printf("  unchanged: %s\n", format(plan.Unchanged))
+	fmt.Printf("  unmanaged: %s\n", format(plan.Unmanaged))
+}
+
+func deleteWatch(host string, port int, authFile string, watch string) error {
+	statusCode, content, err := doRequest(http.MethodDelete, buildWatcherURL(host, port, watch), authFile, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to delete the watch '%s'. Error: %v", watch, err)
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("Failed to delete the watch '%s'. Status Code: %d. Error: %s", watch, statusCode, string(content))
+	}
+	return nil
+}
+
+type syncCmd struct {
+	host        string
+	port        int
+	watchesFile string
+	authFile    string
+	release     string
+	secretsFile string
+	prune       bool
+	dryRun      bool
+}
+
+func (*syncCmd) Name() string { return "sync" }
+func (*syncCmd) Synopsis() string {
+	return "Reconcile the watches installed in Elasticsearch Watcher with a watches file"
+}
+
+func (*syncCmd) Usage() string {
+	return `sync [-host] <host name> [-port] <port> [-watches-file] <path to watches file> [-auth-file] <path to basic auth file> [-release] <release name> [-secrets-file] <path to secrets file> [-prune] [-dry-run]
+        Create and update the watches declared in the watches file and, with -prune, delete the installed watches which are not declared.
+        Only the watches created by this tool, and with -release only the ones of that release, are deleted.
+	`
+}
+
+func (s *syncCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.host, "host", "localhost", "Host name of the Elasticsearch API")
+	f.IntVar(&s.port, "port", 9200, "Port of the Elastisearch API")
+	f.StringVar(&s.watchesFile, "watches-file", "", "Path to watches file")
+	f.StringVar(&s.authFile, "auth-file", "", "Path to basic auth file")
+	f.StringVar(&s.release, "release", "", "Name of the chart release stamped on the watches")
+	f.StringVar(&s.secretsFile, "secrets-file", "", "Path to the JSON file with the secrets referenced by the ${secret:name} placeholders")
+	f.BoolVar(&s.prune, "prune", false, "Delete the installed watches created by this tool which are not declared in the watches file")
+	f.BoolVar(&s.dryRun, "dry-run", false, "Only print the sync plan without applying it")
+}
+
+func (s *syncCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if _, err := os.Stat(s.watchesFile); os.IsNotExist(err) {
+		fmt.Printf("Watches file '%s' not found\n", s.watchesFile)
+		return subcommands.ExitFailure
+	}
+	cfg, err := loadWatches(s.watchesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	secrets, err := loadSecrets(s.secretsFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	watches, err := injectSecrets(cfg.Watches, secrets)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	watches, err = stampWatches(watches, s.watchesFile, s.release)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	installed, err := fetchInstalledWatches(s.host, s.port, s.authFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	plan, err := computeSyncPlan(watches, installed, s.release)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	printSyncPlan(plan, s.prune)
+
+	if s.dryRun || plan.InSync(s.prune) {
+		return subcommands.ExitSuccess
+	}
+
+	client := buildHTTPClient()
+	for _, watch := range plan.Create {
+		if err := putWatch(client, s.host, s.port, s.authFile, watch); err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("Successfully created the watch '%s'.\n", watch.Name)
+	}
+
+	for _, watch := range plan.Update {
+		if err := putWatch(client, s.host, s.port, s.authFile, watch); err != nil {
+			fmt.Println(err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("Successfully updated the watch '%s'.\n", watch.Name)
+	}
+
+	if s.prune {
+		for _, watch := range plan.Delete {
+			if err := deleteWatch(s.host, s.port, s.authFile, watch); err != nil {
+				fmt.Println(err)
+				return subcommands.ExitFailure
+			}
+			fmt.Printf("Successfully deleted the watch '%s'.\n", watch)
+		}
+	}
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,231 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("The elasticwatcher sync", func() {
+	var server *ghttp.Server
+	var elasticHost string
+	var elasticPort int
+	var watchesFile *os.File
+	const watcherEndpoint = "/_xpack/watcher/watch"
+	const watchesSearchEndpoint = "/.watches/_search"
+	const Watches = `{"watches": [
+		{"name": "watch_new", "body": {"trigger": {"schedule": {"interval": "5m"}}}},
+		{"name": "watch_changed", "body": {"trigger": {"schedule": {"interval": "10m"}}}},
+		{"name": "watch_same", "body": {"trigger": {"schedule": {"interval": "15m"}}}}
+	]}`
+	var SearchResponse string
+
+	// searchHit builds the source of a watch installed by a previous sync of the watches file
+	searchHit := func(name string, interval string) map[string]interface{} {
+		watch := Watch{Name: name, Body: map[string]interface{}{
+			"trigger": map[string]interface{}{"schedule": map[string]interface{}{"interval": interval}}}}
+		stamped, err := stampWatch(watch, watchesFile.Name(), "")
+		Expect(err).ShouldNot(HaveOccurred())
+		return map[string]interface{}{"_id": name, "_source": stamped.Body}
+	}
+
+	Context("plan", func() {
+		It("should classify the watches", func() {
+			declared := []Watch{
+				{Name: "a", Body: map[string]interface{}{"trigger": "x"}},
+				{Name: "b", Body: map[string]interface{}{"trigger": "y"}},
+				{Name: "c", Body: map[string]interface{}{"throttle_period": 5}},
+			}
+			installed := []installedWatch{
+				{ID: "b", Source: map[string]interface{}{"trigger": "z"}},
+				{ID: "c", Source: map[string]interface{}{"throttle_period": 5.0, "_status": map[string]interface{}{}}},
+				{ID: "d", Source: map[string]interface{}{"metadata": map[string]interface{}{
+					"elasticwatcher": map[string]interface{}{"managed_by": "elasticwatcher"}}}},
+				{ID: "e", Source: map[string]interface{}{}},
+			}
+
+			plan, err := computeSyncPlan(declared, installed, "")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(watchNames(plan.Create)).Should(Equal([]string{"a"}))
+			Expect(watchNames(plan.Update)).Should(Equal([]string{"b"}))
+			Expect(plan.Unchanged).Should(Equal([]string{"c"}))
+			Expect(plan.Delete).Should(Equal([]string{"d"}))
+			Expect(plan.Unmanaged).Should(Equal([]string{"e"}))
+			Expect(plan.InSync(false)).Should(BeFalse())
+		})
+
+		It("should ignore the defaults added by Watcher to the installed watches", func() {
+			declared := []Watch{{Name: "a", Body: map[string]interface{}{
+				"input": map[string]interface{}{"search": map[string]interface{}{"request": map[string]interface{}{
+					"indices": []interface{}{"logstash-*"}}}},
+				"actions": map[string]interface{}{"teams": map[string]interface{}{"webhook": map[string]interface{}{
+					"host": "outlook.office.com", "port": 443, "path": "/webhook"}}},
+			}}}
+			installed := []installedWatch{{ID: "a", Source: map[string]interface{}{
+				"input": map[string]interface{}{"search": map[string]interface{}{"request": map[string]interface{}{
+					"indices":                []interface{}{"logstash-*"},
+					"search_type":            "query_then_fetch",
+					"rest_total_hits_as_int": true}}},
+				"actions": map[string]interface{}{"teams": map[string]interface{}{"webhook": map[string]interface{}{
+					"host": "outlook.office.com", "port": 443.0, "path": "/webhook",
+					"scheme": "http", "method": "get", "params": map[string]interface{}{}, "headers": map[string]interface{}{}}}},
+				"status": map[string]interface{}{"state": map[string]interface{}{"active": true}},
+			}}}
+
+			plan, err := computeSyncPlan(declared, installed, "")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plan.Update).Should(BeEmpty())
+			Expect(plan.Unchanged).Should(Equal([]string{"a"}))
+		})
+
+		It("should only prune the watches of the given release", func() {
+			installed := []installedWatch{
+				{ID: "a", Source: map[string]interface{}{"metadata": map[string]interface{}{
+					"elasticwatcher": map[string]interface{}{"managed_by": "elasticwatcher", "release": "prod"}}}},
+				{ID: "b", Source: map[string]interface{}{"metadata": map[string]interface{}{
+					"elasticwatcher": map[string]interface{}{"managed_by": "elasticwatcher", "release": "dev"}}}},
+			}
+
+			plan, err := computeSyncPlan(nil, installed, "prod")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plan.Delete).Should(Equal([]string{"a"}))
+			Expect(plan.Unmanaged).Should(Equal([]string{"b"}))
+		})
+	})
+
+	Context("sync command", func() {
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+
+			u, err := url.Parse(server.URL())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			host, port, err := net.SplitHostPort(u.Host)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			elasticHost = host
+			elasticPort, err = strconv.Atoi(port)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			watchesFile, err = ioutil.TempFile("", "watches")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = watchesFile.Write([]byte(Watches))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			hits := []map[string]interface{}{
+				searchHit("watch_changed", "1m"),
+				searchHit("watch_same", "15m"),
+				searchHit("watch_old", "1h"),
+				{"_id": "watch_kibana", "_source": map[string]interface{}{"trigger": map[string]interface{}{}}},
+			}
+			content, err := json.Marshal(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+			Expect(err).ShouldNot(HaveOccurred())
+			SearchResponse = string(content)
+		})
+
+		AfterEach(func() {
+			if watchesFile != nil {
+				os.Remove(watchesFile.Name())
+			}
+			server.Close()
+		})
+
+		It("should create and update the watches without pruning", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_new"),
+					ghttp.RespondWith(http.StatusCreated, "{}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_changed"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &syncCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: watchesFile.Name()}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+
+		It("should delete the undeclared watches created by the tool with prune", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_new"),
+					ghttp.RespondWith(http.StatusCreated, "{}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", watcherEndpoint+"/watch_changed"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", watcherEndpoint+"/watch_old"),
+					ghttp.RespondWith(http.StatusOK, "{}"),
+				),
+			)
+
+			cmd := &syncCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: watchesFile.Name(),
+				prune:       true}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(4))
+		})
+
+		It("should only print the plan in dry run mode", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", watchesSearchEndpoint),
+					ghttp.RespondWith(http.StatusOK, SearchResponse),
+				),
+			)
+
+			cmd := &syncCmd{
+				host:        elasticHost,
+				port:        elasticPort,
+				watchesFile: watchesFile.Name(),
+				prune:       true,
+				dryRun:      true}
+
+			exitStatus := cmd.Execute(nil, nil)
+
+			Expect(exitStatus).Should(Equal(subcommands.ExitSuccess))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+	})
+})
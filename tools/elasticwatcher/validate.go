@@ -0,0 +1,262 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// validationError a problem found in a watch definition
+type validationError struct {
+	Watch   string
+	Path    string
+	Message string
+}
+
+func (e validationError) String() string {
+	return fmt.Sprintf("%s: %s: %s", e.Watch, e.Path, e.Message)
+}
+
+// intervalPattern matches the Watcher time values, e.g. 10s, 5m, 1h, 2d or 1w
+var intervalPattern = regexp.MustCompile(`^(\d+)(s|m|h|d|w)?$`)
+
+// identifierPattern matches the keys which can be used in a dotted JSON path
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// scheduleTypes the schedule types supported by Watcher
+var scheduleTypes = []string{"cron", "interval", "hourly", "daily", "weekly", "monthly", "yearly"}
+
+func jsonPathKey(path string, key string) string {
+	if identifierPattern.MatchString(key) {
+		return path + "." + key
+	}
+	return fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+}
+
+// parseInterval parses a Watcher interval, e.g. 5m, and returns it in seconds
+func parseInterval(interval interface{}) (int64, error) {
+	if seconds, ok := interval.(float64); ok {
+		if seconds <= 0 || seconds != float64(int64(seconds)) {
+			return 0, fmt.Errorf("invalid interval %v, expected a positive number of seconds", seconds)
+		}
+		return int64(seconds), nil
+	}
+
+	value, ok := interval.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid interval %v, expected a string such as '5m'", interval)
+	}
+	match := intervalPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, fmt.Errorf("invalid interval '%s', expected a number followed by s, m, h, d or w", value)
+	}
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid interval '%s', expected a positive value", value)
+	}
+	units := map[string]int64{"": 1, "s": 1, "m": 60, "h": 3600, "d": 86400, "w": 604800}
+	return n * units[match[2]], nil
+}
+
+// checkMustache verifies that the mustache placeholders and sections in a text are balanced
+func checkMustache(text string) error {
+	_, err := parseMustache(text)
+	return err
+}
+
+func truncate(text string, length int) string {
+	if len(text) <= length {
+		return text
+	}
+	return text[:length] + "..."
+}
+
+// watchValidator collects the validation errors of a single watch
+type watchValidator struct {
+	watch  string
+	errors []validationError
+}
+
+func (v *watchValidator) addError(path string, format string, args ...interface{}) {
+	v.errors = append(v.errors, validationError{Watch: v.watch, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *watchValidator) validateSchedule(schedule interface{}, path string) {
+	scheduleMap, ok := schedule.(map[string]interface{})
+	if !ok {
+		v.addError(path, "schedule must be an object")
+		return
+	}
+
+	var found []string
+	for _, scheduleType := range scheduleTypes {
+		if _, ok := scheduleMap[scheduleType]; ok {
+			found = append(found, scheduleType)
+		}
+	}
+	if len(found) != 1 {
+		v.addError(path, "schedule must define exactly one of %s", strings.Join(scheduleTypes, ", "))
+	}
+	for key := range scheduleMap {
+		if !containsString(scheduleTypes, key) {
+			v.addError(jsonPathKey(path, key), "unknown schedule type '%s'", key)
+		}
+	}
+
+	if cron, ok := scheduleMap["cron"]; ok {
+		cronPath := jsonPathKey(path, "cron")
+		switch expressions := cron.(type) {
+		case string:
+			if _, err := parseCron(expressions); err != nil {
+				v.addError(cronPath, "%v", err)
+			}
+		case []interface{}:
+			for i, expression := range expressions {
+				expressionPath := fmt.Sprintf("%s[%d]", cronPath, i)
+				if s, ok := expression.(string); !ok {
+					v.addError(expressionPath, "cron expression must be a string")
+				} else if _, err := parseCron(s); err != nil {
+					v.addError(expressionPath, "%v", err)
+				}
+			}
+		default:
+			v.addError(cronPath, "cron must be a string or a list of strings")
+		}
+	}
+
+	if interval, ok := scheduleMap["interval"]; ok {
+		if _, err := parseInterval(interval); err != nil {
+			v.addError(jsonPathKey(path, "interval"), "%v", err)
+		}
+	}
+}
+
+// validatePlaceholders checks the mustache placeholders of every string in a value
+func (v *watchValidator) validatePlaceholders(value interface{}, path string) {
+	switch value := value.(type) {
+	case string:
+		if err := checkMustache(value); err != nil {
+			v.addError(path, "%v", err)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			v.validatePlaceholders(value[key], jsonPathKey(path, key))
+		}
+	case []interface{}:
+		for i, item := range value {
+			v.validatePlaceholders(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWatches checks the watch definitions of a watches file without a cluster
+func validateWatches(cfg *WatcherConfig) []validationError {
+	var errors []validationError
+	names := make(map[string]bool)
+	for i, watch := range cfg.Watches {
+		path := fmt.Sprintf("$.watches[%d]", i)
+		v := &watchValidator{watch: watch.Name}
+		if watch.Name == "" {
+			v.watch = fmt.Sprintf("#%d", i)
+			v.addError(path+".name", "watch name is missing")
+		} else if names[watch.Name] {
+			v.addError(path+".name", "duplicate watch name")
+		}
+		names[watch.Name] = true
+
+		bodyPath := path + ".body"
+		body, ok := watch.Body.(map[string]interface{})
+		if !ok {
+			v.addError(bodyPath, "watch body must be an object")
+			errors = append(errors, v.errors...)
+			continue
+		}
+
+		for _, block := range []string{"trigger", "input", "condition", "actions"} {
+			value, ok := body[block]
+			if !ok {
+				v.addError(bodyPath, "missing '%s' block", block)
+				continue
+			}
+			if m, ok := value.(map[string]interface{}); !ok || len(m) == 0 {
+				v.addError(jsonPathKey(bodyPath, block), "'%s' must be a non-empty object", block)
+			}
+		}
+
+		if trigger, ok := body["trigger"].(map[string]interface{}); ok {
+			triggerPath := jsonPathKey(bodyPath, "trigger")
+			if schedule, ok := trigger["schedule"]; ok {
+				v.validateSchedule(schedule, jsonPathKey(triggerPath, "schedule"))
+			} else {
+				v.addError(triggerPath, "missing 'schedule' block")
+			}
+		}
+
+		v.validatePlaceholders(body, bodyPath)
+		errors = append(errors, v.errors...)
+	}
+	return errors
+}
+
+type validateCmd struct {
+	watchesFile string
+}
+
+func (*validateCmd) Name() string { return "validate" }
+func (*validateCmd) Synopsis() string {
+	return "Validate a watches file without connecting to Elasticsearch"
+}
+
+func (*validateCmd) Usage() string {
+	return `validate [-watches-file] <path to watches file>
+        Validate the structure, schedules and mustache placeholders of the watches defined in a watches file
+	`
+}
+
+func (v *validateCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&v.watchesFile, "watches-file", "", "Path to watches file")
+}
+
+func (v *validateCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if _, err := os.Stat(v.watchesFile); os.IsNotExist(err) {
+		fmt.Printf("Watches file '%s' not found\n", v.watchesFile)
+		return subcommands.ExitFailure
+	}
+	cfg, err := loadWatches(v.watchesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	errors := validateWatches(cfg)
+	if len(errors) > 0 {
+		for _, e := range errors {
+			fmt.Println(e)
+		}
+		fmt.Printf("Found %d error(s) in the watches file '%s'\n", len(errors), v.watchesFile)
+		return subcommands.ExitFailure
+	}
+
+	fmt.Printf("The watches file '%s' is valid (%d watches)\n", v.watchesFile, len(cfg.Watches))
+	return subcommands.ExitSuccess
+}
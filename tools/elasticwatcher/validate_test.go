@@ -0,0 +1,116 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher validate", func() {
+	const ValidWatches = `{"watches": [{"name": "watch_test", "body": {
+		"trigger": {"schedule": {"cron": "0 0/1 * * * ?"}},
+		"input": {"search": {"request": {"indices": ["logstash-*"]}}},
+		"condition": {"compare": {"ctx.payload.hits.total": {"gt": 0}}},
+		"actions": {"teams_webhook": {"webhook": {"body": "{\"text\":\"{{ctx.payload.hits.total}} {{#ctx.payload.hits.hits}}{{_id}}{{/ctx.payload.hits.hits}}\"}"}}}
+	}}]}`
+
+	writeWatchesFile := func(content string) string {
+		watchesFile, err := ioutil.TempFile("", "watches")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = watchesFile.Write([]byte(content))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		return watchesFile.Name()
+	}
+
+	Context("intervals", func() {
+		It("should parse the Watcher time values", func() {
+			Expect(parseInterval("10s")).Should(Equal(int64(10)))
+			Expect(parseInterval("5m")).Should(Equal(int64(300)))
+			Expect(parseInterval("2d")).Should(Equal(int64(172800)))
+			Expect(parseInterval(30.0)).Should(Equal(int64(30)))
+		})
+
+		It("should reject invalid intervals", func() {
+			for _, interval := range []interface{}{"5 minutes", "0m", "-1h", "", true} {
+				_, err := parseInterval(interval)
+				Expect(err).Should(HaveOccurred())
+			}
+		})
+	})
+
+	Context("mustache placeholders", func() {
+		It("should accept balanced placeholders", func() {
+			Expect(checkMustache(`{"a":{"b":"{{ctx.id}}"}} {{{ctx.raw}}} {{^x}}none{{/x}}`)).Should(Succeed())
+		})
+
+		It("should report unbalanced placeholders", func() {
+			Expect(checkMustache("count {{ctx.payload.hits.total}")).ShouldNot(Succeed())
+			Expect(checkMustache("{{#items}}{{name}}")).ShouldNot(Succeed())
+			Expect(checkMustache("{{#a}}{{/b}}")).ShouldNot(Succeed())
+			Expect(checkMustache("{{ }}")).ShouldNot(Succeed())
+			Expect(checkMustache("{{> partial}}")).ShouldNot(Succeed())
+		})
+	})
+
+	Context("watches", func() {
+		It("should report the errors with the watch name and path", func() {
+			cfg := &WatcherConfig{Watches: []Watch{
+				{Name: "watch_test", Body: map[string]interface{}{
+					"trigger": map[string]interface{}{
+						"schedule": map[string]interface{}{"interval": "5 minutes"},
+					},
+					"input":   map[string]interface{}{"simple": map[string]interface{}{}},
+					"actions": map[string]interface{}{"log": map[string]interface{}{"logging": map[string]interface{}{"text": "{{ctx.id"}}},
+				}},
+				{Body: map[string]interface{}{}},
+			}}
+
+			errors := validateWatches(cfg)
+
+			var messages []string
+			for _, e := range errors {
+				messages = append(messages, e.Watch+" "+e.Path)
+			}
+			Expect(messages).Should(ConsistOf(
+				"watch_test $.watches[0].body",
+				"watch_test $.watches[0].body.trigger.schedule.interval",
+				"watch_test $.watches[0].body.actions.log.logging.text",
+				"#1 $.watches[1].name",
+				"#1 $.watches[1].body",
+				"#1 $.watches[1].body",
+				"#1 $.watches[1].body",
+				"#1 $.watches[1].body",
+			))
+		})
+	})
+
+	Context("validate command", func() {
+		It("should succeed for a valid watches file", func() {
+			watchesFile := writeWatchesFile(ValidWatches)
+			defer os.Remove(watchesFile)
+
+			cmd := &validateCmd{watchesFile: watchesFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		})
+
+		It("should fail for a watches file with a syntax error", func() {
+			watchesFile := writeWatchesFile(`{"watches": [{"name": "watch_test" "body": {}}]}`)
+			defer os.Remove(watchesFile)
+
+			cmd := &validateCmd{watchesFile: watchesFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+	})
+})
@@ -0,0 +1,346 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// watchTestSpec an offline test of a watch against a fixture payload
+type watchTestSpec struct {
+	Name  string `json:"name"`
+	Watch string `json:"watch"`
+	// Payload is used as ctx.payload, it can also be loaded from PayloadFile
+	Payload     interface{} `json:"payload"`
+	PayloadFile string      `json:"payload_file"`
+	// Time is the simulated execution and trigger time, in RFC3339 format
+	Time string `json:"time"`
+	// Condition is the expected result of the watch condition
+	Condition *bool `json:"condition"`
+	// Actions maps the action names to the expected rendered values, indexed by their path in the action, e.g. webhook.body
+	Actions map[string]map[string]string `json:"actions"`
+}
+
+// watchTestsConfig holds the offline tests of watches
+type watchTestsConfig struct {
+	Tests []watchTestSpec `json:"tests"`
+}
+
+// watchTestResult outcome of an offline test
+type watchTestResult struct {
+	Name     string
+	Watch    string
+	Failures []string
+	Duration time.Duration
+}
+
+func loadWatchTests(testsFile string) (*watchTestsConfig, error) {
+	content, err := ioutil.ReadFile(testsFile) // #nosec
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the tests file: %v", err)
+	}
+	var cfg watchTestsConfig
+	err = json.Unmarshal(content, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal the tests: %v", describeJSONError(content, err))
+	}
+
+	// The payload files are relative to the tests file
+	dir := filepath.Dir(testsFile)
+	for i, spec := range cfg.Tests {
+		if spec.PayloadFile == "" {
+			continue
+		}
+		path := spec.PayloadFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		payload, err := loadJSONFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the payload of the test '%s': %v", spec.Name, err)
+		}
+		cfg.Tests[i].Payload = payload
+	}
+	return &cfg, nil
+}
+
+// buildWatchContext simulates the execution context of a watch, which is available as 'ctx' in the templates
+func buildWatchContext(name string, body map[string]interface{}, payload interface{}, now time.Time) map[string]interface{} {
+	timestamp := now.UTC().Format("2006-01-02T15:04:05.000Z")
+	metadata, _ := body["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"ctx": map[string]interface{}{
+			"id":             fmt.Sprintf("%s_%s", name, timestamp),
+			"watch_id":       name,
+			"execution_time": timestamp,
+			"trigger": map[string]interface{}{
+				"triggered_time": timestamp,
+				"scheduled_time": timestamp,
+			},
+			"metadata": metadata,
+			"payload":  payload,
+			"vars":     map[string]interface{}{},
+		},
+	}
+}
+
+// runWatchTest evaluates the condition of the watch against the fixture payload and renders its actions
+func runWatchTest(spec watchTestSpec, watches map[string]Watch) (result watchTestResult) {
+	start := time.Now()
+	result = watchTestResult{Name: spec.Name, Watch: spec.Watch}
+	fail := func(format string, args ...interface{}) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+	defer func() { result.Duration = time.Since(start) }()
+
+	watch, ok := watches[spec.Watch]
+	if !ok {
+		fail("watch '%s' not found in the watches file", spec.Watch)
+		return result
+	}
+	body, err := normalizeWatchBody(watch.Body)
+	if err != nil {
+		fail("invalid watch body: %v", err)
+		return result
+	}
+
+	now := time.Now()
+	if spec.Time != "" {
+		if now, err = time.Parse(time.RFC3339, spec.Time); err != nil {
+			fail("invalid time '%s': %v", spec.Time, err)
+			return result
+		}
+	}
+	context := buildWatchContext(spec.Watch, body, spec.Payload, now)
+
+	met, err := evaluateCondition(body["condition"], context)
+	if err != nil {
+		fail("failed to evaluate the condition: %v", err)
+		return result
+	}
+	if spec.Condition != nil && *spec.Condition != met {
+		fail("expected the condition to be %t, but it was %t", *spec.Condition, met)
+	}
+
+	actions, _ := body["actions"].(map[string]interface{})
+	for _, name := range sortedExpectedActions(spec.Actions) {
+		definition, ok := actions[name].(map[string]interface{})
+		if !ok {
+			fail("action '%s' not found in the watch", name)
+			continue
+		}
+		if !met {
+			fail("expected the action '%s' to be executed, but the watch condition was not met", name)
+			continue
+		}
+		actionMet, err := evaluateCondition(definition["condition"], context)
+		if err != nil {
+			fail("failed to evaluate the condition of the action '%s': %v", name, err)
+			continue
+		}
+		if !actionMet {
+			fail("expected the action '%s' to be executed, but its condition was not met", name)
+			continue
+		}
+
+		template := make(map[string]interface{}, len(definition))
+		for key, value := range definition {
+			if key != "condition" && key != "transform" {
+				template[key] = value
+			}
+		}
+		renderer := &mustacheRenderer{}
+		rendered, err := renderer.renderTemplates(template, context)
+		if err != nil {
+			fail("failed to render the action '%s': %v", name, err)
+			continue
+		}
+
+		expected := spec.Actions[name]
+		for _, path := range sortedStringKeys(expected) {
+			value, found := lookupMustachePath(rendered, strings.Split(path, "."))
+			if !found {
+				fail("action '%s' has no field '%s'", name, path)
+				continue
+			}
+			if actual := mustacheString(value); actual != expected[path] {
+				fail("action '%s' field '%s':\n  expected: %s\n  actual:   %s", name, path, expected[path], actual)
+			}
+		}
+		if len(renderer.Unresolved) > 0 {
+			fail("action '%s' has unresolved references: %s", name, strings.Join(renderer.Unresolved, ", "))
+		}
+	}
+	return result
+}
+
+func sortedExpectedActions(actions map[string]map[string]string) []string {
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// junitTestSuites the root of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite a suite of a JUnit XML report
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase a test case of a JUnit XML report
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure the failure of a JUnit test case
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// buildJUnitReport converts the test results into a JUnit XML report
+func buildJUnitReport(results []watchTestResult) ([]byte, error) {
+	suite := junitTestSuite{Name: "elasticwatcher", Tests: len(results)}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Duration
+		testCase := junitTestCase{Name: result.Name, ClassName: result.Watch, Time: formatSeconds(result.Duration)}
+		if len(result.Failures) > 0 {
+			suite.Failures++
+			testCase.Failure = &junitFailure{
+				Message: strings.SplitN(result.Failures[0], "\n", 2)[0],
+				Content: strings.Join(result.Failures, "\n"),
+			}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = formatSeconds(total)
+
+	content, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+type testCmd struct {
+	watchesFile string
+	testsFile   string
+	junitFile   string
+}
+
+func (*testCmd) Name() string { return "test" }
+func (*testCmd) Synopsis() string {
+	return "Test the conditions and the actions of watches offline against fixture payloads"
+}
+
+func (*testCmd) Usage() string {
+	return `test [-watches-file] <path to watches file> [-tests-file] <path to tests file> [-junit-file] <path to JUnit report>
+        Evaluate the conditions of the watches against fixture payloads used as ctx.payload and compare their rendered
+        actions with the expected values, without connecting to Elasticsearch
+	`
+}
+
+func (t *testCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&t.watchesFile, "watches-file", "", "Path to watches file")
+	f.StringVar(&t.testsFile, "tests-file", "", "Path to tests file")
+	f.StringVar(&t.junitFile, "junit-file", "", "Path to the JUnit XML report (default standard output)")
+}
+
+func (t *testCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	cfg, err := loadWatches(t.watchesFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+	tests, err := loadWatchTests(t.testsFile)
+	if err != nil {
+		fmt.Println(err)
+		return subcommands.ExitFailure
+	}
+
+	watches := make(map[string]Watch, len(cfg.Watches))
+	for _, watch := range cfg.Watches {
+		watches[watch.Name] = watch
+	}
+
+	var results []watchTestResult
+	failed := 0
+	for _, spec := range tests.Tests {
+		result := runWatchTest(spec, watches)
+		if len(result.Failures) > 0 {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	report, err := buildJUnitReport(results)
+	if err != nil {
+		fmt.Printf("Failed to build the JUnit report. Error: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	if t.junitFile == "" {
+		fmt.Println(string(report))
+	} else {
+		for _, result := range results {
+			if len(result.Failures) == 0 {
+				fmt.Printf("PASS  %s (%s)\n", result.Name, result.Watch)
+				continue
+			}
+			fmt.Printf("FAIL  %s (%s)\n", result.Name, result.Watch)
+			for _, failure := range result.Failures {
+				fmt.Printf("      %s\n", strings.Replace(failure, "\n", "\n      ", -1))
+			}
+		}
+		fmt.Printf("%d tests, %d failed\n", len(results), failed)
+
+		err = ioutil.WriteFile(t.junitFile, report, 0644) // #nosec
+		if err != nil {
+			fmt.Printf("Failed to write the JUnit report '%s'. Error: %v\n", t.junitFile, err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	if failed > 0 {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,189 @@
+// Copyright (c) Microsoft and contributors.  All rights reserved.
+//
+// This source code is licensed under the MIT license found in the
+// LICENSE file in the root directory of this source tree.
+
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/subcommands"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The elasticwatcher test", func() {
+	const Watches = `{"watches": [{"name": "watch_errors", "body": {
+		"trigger": {"schedule": {"interval": "5m"}},
+		"metadata": {"threshold": 10},
+		"condition": {"compare": {"ctx.payload.hits.total": {"gte": "{{ctx.metadata.threshold}}"}}},
+		"actions": {
+			"teams_webhook": {
+				"webhook": {
+					"method": "POST",
+					"host": "outlook.office.com",
+					"body": "{\"text\": \"{{ctx.watch_id}}: {{ctx.payload.hits.total}} errors at {{ctx.execution_time}}\"}"
+				}
+			},
+			"log": {
+				"condition": {"never": {}},
+				"logging": {"text": "{{ctx.payload.hits.total}} errors"}
+			}
+		}
+	}}]}`
+	var dir string
+	var watches map[string]Watch
+
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).Should(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "watchtests")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		cfg, err := loadWatches(writeFile("watches.json", Watches))
+		Expect(err).ShouldNot(HaveOccurred())
+		watches = map[string]Watch{}
+		for _, watch := range cfg.Watches {
+			watches[watch.Name] = watch
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	boolPtr := func(b bool) *bool { return &b }
+
+	It("should pass when the condition and the rendered actions match", func() {
+		result := runWatchTest(watchTestSpec{
+			Name:      "errors above threshold",
+			Watch:     "watch_errors",
+			Payload:   map[string]interface{}{"hits": map[string]interface{}{"total": 12.0}},
+			Time:      "2018-03-20T10:30:00Z",
+			Condition: boolPtr(true),
+			Actions: map[string]map[string]string{
+				"teams_webhook": {
+					"webhook.method": "POST",
+					"webhook.body":   `{"text": "watch_errors: 12 errors at 2018-03-20T10:30:00.000Z"}`,
+				},
+			},
+		}, watches)
+
+		Expect(result.Failures).Should(BeEmpty())
+	})
+
+	It("should report the mismatches", func() {
+		result := runWatchTest(watchTestSpec{
+			Name:      "errors below threshold",
+			Watch:     "watch_errors",
+			Payload:   map[string]interface{}{"hits": map[string]interface{}{"total": 3.0}},
+			Condition: boolPtr(true),
+			Actions:   map[string]map[string]string{"teams_webhook": {"webhook.body": ""}},
+		}, watches)
+
+		Expect(result.Failures).Should(Equal([]string{
+			"expected the condition to be true, but it was false",
+			"expected the action 'teams_webhook' to be executed, but the watch condition was not met",
+		}))
+	})
+
+	It("should report the actions which are not executed or not rendered", func() {
+		result := runWatchTest(watchTestSpec{
+			Name:    "actions",
+			Watch:   "watch_errors",
+			Payload: map[string]interface{}{"hits": map[string]interface{}{"total": 12.0}},
+			Actions: map[string]map[string]string{
+				"log":           {"logging.text": "12 errors"},
+				"email":         {"email.subject": "errors"},
+				"teams_webhook": {"webhook.url": "https://example.com"},
+			},
+		}, watches)
+
+		Expect(result.Failures).Should(Equal([]string{
+			"action 'email' not found in the watch",
+			"expected the action 'log' to be executed, but its condition was not met",
+			"action 'teams_webhook' has no field 'webhook.url'",
+		}))
+	})
+
+	It("should report an unknown watch", func() {
+		result := runWatchTest(watchTestSpec{Name: "unknown", Watch: "watch_unknown"}, watches)
+
+		Expect(result.Failures).Should(Equal([]string{"watch 'watch_unknown' not found in the watches file"}))
+	})
+
+	It("should build a JUnit report", func() {
+		report, err := buildJUnitReport([]watchTestResult{
+			{Name: "passing", Watch: "watch_errors"},
+			{Name: "failing", Watch: "watch_errors", Failures: []string{"first\n  details", "second"}},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		var suites junitTestSuites
+		Expect(xml.Unmarshal(report, &suites)).Should(Succeed())
+		Expect(suites.Suites).Should(HaveLen(1))
+		suite := suites.Suites[0]
+		Expect(suite.Tests).Should(Equal(2))
+		Expect(suite.Failures).Should(Equal(1))
+		Expect(suite.Cases[0].Failure).Should(BeNil())
+		Expect(suite.Cases[1].ClassName).Should(Equal("watch_errors"))
+		Expect(suite.Cases[1].Failure.Message).Should(Equal("first"))
+		Expect(suite.Cases[1].Failure.Content).Should(Equal("first\n  details\nsecond"))
+	})
+
+	Context("test command", func() {
+		var watchesFile, junitFile string
+
+		BeforeEach(func() {
+			watchesFile = filepath.Join(dir, "watches.json")
+			junitFile = filepath.Join(dir, "junit.xml")
+			writeFile("errors.json", `{"hits": {"total": 12}}`)
+		})
+
+		It("should load the payload files and write the JUnit report", func() {
+			testsFile := writeFile("tests.json", `{"tests": [{
+				"name": "errors above threshold",
+				"watch": "watch_errors",
+				"payload_file": "errors.json",
+				"condition": true,
+				"actions": {"log": {}}
+			}]}`)
+			cmd := &testCmd{watchesFile: watchesFile, testsFile: testsFile, junitFile: junitFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+
+			content, err := ioutil.ReadFile(junitFile)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(content)).Should(ContainSubstring(`<testcase name="errors above threshold" classname="watch_errors"`))
+			Expect(string(content)).Should(ContainSubstring("its condition was not met"))
+		})
+
+		It("should succeed when all the tests pass", func() {
+			testsFile := writeFile("tests.json", `{"tests": [{
+				"name": "errors above threshold",
+				"watch": "watch_errors",
+				"payload_file": "errors.json",
+				"condition": true
+			}]}`)
+			cmd := &testCmd{watchesFile: watchesFile, testsFile: testsFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitSuccess))
+		})
+
+		It("should fail on a missing payload file", func() {
+			testsFile := writeFile("tests.json", `{"tests": [{"name": "missing", "watch": "watch_errors", "payload_file": "missing.json"}]}`)
+			cmd := &testCmd{watchesFile: watchesFile, testsFile: testsFile}
+
+			Expect(cmd.Execute(nil, nil)).Should(Equal(subcommands.ExitFailure))
+		})
+	})
+})